@@ -0,0 +1,32 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "log"
+
+    "github.com/kgugunava/database/codec"
+)
+
+// convert copies a data file into another record format, e.g.
+//
+//	go run ./convert -from input.jsonl -to input.sdb
+//	go run ./convert -from input.sdb -to input.jsonl
+func main() {
+    from := flag.String("from", "", "source data file")
+    to := flag.String("to", "", "destination data file, its extension selects the format")
+    flag.Parse()
+
+    if *from == "" || *to == "" {
+        flag.Usage()
+        log.Fatal("both -from and -to are required")
+    }
+
+    count, err := codec.Convert(*from, *to)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    fmt.Printf("Converted %d records from %s (%s) to %s (%s)\n",
+        count, *from, codec.ForPath(*from).Name(), *to, codec.ForPath(*to).Name())
+}
@@ -0,0 +1,212 @@
+// Package bitmap is a compressed set of ints in the style of roaring bitmaps,
+// for indexes on fields with few distinct values.
+package bitmap
+
+import (
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// Bitmap splits ids into chunks of 65536 by their high bits. A chunk with
+// few ids is a sorted array of the low 16 bits, a dense one is a bitset of
+// 8 KiB, so a set costs at most about 2 bytes per id and far less for long
+// runs of ids.
+//
+// The zero value is an empty set. NOT has no meaning without a universe, use
+// AndNot with the set of all ids.
+type Bitmap struct {
+	keys       []int64 // high bits of the chunks, ascending
+	containers []*container
+}
+
+// arrayMax is the size at which an array container takes as much memory as
+// a bitset and is converted.
+const arrayMax = 4096
+
+type container struct {
+	array []uint16 // sorted, used while n <= arrayMax
+	set   []uint64 // 1024 words, used otherwise
+	n     int
+}
+
+func New() *Bitmap {
+	return &Bitmap{}
+}
+
+// Of returns a bitmap with the given ids.
+func Of(ids ...int) *Bitmap {
+	b := New()
+	for _, id := range ids {
+		b.Add(id)
+	}
+	return b
+}
+
+func split(id int) (int64, uint16) {
+	return int64(id) >> 16, uint16(id)
+}
+
+func (b *Bitmap) find(key int64) (int, bool) {
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+	return i, i < len(b.keys) && b.keys[i] == key
+}
+
+func (b *Bitmap) Add(id int) {
+	key, low := split(id)
+	i, found := b.find(key)
+	if !found {
+		b.keys = slices.Insert(b.keys, i, key)
+		b.containers = slices.Insert(b.containers, i, &container{})
+	}
+	b.containers[i].add(low)
+}
+
+func (b *Bitmap) Remove(id int) {
+	key, low := split(id)
+	i, found := b.find(key)
+	if !found {
+		return
+	}
+	c := b.containers[i]
+	c.remove(low)
+	if c.n == 0 {
+		b.keys = slices.Delete(b.keys, i, i+1)
+		b.containers = slices.Delete(b.containers, i, i+1)
+	}
+}
+
+func (b *Bitmap) Contains(id int) bool {
+	key, low := split(id)
+	i, found := b.find(key)
+	return found && b.containers[i].contains(low)
+}
+
+// Len is the number of ids in the set.
+func (b *Bitmap) Len() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.n
+	}
+	return n
+}
+
+// Iterate calls fn for every id in ascending order until fn returns false.
+func (b *Bitmap) Iterate(fn func(id int) bool) {
+	for i, c := range b.containers {
+		base := int(b.keys[i] << 16)
+		if c.set == nil {
+			for _, low := range c.array {
+				if !fn(base | int(low)) {
+					return
+				}
+			}
+			continue
+		}
+		for w, word := range c.set {
+			for word != 0 {
+				bit := bits.TrailingZeros64(word)
+				if !fn(base | (w*64 + bit)) {
+					return
+				}
+				word &= word - 1
+			}
+		}
+	}
+}
+
+// Ids returns the ids in ascending order.
+func (b *Bitmap) Ids() []int {
+	ids := make([]int, 0, b.Len())
+	b.Iterate(func(id int) bool {
+		ids = append(ids, id)
+		return true
+	})
+	return ids
+}
+
+func (b *Bitmap) Clone() *Bitmap {
+	clone := &Bitmap{
+		keys:       slices.Clone(b.keys),
+		containers: make([]*container, len(b.containers)),
+	}
+	for i, c := range b.containers {
+		clone.containers[i] = c.clone()
+	}
+	return clone
+}
+
+// SizeBytes estimates the memory taken by the set.
+func (b *Bitmap) SizeBytes() int {
+	size := 48 + len(b.keys)*8 + len(b.containers)*8
+	for _, c := range b.containers {
+		size += 64 + cap(c.array)*2 + cap(c.set)*8
+	}
+	return size
+}
+
+// And returns the ids in both a and b.
+func And(a, b *Bitmap) *Bitmap {
+	result := New()
+	i, j := 0, 0
+	for i < len(a.keys) && j < len(b.keys) {
+		switch {
+		case a.keys[i] < b.keys[j]:
+			i++
+		case a.keys[i] > b.keys[j]:
+			j++
+		default:
+			if c := and(a.containers[i], b.containers[j]); c.n > 0 {
+				result.keys = append(result.keys, a.keys[i])
+				result.containers = append(result.containers, c)
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// Or returns the ids in a or b.
+func Or(a, b *Bitmap) *Bitmap {
+	result := New()
+	i, j := 0, 0
+	for i < len(a.keys) || j < len(b.keys) {
+		switch {
+		case j == len(b.keys) || i < len(a.keys) && a.keys[i] < b.keys[j]:
+			result.keys = append(result.keys, a.keys[i])
+			result.containers = append(result.containers, a.containers[i].clone())
+			i++
+		case i == len(a.keys) || a.keys[i] > b.keys[j]:
+			result.keys = append(result.keys, b.keys[j])
+			result.containers = append(result.containers, b.containers[j].clone())
+			j++
+		default:
+			result.keys = append(result.keys, a.keys[i])
+			result.containers = append(result.containers, or(a.containers[i], b.containers[j]))
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// AndNot returns the ids in a but not in b. NOT b is AndNot(all, b).
+func AndNot(a, b *Bitmap) *Bitmap {
+	result := New()
+	j := 0
+	for i, key := range a.keys {
+		for j < len(b.keys) && b.keys[j] < key {
+			j++
+		}
+		c := a.containers[i].clone()
+		if j < len(b.keys) && b.keys[j] == key {
+			c = andNot(a.containers[i], b.containers[j])
+		}
+		if c.n > 0 {
+			result.keys = append(result.keys, key)
+			result.containers = append(result.containers, c)
+		}
+	}
+	return result
+}
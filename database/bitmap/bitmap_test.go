@@ -0,0 +1,239 @@
+package bitmap
+
+import (
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// randomSet returns n random ids below limit as a bitmap and as a map.
+func randomSet(rng *rand.Rand, n, limit int) (*Bitmap, map[int]bool) {
+	b, m := New(), make(map[int]bool)
+	for range n {
+		id := rng.Intn(limit)
+		b.Add(id)
+		m[id] = true
+	}
+	return b, m
+}
+
+func sortedKeys(m map[int]bool) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestAddRemove(t *testing.T) {
+	// enough ids in one chunk to turn its array into a bitset and back
+	b, m := randomSet(rand.New(rand.NewSource(1)), 6000, 65536)
+	b.Add(-5)
+	m[-5] = true
+	if !slices.Equal(b.Ids(), sortedKeys(m)) || b.Len() != len(m) {
+		t.Fatalf("bitmap has %d ids, want %d", b.Len(), len(m))
+	}
+	for id := range m {
+		if id%3 != 0 {
+			b.Remove(id)
+			delete(m, id)
+		}
+	}
+	if !slices.Equal(b.Ids(), sortedKeys(m)) {
+		t.Fatal("ids differ after removing")
+	}
+	for id := -5; id < 65536; id++ {
+		if b.Contains(id) != m[id] {
+			t.Fatalf("Contains(%d) = %v", id, b.Contains(id))
+		}
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, n := range []int{100, 5000, 150000} {
+		a, am := randomSet(rng, n, 300000)
+		b, bm := randomSet(rng, n, 300000)
+
+		and, or, andNot := make(map[int]bool), make(map[int]bool), make(map[int]bool)
+		for id := range am {
+			or[id] = true
+			if bm[id] {
+				and[id] = true
+			} else {
+				andNot[id] = true
+			}
+		}
+		for id := range bm {
+			or[id] = true
+		}
+
+		if !slices.Equal(And(a, b).Ids(), sortedKeys(and)) {
+			t.Fatalf("%d ids: And is wrong", n)
+		}
+		if !slices.Equal(Or(a, b).Ids(), sortedKeys(or)) {
+			t.Fatalf("%d ids: Or is wrong", n)
+		}
+		if !slices.Equal(AndNot(a, b).Ids(), sortedKeys(andNot)) {
+			t.Fatalf("%d ids: AndNot is wrong", n)
+		}
+	}
+}
+
+// The benchmarks compare bitmaps with the map[...]map[int]bool indexes they
+// replaced, on 1 000 000 students with a random active flag and one of 401
+// gpa values:
+//
+//	go test -run '^$' -bench . ./database/bitmap
+const benchmarkStudents = 1_000_000
+
+type benchmarkIndexes struct {
+	activeMap map[bool]map[int]bool
+	gpaMap    map[int]map[int]bool
+	active    map[bool]*Bitmap
+	gpa       map[int]*Bitmap
+}
+
+var benchmarkData struct {
+	active []bool
+	gpa    []int // gpa*100
+}
+
+func benchmarkStudent(id int) (bool, int) {
+	if benchmarkData.active == nil {
+		rng := rand.New(rand.NewSource(1))
+		for range benchmarkStudents {
+			benchmarkData.active = append(benchmarkData.active, rng.Intn(2) == 1)
+			benchmarkData.gpa = append(benchmarkData.gpa, rng.Intn(401))
+		}
+	}
+	return benchmarkData.active[id], benchmarkData.gpa[id]
+}
+
+func buildActiveMap() any {
+	index := map[bool]map[int]bool{false: {}, true: {}}
+	for id := range benchmarkStudents {
+		active, _ := benchmarkStudent(id)
+		index[active][id] = true
+	}
+	return index
+}
+
+func buildActiveBitmap() any {
+	index := map[bool]*Bitmap{false: New(), true: New()}
+	for id := range benchmarkStudents {
+		active, _ := benchmarkStudent(id)
+		index[active].Add(id)
+	}
+	return index
+}
+
+func buildGpaMap() any {
+	index := make(map[int]map[int]bool)
+	for id := range benchmarkStudents {
+		_, gpa := benchmarkStudent(id)
+		if index[gpa] == nil {
+			index[gpa] = make(map[int]bool)
+		}
+		index[gpa][id] = true
+	}
+	return index
+}
+
+func buildGpaBitmap() any {
+	index := make(map[int]*Bitmap)
+	for id := range benchmarkStudents {
+		_, gpa := benchmarkStudent(id)
+		if index[gpa] == nil {
+			index[gpa] = New()
+		}
+		index[gpa].Add(id)
+	}
+	return index
+}
+
+// BenchmarkIndexMemory reports the heap an index takes in MB.
+func BenchmarkIndexMemory(b *testing.B) {
+	benchmarkStudent(0)
+	for _, bench := range []struct {
+		name  string
+		build func() any
+	}{
+		{"active/map", buildActiveMap},
+		{"active/bitmap", buildActiveBitmap},
+		{"gpa/map", buildGpaMap},
+		{"gpa/bitmap", buildGpaBitmap},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var heap uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				index := bench.build()
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				runtime.KeepAlive(index)
+				heap = after.HeapAlloc - before.HeapAlloc
+			}
+			b.ReportMetric(float64(heap)/1e6, "MB")
+		})
+	}
+}
+
+func buildIndexes() *benchmarkIndexes {
+	return &benchmarkIndexes{
+		activeMap: buildActiveMap().(map[bool]map[int]bool),
+		gpaMap:    buildGpaMap().(map[int]map[int]bool),
+		active:    buildActiveBitmap().(map[bool]*Bitmap),
+		gpa:       buildGpaBitmap().(map[int]*Bitmap),
+	}
+}
+
+// BenchmarkGpaAndActive is the query gpa = x AND active.
+func BenchmarkGpaAndActive(b *testing.B) {
+	indexes := buildIndexes()
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result := make(map[int]bool)
+			for id := range indexes.gpaMap[i%401] {
+				if indexes.activeMap[true][id] {
+					result[id] = true
+				}
+			}
+		}
+	})
+	b.Run("bitmap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			And(indexes.gpa[i%401], indexes.active[true])
+		}
+	})
+}
+
+// BenchmarkNotGpa is the query NOT gpa = x over all students.
+func BenchmarkNotGpa(b *testing.B) {
+	indexes := buildIndexes()
+	all := New()
+	for id := range benchmarkStudents {
+		all.Add(id)
+	}
+
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result := make(map[int]bool)
+			excluded := indexes.gpaMap[i%401]
+			for id := range benchmarkStudents {
+				if !excluded[id] {
+					result[id] = true
+				}
+			}
+		}
+	})
+	b.Run("bitmap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			AndNot(all, indexes.gpa[i%401])
+		}
+	})
+}
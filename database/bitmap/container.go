@@ -0,0 +1,189 @@
+package bitmap
+
+import (
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+const setWords = 65536 / 64
+
+func (c *container) search(low uint16) (int, bool) {
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	return i, i < len(c.array) && c.array[i] == low
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.set != nil {
+		return c.set[low/64]&(1<<(low%64)) != 0
+	}
+	_, found := c.search(low)
+	return found
+}
+
+func (c *container) add(low uint16) {
+	if c.set != nil {
+		if c.set[low/64]&(1<<(low%64)) == 0 {
+			c.set[low/64] |= 1 << (low % 64)
+			c.n++
+		}
+		return
+	}
+
+	i, found := c.search(low)
+	if found {
+		return
+	}
+	c.array = slices.Insert(c.array, i, low)
+	c.n++
+	if c.n > arrayMax {
+		c.toSet()
+	}
+}
+
+func (c *container) remove(low uint16) {
+	if c.set != nil {
+		if c.set[low/64]&(1<<(low%64)) != 0 {
+			c.set[low/64] &^= 1 << (low % 64)
+			c.n--
+		}
+		// converting back a bit below the limit keeps a set that hovers
+		// around it from flipping on every change
+		if c.n < arrayMax/2 {
+			c.toArray()
+		}
+		return
+	}
+
+	if i, found := c.search(low); found {
+		c.array = slices.Delete(c.array, i, i+1)
+		c.n--
+	}
+}
+
+func (c *container) toSet() {
+	c.set = make([]uint64, setWords)
+	for _, low := range c.array {
+		c.set[low/64] |= 1 << (low % 64)
+	}
+	c.array = nil
+}
+
+func (c *container) toArray() {
+	array := make([]uint16, 0, c.n)
+	for w, word := range c.set {
+		for word != 0 {
+			array = append(array, uint16(w*64+bits.TrailingZeros64(word)))
+			word &= word - 1
+		}
+	}
+	c.array = array
+	c.set = nil
+}
+
+func (c *container) clone() *container {
+	return &container{array: slices.Clone(c.array), set: slices.Clone(c.set), n: c.n}
+}
+
+// fromSet makes a container from a bitset result, converting small ones to
+// an array.
+func fromSet(set []uint64) *container {
+	c := &container{set: set}
+	for _, word := range set {
+		c.n += bits.OnesCount64(word)
+	}
+	if c.n <= arrayMax {
+		c.toArray()
+	}
+	return c
+}
+
+func and(a, b *container) *container {
+	switch {
+	case a.set != nil && b.set != nil:
+		set := make([]uint64, setWords)
+		for w := range set {
+			set[w] = a.set[w] & b.set[w]
+		}
+		return fromSet(set)
+	case a.set != nil:
+		a, b = b, a
+	}
+
+	// a is an array
+	c := &container{}
+	for _, low := range a.array {
+		if b.contains(low) {
+			c.array = append(c.array, low)
+		}
+	}
+	c.n = len(c.array)
+	return c
+}
+
+func or(a, b *container) *container {
+	if a.set == nil && b.set == nil {
+		c := &container{array: make([]uint16, 0, len(a.array)+len(b.array))}
+		i, j := 0, 0
+		for i < len(a.array) || j < len(b.array) {
+			switch {
+			case j == len(b.array) || i < len(a.array) && a.array[i] < b.array[j]:
+				c.array = append(c.array, a.array[i])
+				i++
+			case i == len(a.array) || a.array[i] > b.array[j]:
+				c.array = append(c.array, b.array[j])
+				j++
+			default:
+				c.array = append(c.array, a.array[i])
+				i++
+				j++
+			}
+		}
+		c.n = len(c.array)
+		if c.n > arrayMax {
+			c.toSet()
+		}
+		return c
+	}
+
+	set := make([]uint64, setWords)
+	for _, src := range []*container{a, b} {
+		if src.set != nil {
+			for w := range set {
+				set[w] |= src.set[w]
+			}
+			continue
+		}
+		for _, low := range src.array {
+			set[low/64] |= 1 << (low % 64)
+		}
+	}
+	return fromSet(set)
+}
+
+func andNot(a, b *container) *container {
+	if a.set != nil && b.set != nil {
+		set := make([]uint64, setWords)
+		for w := range set {
+			set[w] = a.set[w] &^ b.set[w]
+		}
+		return fromSet(set)
+	}
+
+	if a.set != nil {
+		c := a.clone()
+		for _, low := range b.array {
+			c.remove(low)
+		}
+		return c
+	}
+
+	c := &container{}
+	for _, low := range a.array {
+		if !b.contains(low) {
+			c.array = append(c.array, low)
+		}
+	}
+	c.n = len(c.array)
+	return c
+}
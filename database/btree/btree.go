@@ -0,0 +1,537 @@
+// Package btree is a B+tree from int keys to int64 values stored in a paged
+// file, so the id index does not have to fit into memory or be rebuilt on
+// every start.
+package btree
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Tree maps student ids to record locations. A tree made by NewMemory keeps
+// its nodes only in memory. The tree is not safe for concurrent use.
+//
+// Deleting keys never merges nodes, so after many deletes the tree may have
+// half-empty leaves until it is rebuilt with BulkLoad.
+type Tree struct {
+	path     string
+	file     *os.File
+	journal  *os.File
+	hdr      header
+	capacity int
+
+	nodes      map[uint32]*list.Element
+	lru        *list.List // front is the most recently used node
+	dirtyCount int
+
+	inTxn         bool
+	txnPages      uint32 // pages at the start of the transaction
+	journaled     map[uint32]bool
+	journalSize   int64
+	journalSynced bool
+
+	scratch [PageSize]byte // page buffer for searchPage
+}
+
+func journalPath(path string) string {
+	return path + ".journal"
+}
+
+// NewMemory returns an empty tree that is never written to disk.
+func NewMemory() *Tree {
+	t := &Tree{}
+	t.init()
+	return t
+}
+
+func (t *Tree) init() {
+	t.nodes, t.lru = newCache()
+	t.dirtyCount = 0
+	t.hdr = header{root: 1, pages: 2}
+	t.cache(&node{page: 1, leaf: true})
+}
+
+// Open opens the tree file at path, creating an empty tree if it does not
+// exist, and rolls back a transaction left unfinished by a crash.
+func Open(path string) (*Tree, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(journalPath(path), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t := &Tree{path: path, file: file, journal: journal, capacity: DefaultCacheNodes}
+	t.nodes, t.lru = newCache()
+
+	if err := t.load(); err != nil {
+		file.Close()
+		journal.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tree) load() error {
+	if err := rollback(t.file, t.journal); err != nil {
+		return fmt.Errorf("error rolling back index journal: %w", err)
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return t.create()
+	}
+
+	raw := make([]byte, PageSize)
+	if _, err := t.file.ReadAt(raw, 0); err != nil {
+		return ErrCorrupt
+	}
+	t.hdr, err = decodeHeader(raw)
+	if err != nil {
+		return err
+	}
+	if info.Size() < int64(t.hdr.pages)*PageSize || t.hdr.root == 0 || t.hdr.root >= t.hdr.pages {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// create writes an empty tree: the header and an empty root leaf.
+func (t *Tree) create() error {
+	t.hdr = header{root: 1, pages: 2}
+	if _, err := t.file.WriteAt(encodeHeader(t.hdr), 0); err != nil {
+		return err
+	}
+	if _, err := t.file.WriteAt(encodeNode(&node{page: 1, leaf: true}), PageSize); err != nil {
+		return err
+	}
+	return t.file.Sync()
+}
+
+// Close commits the pending changes and closes the file.
+func (t *Tree) Close() error {
+	if t.file == nil {
+		return nil
+	}
+
+	err := t.Commit()
+	errs := []error{err, t.file.Close(), t.journal.Close()}
+	if err == nil {
+		errs = append(errs, os.Remove(journalPath(t.path)))
+	}
+	t.file = nil
+	return errors.Join(errs...)
+}
+
+// Reset removes every key.
+func (t *Tree) Reset() error {
+	if t.file == nil {
+		t.init()
+		return nil
+	}
+
+	t.nodes, t.lru = newCache()
+	t.dirtyCount = 0
+	t.inTxn = false
+	t.journaled = nil
+	if err := t.journal.Truncate(0); err != nil {
+		return err
+	}
+	if err := t.file.Truncate(0); err != nil {
+		return err
+	}
+	return t.create()
+}
+
+// Len returns the number of keys.
+func (t *Tree) Len() int {
+	return int(t.hdr.count)
+}
+
+// Meta returns the bytes stored with SetMeta, or zeros if the tree has
+// changed since.
+func (t *Tree) Meta() []byte {
+	return t.hdr.meta[:]
+}
+
+// SetMeta tags the current contents with up to 32 bytes of caller data, for
+// example what data file state the tree was built from. The tag is written
+// with the next commit and cleared by the next Put or Delete.
+func (t *Tree) SetMeta(meta []byte) error {
+	if len(meta) > metaSize {
+		return fmt.Errorf("index meta is %d bytes, limit is %d", len(meta), metaSize)
+	}
+	if err := t.begin(); err != nil {
+		return err
+	}
+	t.hdr.meta = [metaSize]byte{}
+	copy(t.hdr.meta[:], meta)
+	return nil
+}
+
+// childIndex is the child of an internal node whose subtree may hold key.
+func childIndex(n *node, key int64) int {
+	return sort.Search(len(n.keys), func(i int) bool { return n.keys[i] > key })
+}
+
+func (t *Tree) findLeaf(key int64) (*node, error) {
+	n, err := t.get(t.hdr.root)
+	for err == nil && !n.leaf {
+		n, err = t.get(n.children[childIndex(n, key)])
+	}
+	return n, err
+}
+
+// Get returns the value stored for key.
+func (t *Tree) Get(key int) (int64, bool, error) {
+	n, err := t.get(t.hdr.root)
+	for err == nil && !n.leaf {
+		pageNo := n.children[childIndex(n, int64(key))]
+		if _, cached := t.nodes[pageNo]; !cached && t.file != nil {
+			// leaves far outnumber the cache, searching the page in place is
+			// cheaper than decoding and caching it for one lookup
+			return t.searchPage(pageNo, int64(key))
+		}
+		n, err = t.get(pageNo)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	leaf := n
+
+	i := sort.Search(len(leaf.keys), func(i int) bool { return leaf.keys[i] >= int64(key) })
+	if i < len(leaf.keys) && leaf.keys[i] == int64(key) {
+		return leaf.vals[i], true, nil
+	}
+	return 0, false, nil
+}
+
+// Put stores value for key, replacing the previous one.
+func (t *Tree) Put(key int, value int64) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+
+	t.hdr.meta = [metaSize]byte{}
+
+	split, err := t.insert(t.hdr.root, int64(key), value)
+	if err != nil {
+		return err
+	}
+	if split != nil {
+		// the root was split, the tree grows by one level
+		root, err := t.alloc(false)
+		if err != nil {
+			return err
+		}
+		root.keys = []int64{split.key}
+		root.children = []uint32{t.hdr.root, split.page}
+		if err := t.touch(root); err != nil {
+			return err
+		}
+		t.hdr.root = root.page
+	}
+
+	return t.autoCommit()
+}
+
+type splitResult struct {
+	key  int64  // first key of the new right node
+	page uint32 // the new right node
+}
+
+func (t *Tree) insert(pageNo uint32, key, value int64) (*splitResult, error) {
+	n, err := t.get(pageNo)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.leaf {
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= key })
+		if i < len(n.keys) && n.keys[i] == key {
+			n.vals[i] = value
+			return nil, t.touch(n)
+		}
+
+		n.keys = insertAt(n.keys, i, key)
+		n.vals = insertAt(n.vals, i, value)
+		t.hdr.count++
+		if len(n.keys) <= maxLeafKeys {
+			return nil, t.touch(n)
+		}
+		return t.splitLeaf(n)
+	}
+
+	i := childIndex(n, key)
+	split, err := t.insert(n.children[i], key, value)
+	if err != nil || split == nil {
+		return nil, err
+	}
+
+	n.keys = insertAt(n.keys, i, split.key)
+	n.children = insertAt(n.children, i+1, split.page)
+	if len(n.keys) <= maxInnerKeys {
+		return nil, t.touch(n)
+	}
+	return t.splitInner(n)
+}
+
+func (t *Tree) splitLeaf(n *node) (*splitResult, error) {
+	right, err := t.alloc(true)
+	if err != nil {
+		return nil, err
+	}
+
+	mid := len(n.keys) / 2
+	right.keys = append([]int64(nil), n.keys[mid:]...)
+	right.vals = append([]int64(nil), n.vals[mid:]...)
+	right.next = n.next
+	n.keys = n.keys[:mid:mid]
+	n.vals = n.vals[:mid:mid]
+	n.next = right.page
+
+	if err := t.touch(right); err != nil {
+		return nil, err
+	}
+	if err := t.touch(n); err != nil {
+		return nil, err
+	}
+	return &splitResult{key: right.keys[0], page: right.page}, nil
+}
+
+func (t *Tree) splitInner(n *node) (*splitResult, error) {
+	right, err := t.alloc(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the middle key moves up to the parent
+	mid := len(n.keys) / 2
+	key := n.keys[mid]
+	right.keys = append([]int64(nil), n.keys[mid+1:]...)
+	right.children = append([]uint32(nil), n.children[mid+1:]...)
+	n.keys = n.keys[:mid:mid]
+	n.children = n.children[: mid+1 : mid+1]
+
+	if err := t.touch(right); err != nil {
+		return nil, err
+	}
+	if err := t.touch(n); err != nil {
+		return nil, err
+	}
+	return &splitResult{key: key, page: right.page}, nil
+}
+
+func insertAt[T any](s []T, i int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+// Delete removes key and reports whether it was there.
+func (t *Tree) Delete(key int) (bool, error) {
+	leaf, err := t.findLeaf(int64(key))
+	if err != nil {
+		return false, err
+	}
+
+	i := sort.Search(len(leaf.keys), func(i int) bool { return leaf.keys[i] >= int64(key) })
+	if i == len(leaf.keys) || leaf.keys[i] != int64(key) {
+		return false, nil
+	}
+
+	if err := t.begin(); err != nil {
+		return false, err
+	}
+	t.hdr.meta = [metaSize]byte{}
+	leaf.keys = append(leaf.keys[:i], leaf.keys[i+1:]...)
+	leaf.vals = append(leaf.vals[:i], leaf.vals[i+1:]...)
+	t.hdr.count--
+	if err := t.touch(leaf); err != nil {
+		return false, err
+	}
+
+	return true, t.autoCommit()
+}
+
+// autoCommit keeps the number of dirty pages, and so the memory used by the
+// cache, bounded.
+func (t *Tree) autoCommit() error {
+	if t.file == nil || t.dirtyCount < t.capacity/2 {
+		return nil
+	}
+	return t.Commit()
+}
+
+// Range calls fn for every key from..to in ascending order until fn returns
+// false. fn must not change the tree.
+func (t *Tree) Range(from, to int, fn func(key int, value int64) bool) error {
+	leaf, err := t.findLeaf(int64(from))
+	if err != nil {
+		return err
+	}
+
+	i := sort.Search(len(leaf.keys), func(i int) bool { return leaf.keys[i] >= int64(from) })
+	for {
+		for ; i < len(leaf.keys); i++ {
+			if leaf.keys[i] > int64(to) {
+				return nil
+			}
+			if !fn(int(leaf.keys[i]), leaf.vals[i]) {
+				return nil
+			}
+		}
+		if leaf.next == 0 {
+			return nil
+		}
+		leaf, err = t.get(leaf.next)
+		if err != nil {
+			return err
+		}
+		i = 0
+	}
+}
+
+// All iterates over every key in ascending order. An error stops the
+// iteration and is returned by the err function.
+func (t *Tree) All() (iter.Seq2[int, int64], func() error) {
+	var rangeErr error
+	seq := func(yield func(int, int64) bool) {
+		rangeErr = t.Range(minKey, maxKey, yield)
+	}
+	return seq, func() error { return rangeErr }
+}
+
+const (
+	minKey = -1 << 63
+	maxKey = 1<<63 - 1
+)
+
+// bulkLeafFill leaves some room in bulk loaded leaves, so that the first
+// inserts after loading do not split every leaf.
+const bulkLeafFill = maxLeafKeys * 9 / 10
+
+// BulkLoad builds a tree at path from entries sorted by key, writing every
+// page once instead of inserting keys one by one. The tree is written to a
+// temporary file and renamed over path, replacing any previous tree.
+func BulkLoad(path string, entries iter.Seq2[int, int64]) (*Tree, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "btree-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := tmp.Chmod(0644); err != nil {
+		return nil, err
+	}
+
+	hdr, err := bulkWrite(tmp, entries)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.WriteAt(encodeHeader(hdr), 0); err != nil {
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	// a journal of the old tree must not be applied to the new one
+	if err := os.Remove(journalPath(path)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	return Open(path)
+}
+
+// bulkWrite writes the leaves one after another, then every level of
+// internal nodes above them, and returns the header for the result.
+func bulkWrite(file *os.File, entries iter.Seq2[int, int64]) (header, error) {
+	hdr := header{pages: 1}
+	write := func(n *node) error {
+		n.page = hdr.pages
+		hdr.pages++
+		_, err := file.WriteAt(encodeNode(n), int64(n.page)*PageSize)
+		return err
+	}
+
+	type child struct {
+		first int64
+		page  uint32
+	}
+	var level []child
+
+	leaf := &node{leaf: true}
+	var writeErr error
+	first, last := true, int64(0)
+	for key, value := range entries {
+		if !first && int64(key) <= last {
+			return hdr, fmt.Errorf("bulk load keys are not sorted: %d after %d", key, last)
+		}
+		first, last = false, int64(key)
+
+		if len(leaf.keys) == bulkLeafFill {
+			// leaves are written in order, the next one takes the next page
+			leaf.next = hdr.pages + 1
+			level = append(level, child{leaf.keys[0], hdr.pages})
+			if writeErr = write(leaf); writeErr != nil {
+				break
+			}
+			leaf = &node{leaf: true}
+		}
+		leaf.keys = append(leaf.keys, int64(key))
+		leaf.vals = append(leaf.vals, value)
+		hdr.count++
+	}
+	if writeErr != nil {
+		return hdr, fmt.Errorf("error writing index file: %w", writeErr)
+	}
+	// the last leaf is written even when empty, an empty tree is one leaf
+	var leafFirst int64
+	if len(leaf.keys) > 0 {
+		leafFirst = leaf.keys[0]
+	}
+	level = append(level, child{leafFirst, hdr.pages})
+	if err := write(leaf); err != nil {
+		return hdr, fmt.Errorf("error writing index file: %w", err)
+	}
+
+	for len(level) > 1 {
+		var parents []child
+		for start := 0; start < len(level); start += maxInnerKeys + 1 {
+			group := level[start:min(start+maxInnerKeys+1, len(level))]
+			n := &node{children: []uint32{group[0].page}}
+			for _, c := range group[1:] {
+				n.keys = append(n.keys, c.first)
+				n.children = append(n.children, c.page)
+			}
+			parents = append(parents, child{group[0].first, hdr.pages})
+			if err := write(n); err != nil {
+				return hdr, fmt.Errorf("error writing index file: %w", err)
+			}
+		}
+		level = parents
+	}
+
+	hdr.root = level[0].page
+	return hdr, nil
+}
@@ -0,0 +1,166 @@
+package btree
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestPutGetDelete(t *testing.T) {
+	tree, err := Open(filepath.Join(t.TempDir(), "input.btree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	ids := rand.New(rand.NewSource(1)).Perm(10000)
+	for _, id := range ids {
+		if err := tree.Put(id, int64(id)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id := 0; id < 10000; id += 2 {
+		if ok, err := tree.Delete(id); !ok || err != nil {
+			t.Fatalf("Delete(%d) = %v, %v", id, ok, err)
+		}
+	}
+
+	if tree.Len() != 5000 {
+		t.Fatalf("Len = %d, want 5000", tree.Len())
+	}
+	for _, id := range ids {
+		value, ok, err := tree.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (id%2 == 1) || ok && value != int64(id)*10 {
+			t.Fatalf("Get(%d) = %d, %v", id, value, ok)
+		}
+	}
+
+	next := 1
+	err = tree.Range(0, 9999, func(key int, value int64) bool {
+		if key != next {
+			t.Fatalf("Range returned %d, want %d", key, next)
+		}
+		next += 2
+		return true
+	})
+	if err != nil || next != 10001 {
+		t.Fatalf("Range stopped at %d: %v", next, err)
+	}
+}
+
+// TestDeleteEverything empties a tree of several levels. Nodes are not merged,
+// so the empty leaves stay in the file and must still be skipped and reused.
+func TestDeleteEverything(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.btree")
+	tree, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { tree.Close() }()
+
+	for id := range 20000 {
+		if err := tree.Put(id, int64(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id := range 20000 {
+		if ok, err := tree.Delete(id); !ok || err != nil {
+			t.Fatalf("Delete(%d) = %v, %v", id, ok, err)
+		}
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tree, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.Len() != 0 {
+		t.Fatalf("Len = %d after deleting every key", tree.Len())
+	}
+	err = tree.Range(0, 20000, func(key int, value int64) bool {
+		t.Fatalf("Range returned %d from an empty tree", key)
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{15000, 7, 19999} {
+		if err := tree.Put(id, int64(id)*2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []int
+	err = tree.Range(0, 20000, func(key int, value int64) bool {
+		if value != int64(key)*2 {
+			t.Fatalf("key %d points at %d", key, value)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil || len(keys) != 3 || keys[0] != 7 || keys[1] != 15000 || keys[2] != 19999 {
+		t.Fatalf("Range after refilling = %v, %v", keys, err)
+	}
+}
+
+// bulkTree is a tree file of n keys, key i pointing at offset 100*i.
+func bulkTree(t testing.TB, n int) *Tree {
+	t.Helper()
+	tree, err := BulkLoad(filepath.Join(t.TempDir(), "input.btree"), func(yield func(int, int64) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i, int64(i)*100) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tree.Close() })
+	return tree
+}
+
+func TestBulkLoad(t *testing.T) {
+	tree := bulkTree(t, 100000)
+	if tree.Len() != 100000 {
+		t.Fatalf("Len = %d, want 100000", tree.Len())
+	}
+	for _, id := range []int{0, 1, 4999, 99999} {
+		if value, ok, _ := tree.Get(id); !ok || value != int64(id)*100 {
+			t.Fatalf("Get(%d) = %d, %v", id, value, ok)
+		}
+	}
+	if _, ok, _ := tree.Get(100000); ok {
+		t.Fatal("Get found a key that was not loaded")
+	}
+	// a bulk loaded tree takes inserts between its keys
+	if err := tree.Put(-1, 7); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := tree.Get(-1); !ok || value != 7 {
+		t.Fatalf("Get(-1) = %d, %v", value, ok)
+	}
+}
+
+// BenchmarkGet looks up random keys of a tree file of 1 000 000 ids, of which
+// only the cached pages are in memory:
+//
+//	go test -run '^$' -bench Get ./database/btree
+func BenchmarkGet(b *testing.B) {
+	const n = 1_000_000
+	tree := bulkTree(b, n)
+	ids := rand.New(rand.NewSource(1)).Perm(n)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		id := ids[i%n]
+		if _, ok, err := tree.Get(id); !ok || err != nil {
+			b.Fatalf("Get(%d) = %v, %v", id, ok, err)
+		}
+	}
+}
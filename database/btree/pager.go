@@ -0,0 +1,412 @@
+package btree
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+// The tree file is made of PageSize pages, page 0 is the header.
+//
+// Header (little endian):
+//
+//	magic "BTRE" | format uint32 | root uint32 | pages uint32 | count uint64 | meta [metaSize]byte | crc32
+//
+// Node page:
+//
+//	kind byte | pad byte | key count uint16 | next leaf uint32 | entries...
+//
+// A leaf holds key count x (key int64 | value int64), an internal node holds
+// child uint32 followed by key count x (key int64 | child uint32).
+//
+// Changes are made in the page cache. Before a page of the file is overwritten
+// for the first time in a transaction its original image goes to the journal,
+// and the journal reaches the disk before the page does. Commit writes the
+// header last and empties the journal; Open copies the journaled images back
+// when the previous process died in the middle, so a node split is either on
+// disk completely or not at all.
+const (
+	PageSize = 4096
+
+	headerMagic  = "BTRE"
+	headerFormat = 1
+	metaSize     = 32
+	headerSize   = 4 + 4 + 4 + 4 + 8 + metaSize
+
+	nodeHeaderSize = 8
+	maxLeafKeys    = (PageSize - nodeHeaderSize) / 16
+	maxInnerKeys   = (PageSize - nodeHeaderSize - 4) / 12
+
+	kindLeaf  = 1
+	kindInner = 2
+
+	journalMagic = "BJRN"
+	journalEntry = 4 + PageSize + 4
+
+	// DefaultCacheNodes is how many nodes stay decoded in memory, 4 MiB of pages.
+	DefaultCacheNodes = 1024
+)
+
+var ErrCorrupt = errors.New("index file is corrupt")
+
+type node struct {
+	page     uint32
+	leaf     bool
+	keys     []int64
+	vals     []int64  // leaf: record locations
+	children []uint32 // internal node: len(keys)+1 children
+	next     uint32   // leaf: right sibling, 0 for the last leaf
+	dirty    bool
+}
+
+type header struct {
+	root  uint32
+	pages uint32
+	count uint64
+	meta  [metaSize]byte
+}
+
+// pager part of Tree: the page cache, the journal and the header.
+
+func (t *Tree) get(pageNo uint32) (*node, error) {
+	if elem, ok := t.nodes[pageNo]; ok {
+		t.lru.MoveToFront(elem)
+		return elem.Value.(*node), nil
+	}
+	if t.file == nil {
+		return nil, fmt.Errorf("%w: page %d does not exist", ErrCorrupt, pageNo)
+	}
+
+	raw := make([]byte, PageSize)
+	if _, err := t.file.ReadAt(raw, int64(pageNo)*PageSize); err != nil {
+		return nil, fmt.Errorf("error reading index page %d: %w", pageNo, err)
+	}
+	n, err := decodeNode(pageNo, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.cache(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// cache puts n into the page cache, evicting the least recently used nodes.
+func (t *Tree) cache(n *node) error {
+	if elem, ok := t.nodes[n.page]; ok {
+		elem.Value = n
+		t.lru.MoveToFront(elem)
+		return nil
+	}
+
+	for t.file != nil && t.lru.Len() >= t.capacity {
+		elem := t.lru.Back()
+		old := elem.Value.(*node)
+		if old.dirty {
+			if err := t.writeNode(old); err != nil {
+				return err
+			}
+		}
+		t.lru.Remove(elem)
+		delete(t.nodes, old.page)
+	}
+
+	t.nodes[n.page] = t.lru.PushFront(n)
+	return nil
+}
+
+// touch is called after n has been changed. It journals the original page,
+// marks n dirty and makes sure the changed node is the cached one.
+func (t *Tree) touch(n *node) error {
+	if t.file != nil && n.page < t.txnPages && !t.journaled[n.page] {
+		raw := make([]byte, PageSize)
+		if _, err := t.file.ReadAt(raw, int64(n.page)*PageSize); err != nil {
+			return fmt.Errorf("error reading index page %d: %w", n.page, err)
+		}
+		if err := t.journalPage(n.page, raw); err != nil {
+			return err
+		}
+	}
+
+	if !n.dirty {
+		n.dirty = true
+		t.dirtyCount++
+	}
+	return t.cache(n)
+}
+
+// alloc returns a new empty node at the end of the file.
+func (t *Tree) alloc(leaf bool) (*node, error) {
+	n := &node{page: t.hdr.pages, leaf: leaf}
+	t.hdr.pages++
+	return n, t.touch(n)
+}
+
+// begin starts a transaction before the first change after a commit.
+func (t *Tree) begin() error {
+	if t.file == nil || t.inTxn {
+		return nil
+	}
+
+	if err := t.journal.Truncate(0); err != nil {
+		return fmt.Errorf("error writing index journal: %w", err)
+	}
+	t.journalSize = 0
+
+	start := make([]byte, 8)
+	copy(start, journalMagic)
+	binary.LittleEndian.PutUint32(start[4:], t.hdr.pages)
+	if _, err := t.journal.WriteAt(start, 0); err != nil {
+		return fmt.Errorf("error writing index journal: %w", err)
+	}
+	t.journalSize = int64(len(start))
+
+	t.inTxn = true
+	t.txnPages = t.hdr.pages
+	t.journaled = make(map[uint32]bool)
+
+	raw := make([]byte, PageSize)
+	if _, err := t.file.ReadAt(raw, 0); err != nil {
+		return fmt.Errorf("error reading index header: %w", err)
+	}
+	return t.journalPage(0, raw)
+}
+
+func (t *Tree) journalPage(pageNo uint32, raw []byte) error {
+	entry := make([]byte, journalEntry)
+	binary.LittleEndian.PutUint32(entry, pageNo)
+	copy(entry[4:], raw)
+	binary.LittleEndian.PutUint32(entry[4+PageSize:], crc32.ChecksumIEEE(entry[:4+PageSize]))
+
+	if _, err := t.journal.WriteAt(entry, t.journalSize); err != nil {
+		return fmt.Errorf("error writing index journal: %w", err)
+	}
+	t.journalSize += journalEntry
+	t.journaled[pageNo] = true
+	t.journalSynced = false
+	return nil
+}
+
+// writePage overwrites a page of the file, the journal goes to disk first.
+func (t *Tree) writePage(pageNo uint32, raw []byte) error {
+	if !t.journalSynced {
+		if err := t.journal.Sync(); err != nil {
+			return fmt.Errorf("error writing index journal: %w", err)
+		}
+		t.journalSynced = true
+	}
+	if _, err := t.file.WriteAt(raw, int64(pageNo)*PageSize); err != nil {
+		return fmt.Errorf("error writing index page %d: %w", pageNo, err)
+	}
+	return nil
+}
+
+func (t *Tree) writeNode(n *node) error {
+	if err := t.writePage(n.page, encodeNode(n)); err != nil {
+		return err
+	}
+	n.dirty = false
+	t.dirtyCount--
+	return nil
+}
+
+// Commit writes every change since the previous commit to disk. The tree
+// also commits by itself when too many pages are dirty.
+func (t *Tree) Commit() error {
+	if t.file == nil || !t.inTxn {
+		return nil
+	}
+
+	for elem := t.lru.Back(); elem != nil; elem = elem.Prev() {
+		if n := elem.Value.(*node); n.dirty {
+			if err := t.writeNode(n); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.writePage(0, encodeHeader(t.hdr)); err != nil {
+		return err
+	}
+	if err := t.file.Sync(); err != nil {
+		return fmt.Errorf("error writing index file: %w", err)
+	}
+
+	// an empty journal marks the transaction as finished
+	if err := t.journal.Truncate(0); err != nil {
+		return fmt.Errorf("error writing index journal: %w", err)
+	}
+	t.journalSize = 0
+	t.inTxn = false
+	t.journaled = nil
+	return nil
+}
+
+// rollback restores the pages saved in a journal left by an unfinished
+// transaction. Entries torn by the crash were never followed by a write to
+// the file, so they are ignored.
+func rollback(file, journal *os.File) error {
+	raw, err := io.ReadAll(io.NewSectionReader(journal, 0, 1<<62))
+	if err != nil {
+		return err
+	}
+	if len(raw) < 8 || string(raw[:4]) != journalMagic {
+		return journal.Truncate(0)
+	}
+	pages := binary.LittleEndian.Uint32(raw[4:])
+
+	for entry := raw[8:]; len(entry) >= journalEntry; entry = entry[journalEntry:] {
+		sum := binary.LittleEndian.Uint32(entry[4+PageSize:])
+		if crc32.ChecksumIEEE(entry[:4+PageSize]) != sum {
+			break
+		}
+		pageNo := binary.LittleEndian.Uint32(entry)
+		if _, err := file.WriteAt(entry[4:4+PageSize], int64(pageNo)*PageSize); err != nil {
+			return err
+		}
+	}
+
+	if err := file.Truncate(int64(pages) * PageSize); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return journal.Truncate(0)
+}
+
+// searchPage looks key up in a page that is not cached. Internal nodes are
+// decoded and cached as usual, a leaf is searched in the raw page.
+func (t *Tree) searchPage(pageNo uint32, key int64) (int64, bool, error) {
+	raw := t.scratch[:]
+	if _, err := t.file.ReadAt(raw, int64(pageNo)*PageSize); err != nil {
+		return 0, false, fmt.Errorf("error reading index page %d: %w", pageNo, err)
+	}
+	if raw[0] != kindLeaf {
+		n, err := t.get(pageNo)
+		for err == nil && !n.leaf {
+			n, err = t.get(n.children[childIndex(n, key)])
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= key })
+		if i < len(n.keys) && n.keys[i] == key {
+			return n.vals[i], true, nil
+		}
+		return 0, false, nil
+	}
+
+	count := int(binary.LittleEndian.Uint16(raw[2:]))
+	if count > maxLeafKeys {
+		return 0, false, fmt.Errorf("%w: page %d", ErrCorrupt, pageNo)
+	}
+	keyAt := func(i int) int64 {
+		return int64(binary.LittleEndian.Uint64(raw[nodeHeaderSize+i*16:]))
+	}
+	i := sort.Search(count, func(i int) bool { return keyAt(i) >= key })
+	if i < count && keyAt(i) == key {
+		return int64(binary.LittleEndian.Uint64(raw[nodeHeaderSize+i*16+8:])), true, nil
+	}
+	return 0, false, nil
+}
+
+func encodeHeader(h header) []byte {
+	raw := make([]byte, PageSize)
+	copy(raw, headerMagic)
+	binary.LittleEndian.PutUint32(raw[4:], headerFormat)
+	binary.LittleEndian.PutUint32(raw[8:], h.root)
+	binary.LittleEndian.PutUint32(raw[12:], h.pages)
+	binary.LittleEndian.PutUint64(raw[16:], h.count)
+	copy(raw[24:], h.meta[:])
+	binary.LittleEndian.PutUint32(raw[headerSize:], crc32.ChecksumIEEE(raw[:headerSize]))
+	return raw
+}
+
+func decodeHeader(raw []byte) (header, error) {
+	var h header
+	if string(raw[:4]) != headerMagic || binary.LittleEndian.Uint32(raw[4:]) != headerFormat {
+		return h, ErrCorrupt
+	}
+	if crc32.ChecksumIEEE(raw[:headerSize]) != binary.LittleEndian.Uint32(raw[headerSize:]) {
+		return h, ErrCorrupt
+	}
+	h.root = binary.LittleEndian.Uint32(raw[8:])
+	h.pages = binary.LittleEndian.Uint32(raw[12:])
+	h.count = binary.LittleEndian.Uint64(raw[16:])
+	copy(h.meta[:], raw[24:])
+	return h, nil
+}
+
+func encodeNode(n *node) []byte {
+	raw := make([]byte, PageSize)
+	binary.LittleEndian.PutUint16(raw[2:], uint16(len(n.keys)))
+
+	if n.leaf {
+		raw[0] = kindLeaf
+		binary.LittleEndian.PutUint32(raw[4:], n.next)
+		pos := nodeHeaderSize
+		for i, key := range n.keys {
+			binary.LittleEndian.PutUint64(raw[pos:], uint64(key))
+			binary.LittleEndian.PutUint64(raw[pos+8:], uint64(n.vals[i]))
+			pos += 16
+		}
+		return raw
+	}
+
+	raw[0] = kindInner
+	binary.LittleEndian.PutUint32(raw[nodeHeaderSize:], n.children[0])
+	pos := nodeHeaderSize + 4
+	for i, key := range n.keys {
+		binary.LittleEndian.PutUint64(raw[pos:], uint64(key))
+		binary.LittleEndian.PutUint32(raw[pos+8:], n.children[i+1])
+		pos += 12
+	}
+	return raw
+}
+
+func decodeNode(pageNo uint32, raw []byte) (*node, error) {
+	count := int(binary.LittleEndian.Uint16(raw[2:]))
+	n := &node{page: pageNo, keys: make([]int64, count)}
+
+	switch raw[0] {
+	case kindLeaf:
+		if count > maxLeafKeys {
+			return nil, fmt.Errorf("%w: page %d", ErrCorrupt, pageNo)
+		}
+		n.leaf = true
+		n.next = binary.LittleEndian.Uint32(raw[4:])
+		n.vals = make([]int64, count)
+		pos := nodeHeaderSize
+		for i := range count {
+			n.keys[i] = int64(binary.LittleEndian.Uint64(raw[pos:]))
+			n.vals[i] = int64(binary.LittleEndian.Uint64(raw[pos+8:]))
+			pos += 16
+		}
+	case kindInner:
+		if count > maxInnerKeys {
+			return nil, fmt.Errorf("%w: page %d", ErrCorrupt, pageNo)
+		}
+		n.children = make([]uint32, count+1)
+		n.children[0] = binary.LittleEndian.Uint32(raw[nodeHeaderSize:])
+		pos := nodeHeaderSize + 4
+		for i := range count {
+			n.keys[i] = int64(binary.LittleEndian.Uint64(raw[pos:]))
+			n.children[i+1] = binary.LittleEndian.Uint32(raw[pos+8:])
+			pos += 12
+		}
+	default:
+		return nil, fmt.Errorf("%w: page %d", ErrCorrupt, pageNo)
+	}
+
+	return n, nil
+}
+
+func newCache() (map[uint32]*list.Element, *list.List) {
+	return make(map[uint32]*list.Element), list.New()
+}
@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/kgugunava/database/models"
+)
+
+// Binary record layout (little endian):
+//
+//	header:  magic byte 0xA5 | payload length uint32 | payload crc32 uint32
+//	payload: id int64 | version uint32 | gpa float64 bits | flags byte | name bytes
+//
+// Bit 0 of flags is Active, bit 1 marks a tombstone.
+//
+// The name takes the rest of the payload, so it needs no length of its own.
+const (
+	binaryMagic       = 0xA5
+	binaryHeaderSize  = 9
+	binaryFixedFields = 8 + 4 + 8 + 1
+	binaryMaxPayload  = 16 * 1024 * 1024
+
+	flagActive  = 1 << 0
+	flagDeleted = 1 << 1
+)
+
+var (
+	errBadMagic    = errors.New("bad record magic")
+	errBadChecksum = errors.New("record checksum mismatch")
+	errBadLength   = errors.New("bad record length")
+)
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string {
+	return "binary"
+}
+
+func (binaryCodec) Extension() string {
+	return ".sdb"
+}
+
+func (binaryCodec) Encode(student models.Student) ([]byte, error) {
+	payloadLen := binaryFixedFields + len(student.Name)
+	if payloadLen > binaryMaxPayload {
+		return nil, errBadLength
+	}
+
+	buf := make([]byte, binaryHeaderSize+payloadLen)
+	payload := buf[binaryHeaderSize:]
+
+	binary.LittleEndian.PutUint64(payload[0:], uint64(int64(student.Id)))
+	binary.LittleEndian.PutUint32(payload[8:], uint32(student.Version))
+	binary.LittleEndian.PutUint64(payload[12:], math.Float64bits(student.Gpa))
+	if student.Active {
+		payload[20] |= flagActive
+	}
+	if student.Deleted {
+		payload[20] |= flagDeleted
+	}
+	copy(payload[binaryFixedFields:], student.Name)
+
+	buf[0] = binaryMagic
+	binary.LittleEndian.PutUint32(buf[1:], uint32(payloadLen))
+	binary.LittleEndian.PutUint32(buf[5:], crc32.ChecksumIEEE(payload))
+
+	return buf, nil
+}
+
+func (binaryCodec) NewReader(r io.Reader, offset int64) Reader {
+	return &binaryReader{r: r, offset: offset}
+}
+
+type binaryReader struct {
+	r       io.Reader
+	offset  int64
+	header  [binaryHeaderSize]byte
+	payload []byte
+}
+
+// Next fails with a plain error on a broken or truncated record: without a
+// valid length there is no way to find where the next one starts. A bad
+// checksum only affects the current record and is reported as a *DecodeError.
+func (br *binaryReader) Next() (models.Student, int64, error) {
+	var student models.Student
+	start := br.offset
+
+	n, err := io.ReadFull(br.r, br.header[:])
+	if err == io.EOF {
+		return student, start, io.EOF
+	}
+	if err != nil {
+		return student, start, fmt.Errorf("record at offset %d: %w", start, io.ErrUnexpectedEOF)
+	}
+	br.offset += int64(n)
+
+	if br.header[0] != binaryMagic {
+		return student, start, fmt.Errorf("record at offset %d: %w", start, errBadMagic)
+	}
+	length := binary.LittleEndian.Uint32(br.header[1:])
+	if length < binaryFixedFields || length > binaryMaxPayload {
+		return student, start, fmt.Errorf("record at offset %d: %w", start, errBadLength)
+	}
+
+	if cap(br.payload) < int(length) {
+		br.payload = make([]byte, length)
+	}
+	payload := br.payload[:length]
+	n, err = io.ReadFull(br.r, payload)
+	br.offset += int64(n)
+	if err != nil {
+		return student, start, fmt.Errorf("record at offset %d: %w", start, io.ErrUnexpectedEOF)
+	}
+
+	student, err = decodePayload(br.header[:], payload, start)
+	return student, start, err
+}
+
+func (binaryCodec) decodeAt(data []byte, offset int64) (models.Student, error) {
+	var student models.Student
+
+	header := data[offset:]
+	if len(header) < binaryHeaderSize {
+		return student, fmt.Errorf("record at offset %d: %w", offset, io.ErrUnexpectedEOF)
+	}
+	if header[0] != binaryMagic {
+		return student, fmt.Errorf("record at offset %d: %w", offset, errBadMagic)
+	}
+	length := binary.LittleEndian.Uint32(header[1:])
+	if length < binaryFixedFields || length > binaryMaxPayload {
+		return student, fmt.Errorf("record at offset %d: %w", offset, errBadLength)
+	}
+	payload := header[binaryHeaderSize:]
+	if len(payload) < int(length) {
+		return student, fmt.Errorf("record at offset %d: %w", offset, io.ErrUnexpectedEOF)
+	}
+
+	return decodePayload(header, payload[:length], offset)
+}
+
+// decodePayload checks the payload against the crc in header and decodes it.
+func decodePayload(header, payload []byte, offset int64) (models.Student, error) {
+	var student models.Student
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[5:]) {
+		return student, &DecodeError{Offset: offset, Err: errBadChecksum}
+	}
+
+	student.Id = int(int64(binary.LittleEndian.Uint64(payload[0:])))
+	student.Version = int(binary.LittleEndian.Uint32(payload[8:]))
+	student.Gpa = math.Float64frombits(binary.LittleEndian.Uint64(payload[12:]))
+	student.Active = payload[20]&flagActive != 0
+	student.Deleted = payload[20]&flagDeleted != 0
+	student.Name = string(payload[binaryFixedFields:])
+
+	return student, nil
+}
@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/kgugunava/database/models"
+)
+
+// Codec turns students into records of a data file and back. Offsets returned
+// by readers are the positions the records start at, which is what IdIndex
+// stores, so the indexes do not depend on the format.
+type Codec interface {
+	Name() string
+	Extension() string
+	Encode(student models.Student) ([]byte, error)
+	NewReader(r io.Reader, offset int64) Reader
+}
+
+// Reader returns io.EOF after the last record. A record that was read but
+// cannot be decoded is reported as *DecodeError and the reader is already past
+// it, so callers may skip it and go on. Any other error ends the reading.
+type Reader interface {
+	Next() (models.Student, int64, error)
+}
+
+type DecodeError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("record at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	JSONL  Codec = jsonlCodec{}
+	Binary Codec = binaryCodec{}
+)
+
+// ForPath picks the codec by the file extension, JSONL is the default.
+func ForPath(path string) Codec {
+	if filepath.Ext(path) == Binary.Extension() {
+		return Binary
+	}
+	return JSONL
+}
+
+// ByName returns the codec whose Name is name.
+func ByName(name string) (Codec, bool) {
+	for _, c := range []Codec{JSONL, Binary} {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// sliceDecoder is implemented by codecs that can decode a record in place,
+// without a reader copying it first.
+type sliceDecoder interface {
+	decodeAt(data []byte, offset int64) (models.Student, error)
+}
+
+// DecodeAt decodes the record starting at offset in data, which holds the
+// whole file, e.g. a memory mapping of it.
+func DecodeAt(c Codec, data []byte, offset int64) (models.Student, error) {
+	if offset < 0 || offset >= int64(len(data)) {
+		return models.Student{}, io.ErrUnexpectedEOF
+	}
+	if d, ok := c.(sliceDecoder); ok {
+		return d.decodeAt(data, offset)
+	}
+	return ReadAt(c, bytes.NewReader(data), offset)
+}
+
+// ReadAt decodes the single record starting at offset.
+func ReadAt(c Codec, r io.ReaderAt, offset int64) (models.Student, error) {
+	reader := c.NewReader(io.NewSectionReader(r, offset, 1<<62), offset)
+	student, _, err := reader.Next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return student, err
+}
@@ -0,0 +1,194 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+)
+
+var codecs = []Codec{JSONL, Binary}
+
+var codecStudents = []models.Student{
+	{Id: 1, Name: "Anna", Gpa: 4.5, Active: true, Version: 1},
+	{Id: -7, Name: "", Gpa: 0, Version: 3},
+	{Id: 1 << 40, Name: "Ёжик \"в\" тумане\n\\", Gpa: 3.14159, Active: true, Version: 12},
+	{Id: 2, Version: 2, Deleted: true},
+}
+
+// encodeAll returns the records of students one after another and the
+// offset every one of them starts at.
+func encodeAll(t *testing.T, c Codec, students []models.Student) ([]byte, []int64) {
+	t.Helper()
+	var data []byte
+	var offsets []int64
+	for _, student := range students {
+		record, err := c.Encode(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, int64(len(data)))
+		data = append(data, record...)
+	}
+	return data, offsets
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, offsets := encodeAll(t, c, codecStudents)
+
+			// records can start anywhere in a file, the reader counts from there
+			reader := c.NewReader(bytes.NewReader(data), 100)
+			for i, want := range codecStudents {
+				student, offset, err := reader.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if student != want || offset != offsets[i]+100 {
+					t.Fatalf("record %d is %+v at %d, want %+v at %d", i, student, offset, want, offsets[i]+100)
+				}
+			}
+			if _, _, err := reader.Next(); err != io.EOF {
+				t.Fatalf("after the last record: %v", err)
+			}
+
+			for i, want := range codecStudents {
+				if student, err := ReadAt(c, bytes.NewReader(data), offsets[i]); err != nil || student != want {
+					t.Errorf("ReadAt(%d) = %+v, %v", offsets[i], student, err)
+				}
+				if student, err := DecodeAt(c, data, offsets[i]); err != nil || student != want {
+					t.Errorf("DecodeAt(%d) = %+v, %v", offsets[i], student, err)
+				}
+			}
+			if _, err := DecodeAt(c, data, int64(len(data))); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("DecodeAt past the end: %v", err)
+			}
+		})
+	}
+}
+
+func TestCodecCorruptedRecord(t *testing.T) {
+	// every case breaks the second record
+	withoutSecond := slices.Delete(slices.Clone(codecStudents), 1, 2)
+
+	for _, test := range []struct {
+		name    string
+		codec   Codec
+		corrupt func(data []byte, offsets []int64) []byte
+		// the records read and skipped before the reader stops; without a
+		// valid length the binary reader cannot find the next record, so it
+		// stops with an error instead of io.EOF
+		read    []models.Student
+		skipped int
+		eof     bool
+	}{
+		{"jsonl broken line", JSONL, func(data []byte, offsets []int64) []byte {
+			data[offsets[1]] = 'x'
+			return data
+		}, withoutSecond, 1, true},
+		{"jsonl truncated", JSONL, func(data []byte, offsets []int64) []byte {
+			return data[:offsets[2]+5]
+		}, codecStudents[:2], 1, true},
+		{"binary checksum", Binary, func(data []byte, offsets []int64) []byte {
+			data[offsets[1]+binaryHeaderSize] ^= 0xFF
+			return data
+		}, withoutSecond, 1, true},
+		{"binary magic", Binary, func(data []byte, offsets []int64) []byte {
+			data[offsets[1]] = 0
+			return data
+		}, codecStudents[:1], 0, false},
+		{"binary length", Binary, func(data []byte, offsets []int64) []byte {
+			data[offsets[1]+4] = 0xFF
+			return data
+		}, codecStudents[:1], 0, false},
+		{"binary truncated", Binary, func(data []byte, offsets []int64) []byte {
+			return data[:offsets[1]+binaryHeaderSize+3]
+		}, codecStudents[:1], 0, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, offsets := encodeAll(t, test.codec, codecStudents)
+			data = test.corrupt(data, offsets)
+
+			reader := test.codec.NewReader(bytes.NewReader(data), 0)
+			var read []models.Student
+			var skipped int
+			var err error
+			for {
+				var student models.Student
+				student, _, err = reader.Next()
+				var decodeErr *DecodeError
+				if errors.As(err, &decodeErr) {
+					skipped++
+					continue
+				}
+				if err != nil {
+					break
+				}
+				read = append(read, student)
+			}
+
+			if !slices.Equal(read, test.read) || skipped != test.skipped || (err == io.EOF) != test.eof {
+				t.Fatalf("read %v, skipped %d, stopped with %v", read, skipped, err)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	jsonlPath := filepath.Join(dir, "input.jsonl")
+	data, _ := encodeAll(t, JSONL, codecStudents)
+	// a line that cannot be decoded is dropped
+	data = append(data, "{broken\n"...)
+	if err := os.WriteFile(jsonlPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sdbPath := filepath.Join(dir, "input.sdb")
+	if count, err := Convert(jsonlPath, sdbPath); err != nil || count != len(codecStudents) {
+		t.Fatalf("Convert to binary = %d, %v", count, err)
+	}
+	backPath := filepath.Join(dir, "back.jsonl")
+	if count, err := Convert(sdbPath, backPath); err != nil || count != len(codecStudents) {
+		t.Fatalf("Convert back = %d, %v", count, err)
+	}
+
+	back, err := os.ReadFile(backPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := encodeAll(t, JSONL, codecStudents); !bytes.Equal(back, want) {
+		t.Fatalf("converted back:\n%s\nwant:\n%s", back, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Fatalf("temporary files left in %v", entries)
+	}
+}
+
+func TestConvertSameFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.jsonl")
+	data, _ := encodeAll(t, JSONL, codecStudents)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.jsonl")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip(err)
+	}
+
+	for _, dst := range []string{path, filepath.Join(dir, ".", "input.jsonl"), link} {
+		if _, err := Convert(path, dst); !errors.Is(err, ErrSameFile) {
+			t.Errorf("Convert(%s, %s) = %v", path, dst, err)
+		}
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Fatal("the source file was changed")
+	}
+}
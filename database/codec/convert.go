@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrSameFile = errors.New("source and destination are the same file")
+
+// Convert rewrites every record of srcPath into dstPath, the formats are taken
+// from the file extensions. Old versions of edited students are kept, so the
+// indexes built from the new file are the same. Records that cannot be
+// decoded are dropped, just as LoadIndex ignores them. The records go to a
+// temporary file that replaces dstPath only once all of them are written.
+func Convert(srcPath, dstPath string) (int, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, fmt.Errorf("error opening source file: %w", err)
+	}
+	defer src.Close()
+
+	if dstInfo, err := os.Stat(dstPath); err == nil {
+		srcInfo, err := src.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("error opening source file: %w", err)
+		}
+		if os.SameFile(srcInfo, dstInfo) {
+			return 0, fmt.Errorf("%w: %s", ErrSameFile, dstPath)
+		}
+	}
+
+	dst, err := os.CreateTemp(filepath.Dir(dstPath), filepath.Base(dstPath)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("error creating destination file: %w", err)
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+	if err := dst.Chmod(0644); err != nil {
+		return 0, fmt.Errorf("error creating destination file: %w", err)
+	}
+
+	from, to := ForPath(srcPath), ForPath(dstPath)
+	reader := from.NewReader(src, 0)
+	w := bufio.NewWriter(dst)
+	count := 0
+
+	for {
+		student, _, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("error reading source file: %w", err)
+		}
+
+		data, err := to.Encode(student)
+		if err != nil {
+			return count, fmt.Errorf("error encoding student %d: %w", student.Id, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return count, fmt.Errorf("error writing destination file: %w", err)
+		}
+		count++
+	}
+
+	if err := w.Flush(); err != nil {
+		return count, fmt.Errorf("error writing destination file: %w", err)
+	}
+	if err := dst.Sync(); err != nil {
+		return count, fmt.Errorf("error writing destination file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return count, fmt.Errorf("error writing destination file: %w", err)
+	}
+	if err := os.Rename(dst.Name(), dstPath); err != nil {
+		return count, fmt.Errorf("error replacing destination file: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/scanner"
+)
+
+// jsonlCodec is the original format: one JSON object per line.
+type jsonlCodec struct{}
+
+func (jsonlCodec) Name() string {
+	return "jsonl"
+}
+
+func (jsonlCodec) Extension() string {
+	return ".jsonl"
+}
+
+func (jsonlCodec) Encode(student models.Student) ([]byte, error) {
+	data, err := json.Marshal(student)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (jsonlCodec) NewReader(r io.Reader, offset int64) Reader {
+	return &jsonlReader{lines: scanner.NewRecordReader(r, offset)}
+}
+
+type jsonlReader struct {
+	lines *scanner.RecordReader
+}
+
+func (r *jsonlReader) Next() (models.Student, int64, error) {
+	for {
+		var student models.Student
+
+		line, offset, err := r.lines.Next()
+		if err != nil {
+			return student, offset, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		student, err = decodeLine(line, offset)
+		return student, offset, err
+	}
+}
+
+func (jsonlCodec) decodeAt(data []byte, offset int64) (models.Student, error) {
+	line := data[offset:]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	return decodeLine(line, offset)
+}
+
+func decodeLine(line []byte, offset int64) (models.Student, error) {
+	var student models.Student
+	if err := json.Unmarshal(line, &student); err != nil {
+		return student, &DecodeError{Offset: offset, Err: err}
+	}
+	// records written before versioning was introduced
+	if student.Version == 0 {
+		student.Version = 1
+	}
+	return student, nil
+}
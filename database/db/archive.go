@@ -0,0 +1,236 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+)
+
+// Backup archive layout (little endian):
+//
+//	header:  magic "SDBACKUP" | version uint16 | records uint64 | source size int64 |
+//	         source crc32 uint32 | created unix nanoseconds int64 | codec name length byte | codec name
+//	body:    gzip stream of the records in the codec
+//	trailer: crc32 of the header and the body uint32 | "SDBKEND\n"
+//
+// The record count is filled in after the body, so the records are written
+// in one pass.
+const (
+	ArchiveExtension = ".bkp"
+
+	archiveVersion     = 1
+	archiveFixedHeader = 8 + 2 + 8 + 8 + 4 + 8 + 1
+	archiveRecordsAt   = 8 + 2
+	archiveTrailerSize = 4 + 8
+)
+
+var (
+	archiveMagic = []byte("SDBACKUP")
+	archiveEnd   = []byte("SDBKEND\n")
+
+	ErrBackupDamaged = errors.New("backup archive is damaged or truncated")
+)
+
+// ArchiveHeader is what a backup archive tells about itself. SourceSize and
+// SourceChecksum are the size and CRC-32 of the data file the backup was
+// made from, when it is the append-only file.
+type ArchiveHeader struct {
+	Version        int
+	Codec          string
+	Records        int
+	SourceSize     int64
+	SourceChecksum uint32
+	Created        time.Time
+}
+
+func (h ArchiveHeader) encode() []byte {
+	buf := append([]byte(nil), archiveMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(h.Version))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Records))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.SourceSize))
+	buf = binary.LittleEndian.AppendUint32(buf, h.SourceChecksum)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Created.UnixNano()))
+	buf = append(buf, byte(len(h.Codec)))
+	return append(buf, h.Codec...)
+}
+
+// writeArchive writes a backup archive at path with the records fill passes
+// to write and returns how many there were. If anything fails, no archive is
+// left behind.
+func writeArchive(path string, header ArchiveHeader, fill func(write func(models.Student) error) error) (int, error) {
+	c, ok := codec.ByName(header.Codec)
+	if !ok {
+		return 0, fmt.Errorf("unknown backup format %q", header.Codec)
+	}
+	header.Version = archiveVersion
+	header.Records = 0
+
+	// never over an existing backup
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("error creating backup file: %w", err)
+	}
+	records, err := writeArchiveFile(file, header, c, fill)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return records, nil
+}
+
+func writeArchiveFile(file *os.File, header ArchiveHeader, c codec.Codec, fill func(write func(models.Student) error) error) (int, error) {
+	out := bufio.NewWriter(file)
+	_, err := out.Write(header.encode())
+	if err != nil {
+		return 0, fmt.Errorf("error writing to backup file: %w", err)
+	}
+
+	zip := gzip.NewWriter(out)
+	records := 0
+	err = fill(func(student models.Student) error {
+		data, err := c.Encode(student)
+		if err != nil {
+			return fmt.Errorf("error encoding backup record: %w", err)
+		}
+		_, err = zip.Write(data)
+		if err != nil {
+			return fmt.Errorf("error writing to backup file: %w", err)
+		}
+		records++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = zip.Close()
+	if err == nil {
+		err = out.Flush()
+	}
+	if err == nil {
+		_, err = file.WriteAt(binary.LittleEndian.AppendUint64(nil, uint64(records)), archiveRecordsAt)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error writing to backup file: %w", err)
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	checksum, err := checksumSection(file, 0, size, 0)
+	if err != nil {
+		return 0, fmt.Errorf("error reading back backup file: %w", err)
+	}
+	_, err = file.Write(append(binary.LittleEndian.AppendUint32(nil, checksum), archiveEnd...))
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error writing to backup file: %w", err)
+	}
+	return records, nil
+}
+
+// readArchive checks the trailer checksum of a backup archive and then calls
+// fn for each of its records. A damaged archive fails with ErrBackupDamaged,
+// before fn is called when the checksum does not match.
+func readArchive(path string, fn func(models.Student) error) (ArchiveHeader, error) {
+	var header ArchiveHeader
+
+	file, err := os.Open(path)
+	if err != nil {
+		return header, fmt.Errorf("error opening backup file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return header, fmt.Errorf("error opening backup file: %w", err)
+	}
+
+	size := info.Size() - archiveTrailerSize
+	if size < archiveFixedHeader {
+		return header, fmt.Errorf("%w: %s is too short", ErrBackupDamaged, path)
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	_, err = file.ReadAt(trailer, size)
+	if err != nil {
+		return header, fmt.Errorf("error reading backup file: %w", err)
+	}
+	if !bytes.Equal(trailer[4:], archiveEnd) {
+		return header, fmt.Errorf("%w: %s has no trailer", ErrBackupDamaged, path)
+	}
+	checksum, err := checksumSection(file, 0, size, 0)
+	if err != nil {
+		return header, fmt.Errorf("error reading backup file: %w", err)
+	}
+	if checksum != binary.LittleEndian.Uint32(trailer) {
+		return header, fmt.Errorf("%w: checksum of %s does not match", ErrBackupDamaged, path)
+	}
+
+	fixed := make([]byte, archiveFixedHeader)
+	_, err = file.ReadAt(fixed, 0)
+	if err != nil {
+		return header, fmt.Errorf("error reading backup file: %w", err)
+	}
+	if !bytes.Equal(fixed[:8], archiveMagic) {
+		return header, fmt.Errorf("%s is not a backup archive", path)
+	}
+	header = ArchiveHeader{
+		Version:        int(binary.LittleEndian.Uint16(fixed[8:])),
+		Records:        int(binary.LittleEndian.Uint64(fixed[10:])),
+		SourceSize:     int64(binary.LittleEndian.Uint64(fixed[18:])),
+		SourceChecksum: binary.LittleEndian.Uint32(fixed[26:]),
+		Created:        time.Unix(0, int64(binary.LittleEndian.Uint64(fixed[30:]))),
+	}
+	if header.Version > archiveVersion {
+		return header, fmt.Errorf("backup archive version %d is newer than this program reads", header.Version)
+	}
+	name := make([]byte, fixed[38])
+	_, err = file.ReadAt(name, archiveFixedHeader)
+	if err != nil {
+		return header, fmt.Errorf("%w: %s", ErrBackupDamaged, path)
+	}
+	header.Codec = string(name)
+	c, ok := codec.ByName(header.Codec)
+	if !ok {
+		return header, fmt.Errorf("unknown backup format %q", header.Codec)
+	}
+
+	bodyStart := int64(archiveFixedHeader + len(name))
+	zip, err := gzip.NewReader(io.NewSectionReader(file, bodyStart, size-bodyStart))
+	if err != nil {
+		return header, fmt.Errorf("%w: %s: %v", ErrBackupDamaged, path, err)
+	}
+	reader := c.NewReader(zip, 0)
+	records := 0
+	for {
+		student, _, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return header, fmt.Errorf("%w: %s: %v", ErrBackupDamaged, path, err)
+		}
+		records++
+		err = fn(student)
+		if err != nil {
+			return header, err
+		}
+	}
+	if records != header.Records {
+		return header, fmt.Errorf("%w: %s has %d records instead of %d", ErrBackupDamaged, path, records, header.Records)
+	}
+	return header, nil
+}
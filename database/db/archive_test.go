@@ -0,0 +1,105 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup"+ArchiveExtension)
+	students := []models.Student{{Id: 1, Name: "one", Version: 1}, {Id: 2, Name: "two", Gpa: 4.5, Version: 3}}
+	header := ArchiveHeader{Codec: "binary", SourceSize: 123, SourceChecksum: 456}
+
+	records, err := writeArchive(path, header, func(write func(models.Student) error) error {
+		for _, student := range students {
+			if err := write(student); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || records != 2 {
+		t.Fatalf("writeArchive = %d, %v", records, err)
+	}
+	if _, err := writeArchive(path, header, func(func(models.Student) error) error { return nil }); err == nil {
+		t.Fatal("writeArchive replaced an existing archive")
+	}
+
+	var got []models.Student
+	read, err := readArchive(path, func(student models.Student) error {
+		got = append(got, student)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Records != 2 || read.Codec != "binary" || read.SourceSize != 123 || read.SourceChecksum != 456 {
+		t.Fatalf("header = %+v", read)
+	}
+	if !slices.Equal(got, students) {
+		t.Fatalf("read %v, want %v", got, students)
+	}
+}
+
+func TestRestoreDamagedArchive(t *testing.T) {
+	db, backupDir := openBackupTest(t)
+	if err := db.CreateBackup(backupDir); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 100, 120)
+
+	archives, _ := filepath.Glob(filepath.Join(backupDir, "*"+ArchiveExtension))
+	if len(archives) != 1 {
+		t.Fatalf("archives = %v", archives)
+	}
+	archive, err := os.ReadFile(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(db.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	students := selectAll(t, db)
+
+	flip := func(at int) []byte {
+		damaged := slices.Clone(archive)
+		damaged[at] ^= 0x10
+		return damaged
+	}
+	for name, damaged := range map[string][]byte{
+		"truncated trailer": archive[:len(archive)-3],
+		"truncated body":    archive[:len(archive)/2],
+		"header only":       archive[:archiveFixedHeader],
+		"empty":             nil,
+		"record count":      flip(archiveRecordsAt),
+		"body":              flip(len(archive) / 2),
+		"checksum":          flip(len(archive) - archiveTrailerSize),
+		"end marker":        flip(len(archive) - 1),
+	} {
+		if err := os.WriteFile(archives[0], damaged, 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := db.RestoreFromBackup(archives[0])
+		if !errors.Is(err, ErrBackupDamaged) {
+			t.Fatalf("%s: RestoreFromBackup = %v", name, err)
+		}
+
+		after, err := os.ReadFile(db.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(after, data) {
+			t.Fatalf("%s: the data file was changed", name)
+		}
+		if !slices.Equal(selectAll(t, db), students) {
+			t.Fatalf("%s: the indexes were changed", name)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/storage"
+)
+
+type BackupKind string
+
+const (
+	BackupFull        BackupKind = "full"
+	BackupIncremental BackupKind = "incremental"
+)
+
+// BackupManifest describes a chain of backups: a full backup and the
+// incremental ones made after it, in order. It is kept next to the full
+// backup, named like it with manifestSuffix.
+type BackupManifest struct {
+	Format  string        `json:"format"` // codec of the data file and the backups
+	Backups []BackupEntry `json:"backups"`
+}
+
+// BackupEntry is one file of a chain. From and To are the part of the data
+// file it covers: a full backup has the live records of [0, To), an
+// incremental one every record written in [From, To), new versions of edited
+// students and tombstones included. Checksum is the CRC-32 of the data file
+// up to To, by which the next incremental backup knows the file was only
+// appended to in between.
+type BackupEntry struct {
+	File     string     `json:"file"` // name in the backup directory
+	Kind     BackupKind `json:"kind"`
+	Created  time.Time  `json:"created"`
+	From     int64      `json:"from"`
+	To       int64      `json:"to"`
+	Records  int        `json:"records"`
+	Checksum uint32     `json:"checksum"`
+}
+
+const manifestSuffix = ".manifest.json"
+
+// backupTimeFormat names backups by their creation time. Nanoseconds keep
+// backups made within one second apart, and the names still sort by time.
+const backupTimeFormat = "20060102_150405.000000000"
+
+var (
+	ErrNoFullBackup      = errors.New("no full backup to continue, create a full backup first")
+	ErrBackupChainBroken = errors.New("data file was rewritten since the last backup, create a full backup")
+)
+
+// checksumRange continues crc over the bytes [from, to) of the file at path.
+// A file shorter than to is ErrBackupChainBroken.
+func checksumRange(path string, from, to int64, crc uint32) (uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return checksumSection(file, from, to, crc)
+}
+
+// checksumSection is checksumRange for a file that is already open.
+func checksumSection(r io.ReaderAt, from, to int64, crc uint32) (uint32, error) {
+	section := io.NewSectionReader(r, from, to-from)
+	buf := make([]byte, 256*1024)
+	var read int64
+	for {
+		n, err := section.Read(buf)
+		crc = crc32.Update(crc, crc32.IEEETable, buf[:n])
+		read += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if read < to-from {
+		return 0, ErrBackupChainBroken
+	}
+	return crc, nil
+}
+
+func manifestPath(backupPath string) string {
+	return strings.TrimSuffix(backupPath, filepath.Ext(backupPath)) + manifestSuffix
+}
+
+func loadManifest(path string) (*BackupManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup manifest: %w", err)
+	}
+	var manifest BackupManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup manifest %s: %w", path, err)
+	}
+	if len(manifest.Backups) == 0 || manifest.Backups[0].Kind != BackupFull {
+		return nil, fmt.Errorf("backup manifest %s does not start with a full backup", path)
+	}
+	return &manifest, nil
+}
+
+// saveManifest replaces the manifest at once, so a failed write leaves the
+// old chain readable.
+func saveManifest(path string, manifest *BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing backup manifest: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// manifests returns the manifests of a backup directory, oldest first, which
+// the timestamps in their names give.
+func manifests(backupDir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(backupDir, "backup_*"+manifestSuffix))
+	slices.Sort(paths)
+	return paths, err
+}
+
+// CreateIncrementalBackup continues the newest chain of backupDir with the
+// records written to the data file since its last backup. It needs the
+// append-only data file, whose records stay where they are, and fails with
+// ErrBackupChainBroken when the file was rewritten in between, for example
+// by a restore.
+func (db *Db) CreateIncrementalBackup(backupDir string) error {
+	file, ok := db.Recorder.Storage.(*storage.File)
+	if !ok {
+		return errors.New("incremental backups need the append-only data file")
+	}
+
+	paths, err := manifests(backupDir)
+	if err != nil {
+		return fmt.Errorf("error reading backup directory: %w", err)
+	}
+	if len(paths) == 0 {
+		return ErrNoFullBackup
+	}
+	path := paths[len(paths)-1]
+	manifest, err := loadManifest(path)
+	if err != nil {
+		return err
+	}
+	last := manifest.Backups[len(manifest.Backups)-1]
+
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return fmt.Errorf("error reading DB file: %w", err)
+	}
+	size := info.Size()
+	if manifest.Format != file.Codec.Name() || size < last.To {
+		return ErrBackupChainBroken
+	}
+	checksum, err := checksumRange(file.Path, 0, last.To, 0)
+	if err != nil {
+		return fmt.Errorf("error reading DB file: %w", err)
+	}
+	if checksum != last.Checksum {
+		return ErrBackupChainBroken
+	}
+	checksum, err = checksumRange(file.Path, last.To, size, checksum)
+	if err != nil {
+		return fmt.Errorf("error reading DB file: %w", err)
+	}
+
+	timestamp := time.Now()
+	entry := BackupEntry{
+		File:     fmt.Sprintf("backup_%s_incr%d%s", timestamp.Format(backupTimeFormat), len(manifest.Backups), ArchiveExtension),
+		Kind:     BackupIncremental,
+		Created:  timestamp,
+		From:     last.To,
+		To:       size,
+		Checksum: checksum,
+	}
+	backupPath := filepath.Join(backupDir, entry.File)
+
+	header := ArchiveHeader{Codec: file.Codec.Name(), SourceSize: size, SourceChecksum: checksum, Created: timestamp}
+	entry.Records, err = writeArchive(backupPath, header, func(write func(models.Student) error) error {
+		return file.IterateRange(last.To, size, func(offset int64, student models.Student) error {
+			return write(student)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error creating incremental backup: %w", err)
+	}
+
+	manifest.Backups = append(manifest.Backups, entry)
+	err = saveManifest(path, manifest)
+	if err != nil {
+		os.Remove(backupPath)
+		return err
+	}
+
+	fmt.Printf("Incremental backup created: %s\n", backupPath)
+	return nil
+}
+
+// backupChain returns the backups to replay to restore backupPath, with
+// their paths: the whole chain of a manifest, the chain up to backupPath
+// when a manifest lists it, or backupPath alone for a backup made before
+// manifests, whose record count is not known then.
+func backupChain(backupPath string) ([]BackupEntry, bool, error) {
+	dir := filepath.Dir(backupPath)
+	withPaths := func(entries []BackupEntry) []BackupEntry {
+		entries = slices.Clone(entries)
+		for i := range entries {
+			entries[i].File = filepath.Join(dir, entries[i].File)
+		}
+		return entries
+	}
+
+	if strings.HasSuffix(backupPath, manifestSuffix) {
+		manifest, err := loadManifest(backupPath)
+		if err != nil {
+			return nil, false, err
+		}
+		return withPaths(manifest.Backups), true, nil
+	}
+
+	paths, err := manifests(dir)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading backup directory: %w", err)
+	}
+	for _, path := range paths {
+		manifest, err := loadManifest(path)
+		if err != nil {
+			continue
+		}
+		for i, entry := range manifest.Backups {
+			if entry.File == filepath.Base(backupPath) {
+				return withPaths(manifest.Backups[:i+1]), true, nil
+			}
+		}
+	}
+	return []BackupEntry{{File: backupPath, Kind: BackupFull}}, false, nil
+}
+
+// replayBackups reads a chain of backups in order, the last record of a
+// student winning and a tombstone removing it, and returns the live
+// students in the order they first appear. Nothing is written, so a chain
+// that cannot be read fails before the data file is touched. When counted,
+// a file with fewer or more records than the manifest says is an error.
+func replayBackups(chain []BackupEntry, counted bool) ([]models.Student, error) {
+	var students []models.Student
+	live := make(map[int]int) // id - position in students
+
+	apply := func(student models.Student) error {
+		i, exists := live[student.Id]
+		switch {
+		case student.Deleted && exists:
+			students[i].Deleted = true
+			delete(live, student.Id)
+		case student.Deleted:
+		case exists:
+			students[i] = student
+		default:
+			live[student.Id] = len(students)
+			students = append(students, student)
+		}
+		return nil
+	}
+
+	for _, entry := range chain {
+		records, err := replayBackupFile(entry.File, apply)
+		if err != nil {
+			return nil, err
+		}
+		if counted && records != entry.Records {
+			return nil, fmt.Errorf("backup file %s has %d records instead of %d, it is damaged or truncated", entry.File, records, entry.Records)
+		}
+	}
+
+	return slices.DeleteFunc(students, func(student models.Student) bool { return student.Deleted }), nil
+}
+
+// replayBackupFile calls apply for every record of a backup archive, or of a
+// plain backup made before archives, whose damaged records cannot be told
+// from the end of the file and are skipped.
+func replayBackupFile(path string, apply func(models.Student) error) (int, error) {
+	if filepath.Ext(path) == ArchiveExtension {
+		header, err := readArchive(path, apply)
+		return header.Records, err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening backup file: %w", err)
+	}
+	defer src.Close()
+
+	records := 0
+	reader := codec.ForPath(path).NewReader(src, 0)
+	for {
+		student, _, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		var decodeErr *codec.DecodeError
+		if errors.As(err, &decodeErr) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading backup file %s: %w", path, err)
+		}
+		records++
+		apply(student)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+)
+
+// openBackupTest opens a database of students [0, 100) and returns it with
+// an empty backup directory.
+func openBackupTest(t *testing.T) (*Db, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db := newTestDb(t, filepath.Join(dir, "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	addStudents(t, db, 0, 100)
+
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.Mkdir(backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return db, backupDir
+}
+
+func selectAll(t *testing.T, db *Db) []models.Student {
+	t.Helper()
+	students, err := db.Select(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return students
+}
+
+func TestRestoreBackupChain(t *testing.T) {
+	db, backupDir := openBackupTest(t)
+	if err := db.CreateBackup(backupDir); err != nil {
+		t.Fatal(err)
+	}
+
+	student, err := db.Recorder.FindById(5, db.IdIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := *student
+	edited.Name = "edited"
+	err = db.Recorder.EditRecord(db.Recorder.MakeNewRecord(edited), student.Version, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Recorder.DeleteRecordById(7, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 100, 150)
+	if err := db.CreateIncrementalBackup(backupDir); err != nil {
+		t.Fatal(err)
+	}
+	want := selectAll(t, db)
+
+	// not in any backup
+	addStudents(t, db, 150, 200)
+
+	paths, err := manifests(backupDir)
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("manifests = %v, %v", paths, err)
+	}
+	if err := db.RestoreFromBackup(paths[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := selectAll(t, db); !slices.Equal(got, want) {
+		t.Fatalf("restored %d students, want %d", len(got), len(want))
+	}
+}
+
+func TestBackupNamesAreUnique(t *testing.T) {
+	db, backupDir := openBackupTest(t)
+	for range 3 {
+		if err := db.CreateBackup(backupDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CreateIncrementalBackup(backupDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := manifests(backupDir)
+	if err != nil || len(paths) != 3 {
+		t.Fatalf("manifests = %v, %v", paths, err)
+	}
+	archives, _ := filepath.Glob(filepath.Join(backupDir, "*"+ArchiveExtension))
+	if len(archives) != 6 {
+		t.Fatalf("%d archives, want 6", len(archives))
+	}
+	// the newest chain is continued
+	manifest, err := loadManifest(paths[2])
+	if err != nil || len(manifest.Backups) != 2 {
+		t.Fatalf("newest manifest = %+v, %v", manifest, err)
+	}
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/recorder"
+)
+
+func TestAddNewRecordsFromList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	db := newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 10)
+
+	var records []models.Record
+	for _, id := range []int{10, 5, 11, 10, 12} {
+		records = append(records, db.Recorder.MakeNewRecord(models.Student{Id: id, Name: "new", Gpa: 3}))
+	}
+	result, err := db.Recorder.AddNewRecordsFromList(records, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 3 || len(result.Failed) != 2 {
+		t.Fatalf("added %d, failed %v", result.Added, result.Failed)
+	}
+	for i, row := range []int{1, 3} {
+		if result.Failed[i].Row != row || !errors.Is(&result.Failed[i], recorder.ErrDuplicateId) {
+			t.Fatalf("failed row %d is %v", i, result.Failed[i])
+		}
+	}
+	if named := db.Recorder.Indexes.Lookup("name", "new"); len(named) != 3 || db.RecordInfo.Len() != 13 {
+		t.Fatalf("indexes have %d new students of %d", len(named), db.RecordInfo.Len())
+	}
+
+	// the records are in the data file as well
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = newTestDb(t, path)
+	if err := db.Open(true); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if student, err := db.Recorder.FindById(12, db.IdIndex); err != nil || student.Name != "new" {
+		t.Fatalf("FindById(12) = %v, %v", student, err)
+	}
+}
+
+// BenchmarkAddRecords adds 1 000 000 students to an empty database in one
+// bulk load and one by one:
+//
+//	go test -run '^$' -bench AddRecords -benchtime 1x ./database/db
+func BenchmarkAddRecords(b *testing.B) {
+	for _, ext := range formatExtensions {
+		for _, bulk := range []bool{true, false} {
+			name := ext[1:] + "/bulk"
+			if !bulk {
+				name = ext[1:] + "/one-by-one"
+			}
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					db := newTestDb(b, filepath.Join(b.TempDir(), "input"+ext))
+					if err := db.Open(false); err != nil {
+						b.Fatal(err)
+					}
+					records := make([]models.Record, formatStudents)
+					for id := range records {
+						records[id] = db.Recorder.MakeNewRecord(models.Student{Id: id, Name: "student", Gpa: float64(id%50) / 10, Active: id%3 == 0})
+					}
+					b.StartTimer()
+
+					if bulk {
+						_, err := db.Recorder.AddNewRecordsFromList(records, db.IdIndex, db.RecordInfo)
+						if err != nil {
+							b.Fatal(err)
+						}
+					} else {
+						for _, record := range records {
+							err := db.Recorder.AddNewRecord(record, db.IdIndex, db.RecordInfo)
+							if err != nil {
+								b.Fatal(err)
+							}
+						}
+					}
+
+					b.StopTimer()
+					db.Close()
+					b.StartTimer()
+				}
+			})
+		}
+	}
+}
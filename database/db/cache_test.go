@@ -0,0 +1,111 @@
+package db
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/storage"
+)
+
+func TestCacheForgetsEditedStudent(t *testing.T) {
+	db := newTestDb(t, filepath.Join(t.TempDir(), "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addStudents(t, db, 0, 10)
+
+	for range 2 {
+		if _, err := db.Recorder.FindById(3, db.IdIndex); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if db.Recorder.Cache.Hits != 1 || db.Recorder.Cache.Misses != 1 {
+		t.Fatalf("hits %d, misses %d, want 1 and 1", db.Recorder.Cache.Hits, db.Recorder.Cache.Misses)
+	}
+
+	student, _ := db.Recorder.FindById(3, db.IdIndex)
+	edited := *student
+	edited.Name = "edited"
+	err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(edited), student.Version, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	student, _ = db.Recorder.FindById(3, db.IdIndex)
+	if student.Name != "edited" {
+		t.Fatalf("FindById after an edit returned %q", student.Name)
+	}
+}
+
+// reopeningFile opens the data file on every read, as storage.File did before
+// it kept one descriptor open.
+type reopeningFile struct {
+	*storage.File
+}
+
+func (f reopeningFile) ReadAt(loc int64) (models.Student, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return models.Student{}, err
+	}
+	defer file.Close()
+	return codec.ReadAt(f.Codec, file, loc)
+}
+
+// hotIds returns n ids of a database of students, nine of ten out of the
+// first hot ones.
+func hotIds(n, students, hot int) []int {
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]int, n)
+	for i := range ids {
+		if rng.Intn(10) < 9 {
+			ids[i] = rng.Intn(hot)
+		} else {
+			ids[i] = rng.Intn(students)
+		}
+	}
+	return ids
+}
+
+// BenchmarkFindByIdCache looks up students of a JSONL database of 1 000 000,
+// either nine of ten out of 5000 hot ids or all at random:
+//
+//	go test -run '^$' -bench FindByIdCache -benchtime 200000x ./database/db
+func BenchmarkFindByIdCache(b *testing.B) {
+	for _, bench := range []struct {
+		name   string
+		ids    []int
+		cache  bool
+		reopen bool
+	}{
+		{"hot/no-cache", hotIds(1_000_000, formatStudents, 5000), false, false},
+		{"hot/cache", hotIds(1_000_000, formatStudents, 5000), true, false},
+		{"random/reopen-no-cache", rand.New(rand.NewSource(1)).Perm(formatStudents), false, true},
+		{"random/cache", rand.New(rand.NewSource(1)).Perm(formatStudents), true, false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			db, _ := openFormat(b, ".jsonl", formatStudents)
+			if !bench.cache {
+				db.Recorder.Cache = nil
+			}
+			if bench.reopen {
+				db.Recorder.Storage = reopeningFile{db.Recorder.Storage.(*storage.File)}
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := db.Recorder.FindById(bench.ids[i%len(bench.ids)], db.IdIndex); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if bench.cache {
+				cache := db.Recorder.Cache
+				b.ReportMetric(100*float64(cache.Hits)/float64(cache.Hits+cache.Misses), "%hits")
+			}
+		})
+	}
+}
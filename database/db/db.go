@@ -1,162 +1,326 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"time"
 	"os"
-	"bufio"
-	"encoding/json"
-	"io"
+	"log"
+	"path/filepath"
+	"runtime"
 
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/codec"
 	"github.com/kgugunava/database/scanner"
 	"github.com/kgugunava/database/recorder"
 	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/storage"
 )
 
 type Db struct {
 	Scanner *scanner.Scanner
 	Recorder *recorder.Recorder
 	FilePath string
-	IdIndex map[int]int64 // id - offset
-	NameIndex map[string]map[int]bool
-	GpaIndex map[float64]map[int]bool
-	ActiveIndex map[bool]map[int]bool // map[int]bool = id: true
-	RecordInfo map[int]models.RecordInfo
+	IdIndex *btree.Tree // id - offset, the other indexes are in Recorder.Indexes
+	RecordInfo *models.RecordTable
+	ReadOnly bool
+	Mmap bool // read the append-only data file through a memory mapping
+	lockFile *os.File
+	loaded bool // the indexes match the data file, so Close may save them
 }
 
-func (db *Db) CreateBackup(backupDir string) error {
-    dbPath := db.FilePath
+func NewDb(filePath string, scanner *scanner.Scanner, recorder *recorder.Recorder) *Db {
+	return &Db{
+		Scanner:    scanner,
+		Recorder:   recorder,
+		FilePath:   filePath,
+		IdIndex:    btree.NewMemory(),
+		RecordInfo: models.NewRecordTable(0),
+	}
+}
 
-    if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-        return fmt.Errorf("DB file does not exist: %s", dbPath)
+// Open locks the data file against other processes, opens the storage backend
+// chosen by storage.Open unless the Recorder already has one, and loads the
+// indexes. In read-only mode no lock is taken and every write through the
+// Recorder fails, so a second instance can still browse a database in use.
+func (db *Db) Open(readOnly bool) error {
+    if !readOnly {
+        lockFile, err := acquireLock(db.FilePath)
+        if err != nil {
+            return err
+        }
+        db.lockFile = lockFile
     }
 
-    timestamp := time.Now().Format("20060102_150405")
-    backupPath := fmt.Sprintf("%s/backup_%s.jsonl", backupDir, timestamp)
+    db.ReadOnly = readOnly
+    db.Recorder.ReadOnly = readOnly
 
-    src, err := os.Open(dbPath)
+    err := db.loadIndexDefinitions()
     if err != nil {
-        return fmt.Errorf("error opening DB file: %w", err)
+        db.Close()
+        return err
+    }
+
+    if db.Recorder.Storage == nil {
+        st, err := storage.Open(db.FilePath, readOnly)
+        if err != nil {
+            db.Close()
+            return fmt.Errorf("error opening storage: %w", err)
+        }
+        if file, ok := st.(*storage.File); ok {
+            file.Mmap = db.Mmap
+        }
+        db.Recorder.Storage = st
     }
-    defer src.Close()
 
-    dst, err := os.Create(backupPath)
+    err = db.loadIndexes()
+    var tooLarge *scanner.RecordTooLargeError
+    if errors.As(err, &tooLarge) {
+        // the remaining records are indexed, the oversized ones are only reported
+        log.Printf("some records were skipped while loading indexes: %v", err)
+        err = nil
+    }
+    if err != nil && !os.IsNotExist(err) {
+        db.Close()
+        return fmt.Errorf("error loading indexes: %w", err)
+    }
+
+    db.loaded = true
+    return nil
+}
+
+// loadIndexes starts from the index snapshot when it is still valid and only
+// parses the records appended after it, otherwise it rebuilds from scratch.
+// Snapshots are validated by the size and checksum of the part of the file
+// they cover, so only the append-only file storage can use them.
+func (db *Db) loadIndexes() error {
+    file, ok := db.Recorder.Storage.(*storage.File)
+    if !ok {
+        return db.LoadIndex()
+    }
+
+    covered, err := db.loadSnapshot()
     if err != nil {
-        return fmt.Errorf("error creating backup file: %w", err)
+        return db.LoadIndex()
     }
-    defer dst.Close()
 
-    scanner := bufio.NewScanner(src)
-    for scanner.Scan() {
-        line := scanner.Text()
-        if line == "" {
-            continue
+    return file.IterateFrom(covered, db.applyRecord)
+}
+
+// Close saves the index snapshot for the next start, closes the id index and
+// the storage and releases the lock. Indexes that were not loaded completely,
+// as when Open fails, are not saved: the snapshot would pass for a whole one.
+func (db *Db) Close() error {
+    var errs []error
+
+    if _, ok := db.Recorder.Storage.(*storage.File); ok && db.lockFile != nil && db.loaded {
+        if _, err := os.Stat(db.FilePath); err == nil {
+            errs = append(errs, db.SaveSnapshot())
         }
+    }
+
+    db.loaded = false
+    errs = append(errs, db.IdIndex.Close())
+    db.IdIndex = btree.NewMemory()
+
+    if db.Recorder.Storage != nil {
+        errs = append(errs, db.Recorder.Storage.Close())
+        db.Recorder.Storage = nil
+    }
+
+    if db.lockFile != nil {
+        errs = append(errs, releaseLock(db.lockFile))
+        db.lockFile = nil
+    }
+
+    return errors.Join(errs...)
+}
 
-        var student models.Student
-        err := json.Unmarshal([]byte(line), &student)
+// backupCodec is the format of backups: the data file's own format for the
+// append-only storage, JSONL for everything else.
+func (db *Db) backupCodec() codec.Codec {
+    if file, ok := db.Recorder.Storage.(*storage.File); ok {
+        return file.Codec
+    }
+    return codec.JSONL
+}
+
+// CreateBackup writes the live students into a new full backup archive and
+// starts a chain of backups with it, which CreateIncrementalBackup continues.
+func (db *Db) CreateBackup(backupDir string) error {
+    timestamp := time.Now()
+    backupCodec := db.backupCodec()
+    entry := BackupEntry{
+        File:    fmt.Sprintf("backup_%s%s", timestamp.Format(backupTimeFormat), ArchiveExtension),
+        Kind:    BackupFull,
+        Created: timestamp,
+    }
+
+    if file, ok := db.Recorder.Storage.(*storage.File); ok {
+        info, err := os.Stat(file.Path)
+        if os.IsNotExist(err) {
+            return fmt.Errorf("DB file does not exist: %s", file.Path)
+        }
+        if err != nil {
+            return fmt.Errorf("error reading DB file: %w", err)
+        }
+        // the part of the data file the backup covers, incremental backups go on from there
+        entry.To = info.Size()
+        entry.Checksum, err = checksumRange(file.Path, 0, entry.To, 0)
         if err != nil {
-            continue
+            return fmt.Errorf("error reading DB file: %w", err)
         }
+    }
 
-        if _, exists := db.IdIndex[student.Id]; exists {
-            _, err = dst.Write(append([]byte(line), '\n'))
+    backupPath := filepath.Join(backupDir, entry.File)
+    header := ArchiveHeader{Codec: backupCodec.Name(), SourceSize: entry.To, SourceChecksum: entry.Checksum, Created: timestamp}
+    records, err := writeArchive(backupPath, header, func(write func(models.Student) error) error {
+        live := func(offset int64, student models.Student) error {
+            // only the latest version of an edited student is live
+            liveOffset, exists, err := db.IdIndex.Get(student.Id)
             if err != nil {
-                return fmt.Errorf("error writing to backup file: %w", err)
+                return err
+            }
+            if !exists || liveOffset != offset || student.Deleted {
+                return nil
             }
+            return write(student)
         }
+        // records appended meanwhile lie past To, the next incremental backup has them
+        if file, ok := db.Recorder.Storage.(*storage.File); ok {
+            return file.IterateRange(0, entry.To, live)
+        }
+        return db.Recorder.Storage.Iterate(live)
+    })
+    if err != nil {
+        return fmt.Errorf("error creating backup: %w", err)
     }
+    entry.Records = records
 
-    if err := scanner.Err(); err != nil {
-        return fmt.Errorf("error reading DB file: %w", err)
+    manifest := &BackupManifest{Format: backupCodec.Name(), Backups: []BackupEntry{entry}}
+    err = saveManifest(manifestPath(backupPath), manifest)
+    if err != nil {
+        return err
     }
 
     fmt.Printf("Backup created: %s\n", backupPath)
     return nil
 }
 
-func (db *Db) LoadIndex(dbFilePath string) error {
-    db.IdIndex = make(map[int]int64)
-    db.NameIndex = make(map[string]map[int]bool)
-    db.GpaIndex = make(map[float64]map[int]bool)
-    db.ActiveIndex = make(map[bool]map[int]bool)
-    db.RecordInfo = make(map[int]models.RecordInfo)
-
-    file, err := os.Open(dbFilePath)
+// LoadIndex rebuilds every index from the storage. The id index is collected
+// in memory and then bulk loaded into its file.
+func (db *Db) LoadIndex() error {
+    err := db.loadAllRecords()
     if err != nil {
         return err
     }
-    defer file.Close()
+    return db.storeIdIndex()
+}
 
-    scanner := bufio.NewScanner(file)
-    var offset int64 = 0
+func (db *Db) loadAllRecords() error {
+    db.IdIndex.Close()
+    db.IdIndex = btree.NewMemory()
+    db.RecordInfo = models.NewRecordTable(0)
+    db.Recorder.Indexes.Clear()
+    db.Recorder.Cache.Clear()
 
-    for scanner.Scan() {
-        line := scanner.Text()
-        if line == "" {
-            offset++
-            continue
-        }
+    // chunks are split at newlines, which only works for JSONL files
+    file, ok := db.Recorder.Storage.(*storage.File)
+    if !ok || file.Codec != codec.JSONL || runtime.NumCPU() < 2 {
+        return db.Recorder.Storage.Iterate(db.applyRecord)
+    }
 
-        var student models.Student
-        err := json.Unmarshal([]byte(line), &student)
-        if err != nil {
-            offset += int64(len(line)) + 1
-            continue
-        }
+    info, err := os.Stat(file.Path)
+    if err != nil || info.Size() < parallelLoadMinSize {
+        return db.Recorder.Storage.Iterate(db.applyRecord)
+    }
 
-        db.IdIndex[student.Id] = offset
+    return db.indexParallel(file.Path, info.Size(), runtime.NumCPU())
+}
 
-        if db.NameIndex[student.Name] == nil {
-            db.NameIndex[student.Name] = make(map[int]bool)
-        }
-        db.NameIndex[student.Name][student.Id] = true
+// applyRecord brings the indexes up to date with one stored record, in the
+// order the storage returns them.
+func (db *Db) applyRecord(offset int64, student models.Student) error {
+    if student.Deleted {
+        _, err := db.IdIndex.Delete(student.Id)
+        db.unindexStudent(student.Id)
+        return err
+    }
+    db.indexStudent(student)
+    return db.IdIndex.Put(student.Id, offset)
+}
 
-        if db.GpaIndex[student.Gpa] == nil {
-            db.GpaIndex[student.Gpa] = make(map[int]bool)
-        }
-        db.GpaIndex[student.Gpa][student.Id] = true
+func (db *Db) unindexStudent(id int) {
+    old, exists := db.RecordInfo.Get(id)
+    if !exists {
+        return
+    }
 
-        if db.ActiveIndex[student.Active] == nil {
-            db.ActiveIndex[student.Active] = make(map[int]bool)
-        }
-        db.ActiveIndex[student.Active][student.Id] = true
+    db.Recorder.Indexes.Remove(old.Student(id))
+    db.RecordInfo.Delete(id)
+}
 
-        db.RecordInfo[student.Id] = models.RecordInfo{
-            Name:   student.Name,
-            Gpa:    student.Gpa,
-            Active: student.Active,
-        }
+// indexStudent fills every index but the id index, whose offsets come from
+// different places when loading from the storage and from the snapshot.
+func (db *Db) indexStudent(student models.Student) {
+    // an edited student appears again later in the file, the last line wins
+    db.unindexStudent(student.Id)
 
-        offset += int64(len(line)) + 1
-    }
+    student.Name = db.Recorder.Indexes.InternName(student.Name, db.RecordInfo)
 
-    return scanner.Err()
+    db.RecordInfo.Set(student.Id, models.RecordInfo{
+        Name:    student.Name,
+        Gpa:     student.Gpa,
+        Active:  student.Active,
+        Version: student.Version,
+    })
+    db.Recorder.Indexes.Add(student)
 }
 
+// RestoreFromBackup replaces the database with a backup. backupPath is a
+// manifest, whose whole chain is restored, or a backup file: an incremental
+// one is restored together with the backups before it in its chain. The
+// chain is read and checked before the data file is cleared.
 func (db *Db) RestoreFromBackup(backupPath string) error {
-    src, err := os.Open(backupPath)
+    if db.ReadOnly {
+        return recorder.ErrReadOnly
+    }
+
+    chain, counted, err := backupChain(backupPath)
+    if err != nil {
+        return err
+    }
+    students, err := replayBackups(chain, counted)
     if err != nil {
-        return fmt.Errorf("error opening backup file: %w", err)
+        return err
     }
-    defer src.Close()
 
-    dst, err := os.Create(db.FilePath)
+    st := db.Recorder.Storage
+    err = st.Reset()
     if err != nil {
-        return fmt.Errorf("error creating DB file: %w", err)
+        return fmt.Errorf("error clearing DB file: %w", err)
     }
-    defer dst.Close()
 
-    _, err = io.Copy(dst, src)
+    for _, student := range students {
+        _, err = st.Append(student)
+        if err != nil {
+            return fmt.Errorf("error copying backup to DB file: %w", err)
+        }
+    }
+
+    err = st.Sync()
     if err != nil {
         return fmt.Errorf("error copying backup to DB file: %w", err)
     }
 
-    err = db.LoadIndex(db.FilePath)
+    os.Remove(snapshotPath(db.FilePath))
+
+    err = db.LoadIndex()
     if err != nil {
+        db.loaded = false
         return fmt.Errorf("error rebuilding indexes: %w", err)
     }
 
     fmt.Printf("Database restored from: %s\n", backupPath)
     return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/parquet"
+	"github.com/kgugunava/database/recorder"
+)
+
+// ExportSheet is the name of the sheet ExportXLSX writes.
+const ExportSheet = "Students"
+
+// ExportXLSX writes the students picked by query into a new XLSX file and
+// returns how many there were. The sheet has the header row of
+// recorder.ImportFields, so the file can be imported back, numbers and
+// booleans are stored as such, and the header has an auto-filter and stays in
+// place when scrolling. Like ExportParquet it keeps only the selected ids and
+// makes each student when its row is written; the stream writer keeps large
+// sheets in a temporary file until the workbook is saved.
+func (db *Db) ExportXLSX(path string, query Query) (int, error) {
+	ids, err := db.selectIds(query)
+	if err != nil {
+		return 0, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	err = f.SetSheetName(f.GetSheetName(0), ExportSheet)
+	if err != nil {
+		return 0, fmt.Errorf("error creating sheet: %w", err)
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creating styles: %w", err)
+	}
+	// built-in number format 2 is "0.00"
+	gpaStyle, err := f.NewStyle(&excelize.Style{NumFmt: 2})
+	if err != nil {
+		return 0, fmt.Errorf("error creating styles: %w", err)
+	}
+
+	sw, err := f.NewStreamWriter(ExportSheet)
+	if err != nil {
+		return 0, fmt.Errorf("error creating sheet: %w", err)
+	}
+
+	// a stream writer takes widths, panes and the filter only before the
+	// first row
+	idWidth, nameWidth := len("id"), len("name")
+	for _, id := range ids {
+		idWidth = max(idWidth, len(strconv.Itoa(id)))
+		nameWidth = max(nameWidth, utf8.RuneCountInString(db.student(id).Name))
+	}
+	widths := []float64{float64(idWidth + 4), float64(min(nameWidth+2, 60)), 8, 9}
+	// every new width goes in front of the earlier ones, and Excel wants
+	// the columns in ascending order
+	for col := len(widths); col > 0; col-- {
+		err = sw.SetColWidth(col, col, widths[col-1])
+		if err != nil {
+			return 0, fmt.Errorf("error setting column widths: %w", err)
+		}
+	}
+	err = sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return 0, fmt.Errorf("error freezing header: %w", err)
+	}
+	last, _ := excelize.CoordinatesToCellName(len(recorder.ImportFields), len(ids)+1)
+	err = f.AutoFilter(ExportSheet, "A1:"+last, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error adding auto-filter: %w", err)
+	}
+
+	header := make([]any, len(recorder.ImportFields))
+	for i, field := range recorder.ImportFields {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: field}
+	}
+	err = sw.SetRow("A1", header)
+	if err != nil {
+		return 0, fmt.Errorf("error writing header: %w", err)
+	}
+
+	for i, id := range ids {
+		student := db.student(id)
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		err = sw.SetRow(cell, []any{
+			student.Id,
+			student.Name,
+			excelize.Cell{StyleID: gpaStyle, Value: student.Gpa},
+			student.Active,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error writing row %d: %w", i+2, err)
+		}
+	}
+
+	err = sw.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("error writing sheet: %w", err)
+	}
+
+	err = f.SaveAs(path)
+	if err != nil {
+		return 0, fmt.Errorf("error saving XLSX file: %w", err)
+	}
+	return len(ids), nil
+}
+
+// ExportCSV writes the students picked by query into a new CSV file in the
+// given dialect and returns how many there were. The header row is that of
+// recorder.ImportFields, so the file can be imported back. If a student
+// cannot be written, for example a name Windows-1251 has no letters for, no
+// file is left behind.
+func (db *Db) ExportCSV(path string, query Query, dialect recorder.CSVDialect) (int, error) {
+	students, err := db.Select(query)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("error creating CSV file: %w", err)
+	}
+	err = writeCSV(file, students, dialect)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return len(students), nil
+}
+
+func writeCSV(file *os.File, students []models.Student, dialect recorder.CSVDialect) error {
+	w, err := recorder.NewCSVWriter(file, dialect)
+	if err != nil {
+		return fmt.Errorf("error writing CSV file: %w", err)
+	}
+
+	err = w.Write(recorder.ImportFields)
+	if err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+	for _, student := range students {
+		err = w.Write(dialect.StudentFields(student))
+		if err != nil {
+			return fmt.Errorf("error writing student %d: %w", student.Id, err)
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("error writing CSV file: %w", err)
+	}
+	return nil
+}
+
+// JSONFormat is the layout of exported JSON.
+type JSONFormat string
+
+const (
+	JSONPretty JSONFormat = "json"   // an indented array
+	NDJSON     JSONFormat = "ndjson" // one student per line
+)
+
+var JSONFormats = []JSONFormat{JSONPretty, NDJSON}
+
+// ExportJSON writes the students picked by query to w, which may be a file or
+// stdout, and returns how many there were. Students are written one at a
+// time in the format of the data file, so both formats can be imported back.
+func (db *Db) ExportJSON(w io.Writer, query Query, format JSONFormat) (int, error) {
+	if format != JSONPretty && format != NDJSON {
+		return 0, fmt.Errorf("unknown JSON format %q", format)
+	}
+	students, err := db.Select(query)
+	if err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	if format == JSONPretty {
+		out.WriteString("[")
+	}
+	for i, student := range students {
+		var data []byte
+		if format == JSONPretty {
+			data, err = json.MarshalIndent(student, "  ", "  ")
+			if i > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n  ")
+		} else {
+			data, err = json.Marshal(student)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error encoding student %d: %w", student.Id, err)
+		}
+		out.Write(data)
+		if format == NDJSON {
+			out.WriteString("\n")
+		}
+	}
+	if format == JSONPretty {
+		if len(students) > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("]\n")
+	}
+
+	err = out.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("error writing JSON: %w", err)
+	}
+	return len(students), nil
+}
+
+// SQLBatchSize is how many rows one INSERT statement of an SQL dump has.
+const SQLBatchSize = 500
+
+// ExportSQL writes the students picked by query to w as an SQL script that
+// creates the table recorder.SQLTable and fills it with batched INSERT
+// statements, in one transaction. The script runs as it is in PostgreSQL and
+// SQLite and can be imported back with Recorder.ImportFromSQL.
+func (db *Db) ExportSQL(w io.Writer, query Query) (int, error) {
+	students, err := db.Select(query)
+	if err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "-- %d students\nBEGIN;\n\n", len(students))
+	fmt.Fprintf(out, "CREATE TABLE %s (\n"+
+		"    id INTEGER PRIMARY KEY,\n"+
+		"    name TEXT NOT NULL,\n"+
+		"    gpa DOUBLE PRECISION NOT NULL,\n"+
+		"    active BOOLEAN NOT NULL\n"+
+		");\n", recorder.SQLTable)
+
+	for i, student := range students {
+		if math.IsNaN(student.Gpa) || math.IsInf(student.Gpa, 0) {
+			return 0, fmt.Errorf("student %d has gpa %v, which SQL has no literal for", student.Id, student.Gpa)
+		}
+
+		if i%SQLBatchSize == 0 {
+			fmt.Fprintf(out, "\nINSERT INTO %s (id, name, gpa, active) VALUES\n", recorder.SQLTable)
+		} else {
+			out.WriteString(",\n")
+		}
+		fmt.Fprintf(out, "(%d, %s, %s, %s)", student.Id, sqlString(student.Name),
+			strconv.FormatFloat(student.Gpa, 'g', -1, 64), strings.ToUpper(strconv.FormatBool(student.Active)))
+		if i%SQLBatchSize == SQLBatchSize-1 || i == len(students)-1 {
+			out.WriteString(";\n")
+		}
+	}
+	out.WriteString("\nCOMMIT;\n")
+
+	err = out.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("error writing SQL: %w", err)
+	}
+	return len(students), nil
+}
+
+// sqlString quotes text as an SQL string literal. Only the quote itself is
+// special in standard SQL, backslashes and line breaks are kept as they are.
+func sqlString(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// ExportParquet writes the students picked by query into a new Parquet file
+// for pandas, DuckDB and the like, and returns how many there were. Only the
+// selected ids are kept, each student is made when it is written and rows go
+// to the file a row group at a time, so the table is never copied in memory.
+// If the export fails, no file is left behind.
+func (db *Db) ExportParquet(path string, query Query) (int, error) {
+	ids, err := db.selectIds(query)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("error creating Parquet file: %w", err)
+	}
+	err = db.writeParquet(file, ids)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, fmt.Errorf("error writing Parquet file: %w", err)
+	}
+	return len(ids), nil
+}
+
+func (db *Db) writeParquet(file *os.File, ids []int) error {
+	out := bufio.NewWriter(file)
+	w, err := parquet.NewWriter(out)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err = w.Write(db.student(id))
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
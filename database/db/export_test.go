@@ -0,0 +1,324 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/parquet"
+	"github.com/kgugunava/database/recorder"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportParquet(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDb(t, filepath.Join(dir, "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addStudents(t, db, 0, 3000)
+
+	for _, query := range []Query{
+		{},
+		{OrderBy: "name", Desc: true},
+		{Ids: bitmap.Of(5, 7, 2999, 4000), OrderBy: "gpa"},
+		{Where: func(student models.Student) bool { return student.Active }, OrderBy: "active"},
+		{Where: func(models.Student) bool { return false }},
+	} {
+		want, err := db.Select(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "students.parquet")
+		n, err := db.ExportParquet(path, query)
+		if err != nil || n != len(want) {
+			t.Fatalf("ExportParquet = %d, %v, want %d students", n, err, len(want))
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []models.Student
+		err = parquet.Read(bytes.NewReader(data), int64(len(data)), func(student models.Student) error {
+			got = append(got, student)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the file does not keep versions
+		for i := range want {
+			want[i].Version = 0
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("query %+v: read back %d students, want %d in the same order", query, len(got), len(want))
+		}
+	}
+
+	if _, err := db.ExportParquet(filepath.Join(dir, "bad.parquet"), Query{OrderBy: "email"}); err == nil {
+		t.Fatal("ExportParquet ordered by an unknown field")
+	}
+}
+
+func TestExportXLSXRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDb(t, filepath.Join(dir, "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addStudents(t, db, 0, 300)
+	edited := models.Student{Id: 7, Name: "  Иванова Анна-Мария  ", Gpa: 4.25, Active: true}
+	record := models.Record{Id: edited.Id, Student: edited}
+	if err := db.Recorder.EditRecord(record, 1, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	query := Query{Where: func(student models.Student) bool { return student.Id%2 == 1 }, OrderBy: "name"}
+	want, err := db.Select(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "students.xlsx")
+	n, err := db.ExportXLSX(path, query)
+	if err != nil || n != len(want) {
+		t.Fatalf("ExportXLSX = %d, %v, want %d students", n, err, len(want))
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(ExportSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != n+1 || !slices.Equal(rows[0], recorder.ImportFields) {
+		t.Fatalf("%d rows with the header %v", len(rows), rows[0])
+	}
+	filter := false
+	for _, name := range f.GetDefinedName() {
+		if name.Name == "_xlnm._FilterDatabase" {
+			filter = name.RefersTo == fmt.Sprintf("'%s'!$A$1:$D$%d", ExportSheet, n+1)
+		}
+	}
+	if !filter {
+		t.Fatalf("no auto-filter over the table in %v", f.GetDefinedName())
+	}
+	if panes, err := f.GetPanes(ExportSheet); err != nil || !panes.Freeze || panes.YSplit != 1 {
+		t.Fatalf("the header is not frozen: %+v, %v", panes, err)
+	}
+	if width, err := f.GetColWidth(ExportSheet, "B"); err != nil || width < float64(len("student 299")) {
+		t.Fatalf("name column width %v, %v", width, err)
+	}
+
+	// importing the file into an empty database gives back the same students
+	imported := newTestDb(t, filepath.Join(dir, "imported.jsonl"))
+	if err := imported.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	report, err := imported.Recorder.ImportFromXLSX(path, recorder.ImportOptions{}, imported.IdIndex, imported.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Inserted: n}) {
+		t.Fatalf("import: %s", report.Summary())
+	}
+	got, err := imported.Select(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d students imported, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Id != want[i].Id || got[i].Name != want[i].Name || got[i].Gpa != want[i].Gpa || got[i].Active != want[i].Active {
+			t.Fatalf("imported %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDb(t, filepath.Join(dir, "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addStudents(t, db, 0, 50)
+	for id, name := range map[int]string{
+		1: `Анна "Аня" Петрова`,
+		2: "O'Brien; Jr, III",
+		4: "Две\nстроки",
+		5: "  Ёлкина\tЮлия  ",
+	} {
+		info, _ := db.RecordInfo.Get(id)
+		student := info.Student(id)
+		student.Name = name
+		record := models.Record{Id: id, Student: student}
+		if err := db.Recorder.EditRecord(record, int(info.Version), db.IdIndex, db.RecordInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plainNames := func(student models.Student) bool { return student.Id != 2 && student.Id != 4 && student.Id != 5 }
+
+	tests := []struct {
+		name    string
+		dialect recorder.CSVDialect
+		read    recorder.CSVDialect // how the file is imported
+		where   func(models.Student) bool
+	}{
+		{name: "default"},
+		{
+			name:    "excel",
+			dialect: recorder.CSVDialect{Delimiter: ';', Encoding: recorder.EncodingUTF8BOM, DecimalComma: true},
+		},
+		{
+			name:    "windows-1251",
+			dialect: recorder.CSVDialect{Delimiter: ';', Encoding: recorder.EncodingWindows1251, DecimalComma: true},
+		},
+		{
+			name:    "all quoted",
+			dialect: recorder.CSVDialect{Delimiter: '\t', Quoting: recorder.QuoteAll},
+		},
+		{
+			name:    "not quoted",
+			dialect: recorder.CSVDialect{Delimiter: '|', Quoting: recorder.QuoteNone},
+			read:    recorder.CSVDialect{Quoting: recorder.QuoteNone},
+			where:   plainNames,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := Query{Where: test.where}
+			want, err := db.Select(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "students.csv")
+			n, err := db.ExportCSV(path, query, test.dialect)
+			if err != nil || n != len(want) {
+				t.Fatalf("ExportCSV = %d, %v, want %d students", n, err, len(want))
+			}
+
+			imported := newTestDb(t, filepath.Join(t.TempDir(), "imported.jsonl"))
+			if err := imported.Open(false); err != nil {
+				t.Fatal(err)
+			}
+			defer imported.Close()
+			report, err := imported.Recorder.ImportFromCSV(path, test.read, recorder.ImportOptions{}, imported.IdIndex, imported.RecordInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Counts() != (recorder.ImportCounts{Inserted: n}) {
+				t.Fatalf("import: %s, skipped %v", report.Summary(), report.Skipped)
+			}
+			got := selectAll(t, imported)
+			for i := range want {
+				if got[i].Id != want[i].Id || got[i].Name != want[i].Name || got[i].Gpa != want[i].Gpa || got[i].Active != want[i].Active {
+					t.Fatalf("imported %+v, want %+v", got[i], want[i])
+				}
+			}
+		})
+	}
+
+	// without quoting a name with a line break cannot be written
+	path := filepath.Join(dir, "broken.csv")
+	_, err := db.ExportCSV(path, Query{}, recorder.CSVDialect{Quoting: recorder.QuoteNone})
+	if err == nil {
+		t.Fatal("ExportCSV wrote a line break without quoting")
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Fatalf("a failed export left %s behind", path)
+	}
+}
+
+func TestExportSQLRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDb(t, filepath.Join(dir, "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// more students than one INSERT takes
+	addStudents(t, db, 0, SQLBatchSize+100)
+	for id, name := range map[int]string{
+		1: "O'Brien",
+		2: `C:\Users\'quoted'\`,
+		3: "две\nстроки\r\nи ещё",
+		4: "Łukasz Żółć 学生 😀",
+		5: "'; DROP TABLE students; --",
+	} {
+		info, _ := db.RecordInfo.Get(id)
+		student := info.Student(id)
+		student.Name = name
+		student.Gpa = 1.0 / 3
+		record := models.Record{Id: id, Student: student}
+		if err := db.Recorder.EditRecord(record, int(info.Version), db.IdIndex, db.RecordInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := selectAll(t, db)
+
+	var dump bytes.Buffer
+	n, err := db.ExportSQL(&dump, Query{})
+	if err != nil || n != len(want) {
+		t.Fatalf("ExportSQL = %d, %v, want %d students", n, err, len(want))
+	}
+
+	imported := newTestDb(t, filepath.Join(dir, "imported.jsonl"))
+	if err := imported.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	report, err := imported.Recorder.ImportFromSQL(&dump, recorder.ImportOptions{}, imported.IdIndex, imported.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Inserted: n}) {
+		t.Fatalf("import: %s, skipped %v", report.Summary(), report.Skipped)
+	}
+	got := selectAll(t, imported)
+	for i := range want {
+		if got[i].Id != want[i].Id || got[i].Name != want[i].Name || got[i].Gpa != want[i].Gpa || got[i].Active != want[i].Active {
+			t.Fatalf("imported %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestImportSQLRowNumbers(t *testing.T) {
+	dump := `-- two statements, tuples over several lines
+INSERT INTO students (id, name) VALUES
+(20, 'Anna'), (21,
+'Boris'),
+(22, 'Vera', 3);
+INSERT INTO other VALUES (1, 'not a student');
+INSERT INTO students (name, id) VALUES ('Gleb', 23), ('bad id', 'x'),
+('Dina', 20);
+`
+	db := openImportTest(t, false)
+	report, err := db.Recorder.ImportFromSQL(strings.NewReader(dump), recorder.ImportOptions{}, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 6 || !slices.Equal(rowNumbers(report.Insert), []int{1, 2, 4}) ||
+		!slices.Equal(errorRows(report.Skipped), []int{3, 5}) || !slices.Equal(errorRows(report.Conflicts), []int{6}) {
+		t.Fatalf("%d rows: insert %v, skipped %v, conflicts %v", report.Rows, rowNumbers(report.Insert), report.Skipped, report.Conflicts)
+	}
+	for i, line := range []string{"line 5:", "line 7:"} {
+		if !strings.Contains(report.Skipped[i].Error(), line) {
+			t.Fatalf("skipped %v does not name %s", report.Skipped[i].Error(), line)
+		}
+	}
+}
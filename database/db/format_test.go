@@ -0,0 +1,63 @@
+package db
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// The storage formats are compared on a database of 1 000 000 students:
+//
+//	go test -run '^$' -bench Format ./database/db
+const formatStudents = 1_000_000
+
+var formatExtensions = []string{".jsonl", ".sdb"}
+
+// openFormat opens a database of n students in the format of ext and
+// returns it with the size of its data file.
+func openFormat(b *testing.B, ext string, n int) (*Db, int64) {
+	path := filepath.Join(b.TempDir(), "input"+ext)
+	_, done := writeStudents(b, path, n)
+	size := done()
+
+	db := newTestDb(b, path)
+	if err := db.Open(false); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db, size
+}
+
+func BenchmarkFormatLoadIndex(b *testing.B) {
+	for _, ext := range formatExtensions {
+		b.Run(ext[1:], func(b *testing.B) {
+			db, size := openFormat(b, ext, formatStudents)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := db.LoadIndex(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size)/1e6, "file-MB")
+		})
+	}
+}
+
+func BenchmarkFormatFindById(b *testing.B) {
+	for _, ext := range formatExtensions {
+		b.Run(ext[1:], func(b *testing.B) {
+			db, _ := openFormat(b, ext, formatStudents)
+			ids := rand.New(rand.NewSource(1)).Perm(formatStudents)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				id := ids[i%len(ids)]
+				student, err := db.Recorder.FindById(id, db.IdIndex)
+				if err != nil || student.Id != id {
+					b.Fatalf("FindById(%d) = %v, %v", id, student, err)
+				}
+			}
+		})
+	}
+}
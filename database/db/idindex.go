@@ -0,0 +1,113 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/storage"
+)
+
+// The id index is a B+tree file next to the data file. It is tagged with the
+// same data file stamp as the snapshot when the database is closed, so at the
+// next start a matching tree is used as is. After a crash the tag does not
+// match and the tree is bulk loaded from the snapshot offsets instead.
+
+type idOffset struct {
+	id     int
+	offset int64
+}
+
+func idIndexPath(dbFilePath string) string {
+	return dbFilePath + ".btree"
+}
+
+// dataStamp identifies the state of the data file the way the snapshot does.
+func dataStamp(size int64, checksum uint32) []byte {
+	stamp := make([]byte, 12)
+	binary.LittleEndian.PutUint64(stamp, uint64(size))
+	binary.LittleEndian.PutUint32(stamp[8:], checksum)
+	return stamp
+}
+
+// persistentIdIndex tells whether the id index lives in a file. Without the
+// lock another process may be changing that file, and the in-memory storage
+// has no place for it.
+func (db *Db) persistentIdIndex() bool {
+	_, inMemory := db.Recorder.Storage.(*storage.Memory)
+	return db.lockFile != nil && !inMemory
+}
+
+// attachIdIndex opens the id index tree built for the snapshot with the given
+// stamp, rebuilding it from the snapshot offsets when it does not match.
+func (db *Db) attachIdIndex(stamp []byte, offsets []idOffset) error {
+	db.IdIndex.Close()
+
+	if !slices.IsSortedFunc(offsets, compareIds) {
+		// snapshots of older versions are not sorted by id
+		slices.SortFunc(offsets, compareIds)
+	}
+
+	if !db.persistentIdIndex() {
+		db.IdIndex = btree.NewMemory()
+		for _, entry := range offsets {
+			if err := db.IdIndex.Put(entry.id, entry.offset); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path := idIndexPath(db.FilePath)
+	tree, err := btree.Open(path)
+	if errors.Is(err, btree.ErrCorrupt) {
+		os.Remove(path)
+		tree, err = btree.Open(path)
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(tree.Meta()[:len(stamp)], stamp) && tree.Len() == len(offsets) {
+		db.IdIndex = tree
+		return nil
+	}
+	tree.Close()
+
+	db.IdIndex, err = btree.BulkLoad(path, func(yield func(int, int64) bool) {
+		for _, entry := range offsets {
+			if !yield(entry.id, entry.offset) {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// storeIdIndex replaces the id index file with the contents of the in-memory
+// tree built by LoadIndex.
+func (db *Db) storeIdIndex() error {
+	if !db.persistentIdIndex() {
+		return nil
+	}
+
+	entries, entriesErr := db.IdIndex.All()
+	tree, err := btree.BulkLoad(idIndexPath(db.FilePath), entries)
+	if err != nil {
+		return err
+	}
+	if err := entriesErr(); err != nil {
+		tree.Close()
+		return err
+	}
+
+	db.IdIndex = tree
+	return nil
+}
+
+func compareIds(a, b idOffset) int {
+	return cmp.Compare(a.id, b.id)
+}
@@ -0,0 +1,423 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/recorder"
+	"github.com/xuri/excelize/v2"
+)
+
+// writeXLSX saves rows to the first sheet of a new workbook.
+func writeXLSX(t *testing.T, path string, rows [][]any) {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openImportTest is a database of students 0..9 made by addStudents, closed
+// and opened again with readOnly.
+func openImportTest(t *testing.T, readOnly bool) *Db {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	db := newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 10)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = newTestDb(t, path)
+	if err := db.Open(readOnly); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func rowNumbers(rows []recorder.ImportRow) []int {
+	var numbers []int
+	for _, row := range rows {
+		numbers = append(numbers, row.Row)
+	}
+	return numbers
+}
+
+func errorRows(errs []recorder.RowError) []int {
+	var numbers []int
+	for _, rowErr := range errs {
+		numbers = append(numbers, rowErr.Row)
+	}
+	return numbers
+}
+
+func TestDryRunImport(t *testing.T) {
+	xlsxPath := filepath.Join(t.TempDir(), "students.xlsx")
+	writeXLSX(t, xlsxPath, [][]any{
+		{"ID", "ФИО", "Средний балл", "Активен"},
+		{20, "Anna", "4,5", "да"},
+		{5, "Boris", 3.25, "нет"},
+		{"x", "Bad id", 4, "да"},
+		{21, "Vera", 2, "true"},
+		{20, "Anna again", 4, "нет"},
+	})
+	options := recorder.ImportOptions{DryRun: true, OnConflict: recorder.ConflictOverwrite}
+
+	// a read-only database can be asked what an import would do
+	db := openImportTest(t, true)
+	before := selectAll(t, db)
+	size := fileSize(t, db.FilePath)
+	report, err := db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := report.Counts()
+	if report.Applied || report.Rows != 5 || counts != (recorder.ImportCounts{Inserted: 2, Overwritten: 1, Skipped: 1, Conflicts: 1}) {
+		t.Fatalf("dry run report: %s", report.Summary())
+	}
+	if err := db.Recorder.ApplyImport(report, db.IdIndex, db.RecordInfo); !errors.Is(err, recorder.ErrReadOnly) {
+		t.Fatalf("ApplyImport on a read-only database: %v", err)
+	}
+	options.DryRun = false
+	if _, err := db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo); !errors.Is(err, recorder.ErrReadOnly) {
+		t.Fatalf("import into a read-only database: %v", err)
+	}
+	if !slices.Equal(selectAll(t, db), before) || fileSize(t, db.FilePath) != size {
+		t.Fatal("a dry run changed the database")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// applied, the report does exactly what it said
+	db = newTestDb(t, db.FilePath)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	options.DryRun = true
+	report, err = db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileSize(t, db.FilePath) != size {
+		t.Fatal("a dry run wrote to the data file")
+	}
+	if err := db.Recorder.ApplyImport(report, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Applied || report.Counts() != counts {
+		t.Fatalf("applied report: %s", report.Summary())
+	}
+	if !slices.Equal(rowNumbers(report.Insert), []int{2, 5}) || !slices.Equal(rowNumbers(report.Update), []int{3}) ||
+		!slices.Equal(errorRows(report.Skipped), []int{4}) || !slices.Equal(errorRows(report.Conflicts), []int{6}) {
+		t.Fatalf("rows: insert %v, update %v, skipped %v, conflicts %v",
+			rowNumbers(report.Insert), rowNumbers(report.Update), errorRows(report.Skipped), errorRows(report.Conflicts))
+	}
+
+	want := map[int]models.Student{
+		20: {Id: 20, Name: "Anna", Gpa: 4.5, Active: true},
+		5:  {Id: 5, Name: "Boris", Gpa: 3.25, Active: false},
+		21: {Id: 21, Name: "Vera", Gpa: 2, Active: true},
+	}
+	for id, student := range want {
+		got, err := db.Recorder.FindById(id, db.IdIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Version = 0
+		if *got != student {
+			t.Fatalf("FindById(%d) = %+v, want %+v", id, *got, student)
+		}
+	}
+	if len(selectAll(t, db)) != 12 {
+		t.Fatalf("%d students after the import, want 12", len(selectAll(t, db)))
+	}
+}
+
+// studentById returns the student with id without its version.
+func studentById(t *testing.T, db *Db, id int) (models.Student, bool) {
+	t.Helper()
+	if _, exists := db.RecordInfo.Get(id); !exists {
+		return models.Student{}, false
+	}
+	student, err := db.Recorder.FindById(id, db.IdIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	student.Version = 0
+	return *student, true
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	xlsxPath := filepath.Join(t.TempDir(), "students.xlsx")
+	writeXLSX(t, xlsxPath, [][]any{
+		{"id", "name", "gpa"},
+		{3, "Three", 4.5},
+		{30, "Thirty", 3},
+		{30, "Thirty again", 1},
+		{4, "Four", ""},
+	})
+
+	tests := []struct {
+		policy recorder.ConflictPolicy
+		counts recorder.ImportCounts
+		err    error
+		want   map[int]models.Student // students 3, 4 and 30 afterwards
+	}{
+		{
+			policy: recorder.ConflictSkip,
+			counts: recorder.ImportCounts{Inserted: 1, Conflicts: 3},
+			want: map[int]models.Student{
+				3:  {Id: 3, Name: "student 3", Gpa: 0.3, Active: true},
+				4:  {Id: 4, Name: "student 4", Gpa: 0.4},
+				30: {Id: 30, Name: "Thirty", Gpa: 3},
+			},
+		},
+		{
+			policy: recorder.ConflictOverwrite,
+			counts: recorder.ImportCounts{Inserted: 1, Overwritten: 2, Conflicts: 1},
+			want: map[int]models.Student{
+				3:  {Id: 3, Name: "Three", Gpa: 4.5},
+				4:  {Id: 4, Name: "Four"},
+				30: {Id: 30, Name: "Thirty", Gpa: 3},
+			},
+		},
+		{
+			policy: recorder.ConflictUpsert,
+			counts: recorder.ImportCounts{Inserted: 1, Merged: 2, Conflicts: 1},
+			want: map[int]models.Student{
+				3:  {Id: 3, Name: "Three", Gpa: 4.5, Active: true},
+				4:  {Id: 4, Name: "Four", Gpa: 0.4},
+				30: {Id: 30, Name: "Thirty", Gpa: 3},
+			},
+		},
+		{
+			policy: recorder.ConflictFail,
+			counts: recorder.ImportCounts{Inserted: 1, Conflicts: 3},
+			err:    recorder.ErrImportConflict,
+			want: map[int]models.Student{
+				3: {Id: 3, Name: "student 3", Gpa: 0.3, Active: true},
+				4: {Id: 4, Name: "student 4", Gpa: 0.4},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			db := openImportTest(t, false)
+			options := recorder.ImportOptions{DryRun: true, OnConflict: test.policy}
+			report, err := db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Counts() != test.counts {
+				t.Fatalf("dry run: %s", report.Summary())
+			}
+
+			// applying plans the rows again without piling up conflicts
+			for range 2 {
+				err = db.Recorder.ApplyImport(report, db.IdIndex, db.RecordInfo)
+				if !errors.Is(err, test.err) {
+					t.Fatalf("ApplyImport: %v, want %v", err, test.err)
+				}
+				if report.Counts() != test.counts {
+					t.Fatalf("applied: %s", report.Summary())
+				}
+				if err == nil {
+					break
+				}
+			}
+
+			for _, id := range []int{3, 4, 30} {
+				got, exists := studentById(t, db, id)
+				want, wanted := test.want[id]
+				if exists != wanted || got != want {
+					t.Fatalf("student %d is %+v, want %+v", id, got, want)
+				}
+			}
+			if err == nil {
+				if err := db.Recorder.ApplyImport(report, db.IdIndex, db.RecordInfo); err == nil {
+					t.Fatal("a report was applied twice")
+				}
+			}
+		})
+	}
+}
+
+func TestApplyImportPlansAgain(t *testing.T) {
+	xlsxPath := filepath.Join(t.TempDir(), "students.xlsx")
+	writeXLSX(t, xlsxPath, [][]any{
+		{"id", "name", "gpa", "active"},
+		{3, "Three", 4.5, "да"},
+		{30, "Thirty", 3, "нет"},
+	})
+	db := openImportTest(t, false)
+	options := recorder.ImportOptions{DryRun: true}
+	report, err := db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Inserted: 1, Conflicts: 1}) {
+		t.Fatalf("dry run: %s", report.Summary())
+	}
+
+	// student 3 is deleted and 30 added after the dry run
+	if err := db.Recorder.DeleteRecordById(3, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 30, 31)
+	if err := db.Recorder.ApplyImport(report, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Inserted: 1, Conflicts: 1}) ||
+		!slices.Equal(rowNumbers(report.Insert), []int{2}) || !slices.Equal(errorRows(report.Conflicts), []int{3}) {
+		t.Fatalf("applied: %s", report.Summary())
+	}
+	if student, _ := studentById(t, db, 3); student.Name != "Three" {
+		t.Fatalf("student 3 is %+v", student)
+	}
+}
+
+func TestUpsertNewStudentWithoutName(t *testing.T) {
+	xlsxPath := filepath.Join(t.TempDir(), "grades.xlsx")
+	writeXLSX(t, xlsxPath, [][]any{
+		{"id", "gpa"},
+		{5, 4.9},
+		{40, 3.3},
+	})
+	db := openImportTest(t, false)
+	options := recorder.ImportOptions{OnConflict: recorder.ConflictUpsert}
+	report, err := db.Recorder.ImportFromXLSX(xlsxPath, options, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Merged: 1, Skipped: 1}) || !errors.Is(&report.Skipped[0], recorder.ErrNoName) {
+		t.Fatalf("report: %s, skipped %v", report.Summary(), report.Skipped)
+	}
+	if _, exists := studentById(t, db, 40); exists {
+		t.Fatal("a student without a name was added")
+	}
+	if student, _ := studentById(t, db, 5); student != (models.Student{Id: 5, Name: "student 5", Gpa: 4.9}) {
+		t.Fatalf("student 5 is %+v", student)
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	elements := []string{
+		`{"id": 20, "name": "Anna", "gpa": 4.5, "active": true}`,
+		`"not an object"`,
+		`{"name": "no id"}`,
+		`{"id": "21", "name": "id is a string"}`,
+		`{"id": 22, "gpa": 3}`,
+		`{"id": 23, "name": "Вера\nО'Нил \"В.\"", "gpa": 2, "active": false}`,
+		`{"id": 5, "name": "taken"}`,
+	}
+	inputs := map[string]string{
+		"array":  "[\n" + strings.Join(elements, ",\n") + "\n]\n",
+		"ndjson": "\xef\xbb\xbf" + strings.Join(elements, "\n") + "\n",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			db := openImportTest(t, false)
+			report, err := db.Recorder.ImportFromJSON(strings.NewReader(input), recorder.ImportOptions{}, db.IdIndex, db.RecordInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Rows != 7 || report.Counts() != (recorder.ImportCounts{Inserted: 2, Skipped: 4, Conflicts: 1}) {
+				t.Fatalf("report: %s", report.Summary())
+			}
+			if !slices.Equal(errorRows(report.Skipped), []int{2, 3, 4, 5}) || !errors.Is(&report.Skipped[3], recorder.ErrNoName) {
+				t.Fatalf("skipped %v", report.Skipped)
+			}
+			if !slices.Equal(errorRows(report.Conflicts), []int{7}) {
+				t.Fatalf("conflicts %v", report.Conflicts)
+			}
+			if student, _ := studentById(t, db, 20); student != (models.Student{Id: 20, Name: "Anna", Gpa: 4.5, Active: true}) {
+				t.Fatalf("student 20 is %+v", student)
+			}
+			if student, _ := studentById(t, db, 23); student.Name != "Вера\nО'Нил \"В.\"" {
+				t.Fatalf("student 23 is %+v", student)
+			}
+		})
+	}
+}
+
+func TestImportBrokenJSONArray(t *testing.T) {
+	db := openImportTest(t, false)
+	input := `[{"id": 20, "name": "Anna"}, {"id": 21, "name": "Boris",}, {"id": 22, "name": "Vera"}]`
+	report, err := db.Recorder.ImportFromJSON(strings.NewReader(input), recorder.ImportOptions{}, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 2 || report.Counts() != (recorder.ImportCounts{Inserted: 1, Skipped: 1}) {
+		t.Fatalf("report: %s", report.Summary())
+	}
+	if skipped := report.Skipped[0]; skipped.Row != 2 || !strings.Contains(skipped.Error(), "at byte 57") {
+		t.Fatalf("skipped %v", skipped.Error())
+	}
+	if _, exists := studentById(t, db, 20); !exists {
+		t.Fatal("the student before the broken element was not imported")
+	}
+}
+
+// TestImportJSONFromPipe reads NDJSON the way the -import-json - flag reads
+// stdin, while it is still being written.
+func TestImportJSONFromPipe(t *testing.T) {
+	db := openImportTest(t, false)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		defer w.Close()
+		w.Write([]byte("\xef\xbb"))
+		w.Write([]byte("\xbf{\"id\": 30, \"name\": \"Anna\"}\n{\"id\": 31,"))
+		for id := 32; id < 1000; id++ {
+			w.Write([]byte(" \"name\": \"piped\"}\n{\"id\": " + strconv.Itoa(id) + ","))
+		}
+		w.Write([]byte(" \"name\": \"last\"}"))
+	}()
+
+	report, err := db.Recorder.ImportFromJSON(r, recorder.ImportOptions{}, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts() != (recorder.ImportCounts{Inserted: 970}) {
+		t.Fatalf("report: %s", report.Summary())
+	}
+	if student, _ := studentById(t, db, 999); student.Name != "last" {
+		t.Fatalf("student 999 is %+v", student)
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/index"
+)
+
+// All indexes but the id index are kept in the Recorder's registry. Only the
+// definitions of those created at runtime are saved, in a JSON file next to
+// the data file; the contents are rebuilt together with the other indexes
+// when the database is opened.
+
+func indexDefinitionsPath(dbFilePath string) string {
+	return dbFilePath + ".indexes"
+}
+
+// CreateIndex adds an index of the given kind on field and fills it from the
+// students already in the database. On name, gpa or active it replaces the
+// built-in index.
+func (db *Db) CreateIndex(field string, kind index.Kind) error {
+	idx, err := db.Recorder.Indexes.Create(field, kind)
+	if err != nil {
+		return err
+	}
+	db.fillIndex(field, idx)
+
+	return db.saveIndexDefinitions()
+}
+
+// DropIndex removes an index created at runtime. Dropping one that replaced a
+// built-in index rebuilds the built-in one.
+func (db *Db) DropIndex(field string) error {
+	builtin, err := db.Recorder.Indexes.Drop(field)
+	if err != nil {
+		return err
+	}
+	if builtin != nil {
+		db.fillIndex(field, builtin)
+	}
+
+	return db.saveIndexDefinitions()
+}
+
+func (db *Db) fillIndex(field string, idx index.Index) {
+	value := index.Fields[field].Value
+	for id, info := range db.RecordInfo.All() {
+		idx.Add(value(info.Student(id)), id)
+	}
+}
+
+// AllIds returns every live id, the universe for a NOT: the ids without a
+// value are bitmap.AndNot(db.AllIds(), ids).
+func (db *Db) AllIds() *bitmap.Bitmap {
+	all := bitmap.New()
+	for _, active := range []bool{false, true} {
+		ids, _ := db.Recorder.Indexes.Bitmap("active", active)
+		all = bitmap.Or(all, ids)
+	}
+	return all
+}
+
+// Indexes lists the built-in and the runtime indexes.
+func (db *Db) Indexes() []index.Definition {
+	return db.Recorder.Indexes.Definitions()
+}
+
+// saveIndexDefinitions is skipped without the lock, in read-only mode indexes
+// only live until the program exits.
+func (db *Db) saveIndexDefinitions() error {
+	if db.lockFile == nil {
+		return nil
+	}
+
+	defs := []index.Definition{}
+	for _, def := range db.Indexes() {
+		if !def.Builtin {
+			defs = append(defs, def)
+		}
+	}
+
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(indexDefinitionsPath(db.FilePath), data, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving index definitions: %w", err)
+	}
+	return nil
+}
+
+// loadIndexDefinitions creates the empty indexes saved by an earlier run.
+// Indexes on name, gpa and active that are not in the file are built in.
+func (db *Db) loadIndexDefinitions() error {
+	data, err := os.ReadFile(indexDefinitionsPath(db.FilePath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var defs []index.Definition
+	err = json.Unmarshal(data, &defs)
+	if err != nil {
+		return fmt.Errorf("error reading index definitions: %w", err)
+	}
+
+	for _, def := range defs {
+		if idx, exists := db.Recorder.Indexes.Get(def.Field); exists && idx.Kind() == def.Kind {
+			continue
+		}
+		db.Recorder.Indexes.Drop(def.Field)
+		_, err := db.Recorder.Indexes.Create(def.Field, def.Kind)
+		if err != nil {
+			return fmt.Errorf("error reading index definitions: %w", err)
+		}
+	}
+	return nil
+}
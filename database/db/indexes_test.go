@@ -0,0 +1,182 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/index"
+)
+
+// reopen closes db and opens the same file again.
+func reopen(t *testing.T, db *Db) *Db {
+	t.Helper()
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = newTestDb(t, db.FilePath)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func savedIndexes(t *testing.T, db *Db) []index.Definition {
+	t.Helper()
+	var defs []index.Definition
+	data, err := os.ReadFile(indexDefinitionsPath(db.FilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		t.Fatal(err)
+	}
+	return defs
+}
+
+func TestRuntimeIndexUpkeep(t *testing.T) {
+	db := newTestDb(t, filepath.Join(t.TempDir(), "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 30)
+
+	// the index is filled from the students already there
+	if err := db.CreateIndex("version", index.Ordered); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateIndex("version", index.Hash); err == nil {
+		t.Fatal("created a second index on version")
+	}
+	lookup := func(field string, value any) []int {
+		return db.Recorder.Indexes.Lookup(field, value)
+	}
+	if ids := lookup("version", 1); len(ids) != 30 {
+		t.Fatalf("version 1 has %d students after CreateIndex", len(ids))
+	}
+
+	student, err := db.Recorder.FindById(6, db.IdIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := *student
+	edited.Name = "renamed"
+	edited.Gpa = 4.9
+	edited.Active = false
+	if err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(edited), student.Version, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Recorder.DeleteRecordById(9, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(db *Db) {
+		t.Helper()
+		lookup := func(field string, value any) []int {
+			return db.Recorder.Indexes.Lookup(field, value)
+		}
+		for _, test := range []struct {
+			field string
+			value any
+			want  []int
+		}{
+			{"version", 2, []int{6}},
+			{"name", "renamed", []int{6}},
+			{"name", "student 6", nil},
+			{"name", "student 9", nil},
+			{"gpa", 4.9, []int{6}},
+			{"gpa", 0.6, nil},
+		} {
+			if ids := lookup(test.field, test.value); !slices.Equal(ids, test.want) {
+				t.Errorf("%s %v has %v, want %v", test.field, test.value, ids, test.want)
+			}
+		}
+		if ids := lookup("version", 1); len(ids) != 28 || slices.Contains(ids, 6) || slices.Contains(ids, 9) {
+			t.Errorf("version 1 has %v", ids)
+		}
+		if ids := lookup("active", true); slices.Contains(ids, 6) || slices.Contains(ids, 9) || len(ids) != 8 {
+			t.Errorf("active has %v", ids)
+		}
+		ordered, _ := db.Recorder.Indexes.Get("version")
+		if ids := ordered.(index.RangeIndex).Range(2, 5); !slices.Equal(ids, []int{6}) {
+			t.Errorf("versions 2 to 5 are %v", ids)
+		}
+	}
+	check(db)
+
+	// the definition is saved and the contents are rebuilt, from the
+	// snapshot and then from the data file
+	if defs := savedIndexes(t, db); !slices.Equal(defs, []index.Definition{{Field: "version", Kind: index.Ordered}}) {
+		t.Fatalf("saved %+v", defs)
+	}
+	db = reopen(t, db)
+	check(db)
+	os.Remove(snapshotPath(db.FilePath))
+	db = reopen(t, db)
+	check(db)
+
+	if err := db.DropIndex("version"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DropIndex("version"); err == nil {
+		t.Fatal("dropped the version index twice")
+	}
+	if defs := savedIndexes(t, db); len(defs) != 0 {
+		t.Fatalf("saved %+v after DropIndex", defs)
+	}
+	db = reopen(t, db)
+	if _, exists := db.Recorder.Indexes.Get("version"); exists {
+		t.Fatal("the dropped index came back")
+	}
+}
+
+func TestReplaceBuiltinIndex(t *testing.T) {
+	db := newTestDb(t, filepath.Join(t.TempDir(), "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 20)
+
+	if err := db.DropIndex("gpa"); err == nil {
+		t.Fatal("dropped the built-in gpa index")
+	}
+	if err := db.CreateIndex("gpa", index.Ordered); err != nil {
+		t.Fatal(err)
+	}
+	rangeIds := func(db *Db) []int {
+		t.Helper()
+		students, err := db.Recorder.FindByIndexRange("gpa", 1.0, 1.2, db.IdIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, student := range students {
+			ids = append(ids, student.Id)
+		}
+		return ids
+	}
+	if ids := rangeIds(db); !slices.Equal(ids, []int{10, 11, 12}) {
+		t.Fatalf("gpa 1.0 to 1.2 is %v", ids)
+	}
+
+	db = reopen(t, db)
+	if ids := rangeIds(db); !slices.Equal(ids, []int{10, 11, 12}) {
+		t.Fatalf("gpa 1.0 to 1.2 is %v after reopening", ids)
+	}
+
+	// dropping it brings back a filled built-in index
+	if err := db.DropIndex("gpa"); err != nil {
+		t.Fatal(err)
+	}
+	idx, _ := db.Recorder.Indexes.Get("gpa")
+	if idx.Kind() != index.Bitmap || !slices.Equal(idx.Lookup(1.1), []int{11}) {
+		t.Fatalf("gpa index is %s with %v", idx.Kind(), idx.Lookup(1.1))
+	}
+	students, err := db.Recorder.FindByGpa(1.1, db.IdIndex)
+	if err != nil || len(students) != 1 || students[0].Id != 11 {
+		t.Fatalf("FindByGpa(1.1) = %v, %v", students, err)
+	}
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockPidTimeout is how long a lock file may stay without a PID. The owner
+// writes it right after creating the file, so a file that has none for longer
+// was left by a crash in between.
+const lockPidTimeout = 10 * time.Second
+
+// LockedError is returned by Open when another process holds the database.
+type LockedError struct {
+	Pid int
+}
+
+func (e *LockedError) Error() string {
+	if e.Pid == 0 {
+		return "database is in use by another process"
+	}
+	return fmt.Sprintf("database is in use by PID %d", e.Pid)
+}
+
+func lockPath(dbFilePath string) string {
+	return dbFilePath + ".lock"
+}
+
+func readLockPid(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func writeLockPid(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// staleLock reports whether the lock file at path was left behind by an owner
+// that is gone, and returns the PID in it. Only platforms without flock need
+// this, there the file itself is the lock.
+func staleLock(path string, alive func(int) bool, now time.Time) (bool, int) {
+	pid := readLockPid(path)
+	if pid != 0 {
+		return !alive(pid), pid
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// removed meanwhile, the next attempt can create it
+		return os.IsNotExist(err), 0
+	}
+	return now.Sub(info.ModTime()) > lockPidTimeout, 0
+}
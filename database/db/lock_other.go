@@ -0,0 +1,50 @@
+//go:build !unix
+
+package db
+
+import (
+	"os"
+	"time"
+)
+
+// acquireLock creates the lock file exclusively. Without flock a crashed
+// owner leaves the file behind, so a file whose PID no longer exists, or that
+// never got one, is considered stale and replaced.
+func acquireLock(dbFilePath string) (*os.File, error) {
+	path := lockPath(dbFilePath)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			if err := writeLockPid(file); err != nil {
+				file.Close()
+				return nil, err
+			}
+			return file, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		stale, pid := staleLock(path, processAlive, time.Now())
+		if !stale {
+			return nil, &LockedError{Pid: pid}
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, &LockedError{Pid: readLockPid(path)}
+}
+
+func releaseLock(file *os.File) error {
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
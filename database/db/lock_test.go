@@ -0,0 +1,44 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl.lock")
+	now := time.Now()
+	alive := func(pid int) bool { return pid == os.Getpid() }
+
+	for _, test := range []struct {
+		name    string
+		content string
+		age     time.Duration
+		stale   bool
+	}{
+		{"live owner", strconv.Itoa(os.Getpid()) + "\n", time.Hour, false},
+		{"dead owner", "999999999\n", 0, true},
+		{"no pid yet", "", time.Second, false},
+		{"no pid after a crash", "", time.Minute, true},
+		{"zero pid after a crash", "0\n", time.Minute, true},
+		{"garbage", "pid?", time.Minute, true},
+	} {
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-test.age), now.Add(-test.age)); err != nil {
+			t.Fatal(err)
+		}
+		if stale, _ := staleLock(path, alive, now); stale != test.stale {
+			t.Errorf("%s: stale = %t", test.name, stale)
+		}
+	}
+
+	os.Remove(path)
+	if stale, _ := staleLock(path, alive, now); !stale {
+		t.Error("a removed lock file is not stale")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package db
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// acquireLock takes an exclusive flock on the lock file next to the data file.
+// The kernel drops the lock when the owner dies, so a lock that is held always
+// belongs to a live process and is never stale, whatever PID the file has. The
+// PID is only for the message, and may still be missing if the owner has not
+// written it yet.
+func acquireLock(dbFilePath string) (*os.File, error) {
+	path := lockPath(dbFilePath)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &LockedError{Pid: readLockPid(path)}
+		}
+		return nil, err
+	}
+
+	if err := writeLockPid(file); err != nil {
+		releaseLock(file)
+		return nil, err
+	}
+	return file, nil
+}
+
+// releaseLock keeps the file in place: removing it while other processes may
+// already have it open would let two of them lock different inodes.
+func releaseLock(file *os.File) error {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return file.Close()
+}
@@ -0,0 +1,75 @@
+//go:build unix
+
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockHeldByAnotherFile(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "input.jsonl")
+
+	held, err := acquireLock(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseLock(held)
+
+	// a PID that cannot be running must not make a held lock look stale
+	err = os.WriteFile(lockPath(dbPath), []byte("999999999\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := acquireLock(dbPath)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		if file != nil {
+			releaseLock(file)
+		}
+		t.Fatalf("second lock: got %v, want LockedError", err)
+	}
+	if locked.Pid != 999999999 {
+		t.Errorf("Pid = %d, want the one in the lock file", locked.Pid)
+	}
+	if _, err := os.Stat(lockPath(dbPath)); err != nil {
+		t.Errorf("lock file of the holder was removed: %v", err)
+	}
+}
+
+func TestLockWithoutPid(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "input.jsonl")
+
+	held, err := acquireLock(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseLock(held)
+	// as if the holder had not written its PID yet
+	held.Truncate(0)
+
+	_, err = acquireLock(dbPath)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("got %v, want LockedError", err)
+	}
+}
+
+func TestLockAfterRelease(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "input.jsonl")
+
+	file, err := acquireLock(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseLock(file)
+
+	file, err = acquireLock(dbPath)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	releaseLock(file)
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/models"
+)
+
+// The memory of the indexes is measured on 1 000 000 generated students with
+// one of 401 gpa values, next to the maps of int sets and map[int]RecordInfo
+// they replaced:
+//
+//	go test -run '^$' -bench Memory -benchtime 1x ./database/db
+
+// memoryStudent returns student id, whose name is one of names different
+// ones and a new string every time, as names read from a file are.
+func memoryStudent(rng *rand.Rand, id, names int) models.Student {
+	return models.Student{
+		Id:      id,
+		Name:    fmt.Sprintf("student %d", id%names),
+		Gpa:     float64(rng.Intn(401)) / 100,
+		Active:  rng.Intn(2) == 1,
+		Version: 1,
+	}
+}
+
+// heapOf returns how many bytes of the heap what build returns takes.
+func heapOf(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	value := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(value)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+type oldIndexes struct {
+	nameIndex   map[string]map[int]bool
+	gpaIndex    map[float64]map[int]bool
+	activeIndex map[bool]*bitmap.Bitmap
+	recordInfo  map[int]models.RecordInfo
+}
+
+func buildOldIndexes(names int, recordInfoOnly bool) any {
+	rng := rand.New(rand.NewSource(1))
+	old := oldIndexes{
+		nameIndex:   make(map[string]map[int]bool),
+		gpaIndex:    make(map[float64]map[int]bool),
+		activeIndex: make(map[bool]*bitmap.Bitmap),
+		recordInfo:  make(map[int]models.RecordInfo),
+	}
+	for id := range formatStudents {
+		student := memoryStudent(rng, id, names)
+		old.recordInfo[id] = models.RecordInfo{Name: student.Name, Gpa: student.Gpa, Active: student.Active, Version: student.Version}
+		if recordInfoOnly {
+			continue
+		}
+		if old.nameIndex[student.Name] == nil {
+			old.nameIndex[student.Name] = make(map[int]bool)
+		}
+		old.nameIndex[student.Name][id] = true
+		if old.gpaIndex[student.Gpa] == nil {
+			old.gpaIndex[student.Gpa] = make(map[int]bool)
+		}
+		old.gpaIndex[student.Gpa][id] = true
+		if old.activeIndex[student.Active] == nil {
+			old.activeIndex[student.Active] = bitmap.New()
+		}
+		old.activeIndex[student.Active].Add(id)
+	}
+	return old
+}
+
+func buildIndexes(names int, recordInfoOnly bool) any {
+	rng := rand.New(rand.NewSource(1))
+	db := loadingDb("input.jsonl")
+	for id := range formatStudents {
+		student := memoryStudent(rng, id, names)
+		if recordInfoOnly {
+			db.RecordInfo.Set(id, models.RecordInfo{Name: student.Name, Gpa: student.Gpa, Active: student.Active, Version: student.Version})
+			continue
+		}
+		db.indexStudent(student)
+	}
+	return db
+}
+
+func BenchmarkIndexMemory(b *testing.B) {
+	for _, bench := range []struct {
+		name           string
+		names          int
+		recordInfoOnly bool
+	}{
+		{"names=100000", 100_000, false},
+		{"names=distinct", formatStudents, false},
+		{"record-info", formatStudents, true},
+	} {
+		for _, old := range []bool{true, false} {
+			build, version := buildIndexes, "new"
+			if old {
+				build, version = buildOldIndexes, "old"
+			}
+			b.Run(bench.name+"/"+version, func(b *testing.B) {
+				var heap uint64
+				for i := 0; i < b.N; i++ {
+					heap = heapOf(func() any { return build(bench.names, bench.recordInfoOnly) })
+				}
+				b.ReportMetric(float64(heap)/1e6, "MB")
+			})
+		}
+	}
+}
+
+// BenchmarkOpenMemory opens a JSONL file of 1 000 000 students read-only,
+// which keeps IdIndex in memory, and reports the heap the database takes.
+func BenchmarkOpenMemory(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "input.jsonl")
+	_, done := writeStudents(b, path, formatStudents)
+	done()
+	b.ResetTimer()
+
+	var heap uint64
+	for i := 0; i < b.N; i++ {
+		db := newTestDb(b, path)
+		heap = heapOf(func() any {
+			if err := db.Open(true); err != nil {
+				b.Fatal(err)
+			}
+			return db
+		})
+		db.Close()
+	}
+	b.ReportMetric(float64(heap)/1e6, "MB")
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/storage"
+)
+
+func TestMmapReadsAppendedRecords(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSONL, codec.Binary} {
+		file := storage.NewFile(filepath.Join(t.TempDir(), "input"+c.Extension()), c)
+		file.Mmap = true
+		defer file.Close()
+
+		first, err := file.Append(models.Student{Id: 1, Name: "first", Version: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if student, err := file.ReadAt(first); err != nil || student.Name != "first" {
+			t.Fatalf("%s: ReadAt = %v, %v", c.Name(), student, err)
+		}
+		// the second record lies past the end of the mapping made above
+		second, err := file.Append(models.Student{Id: 2, Name: "second", Version: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if student, err := file.ReadAt(second); err != nil || student.Name != "second" {
+			t.Fatalf("%s: ReadAt after Append = %v, %v", c.Name(), student, err)
+		}
+	}
+}
+
+// The benchmarks read random students of a JSONL database of 1 000 000 with
+// the record cache off, opening the file on every read, through the shared
+// descriptor and through the mapping:
+//
+//	go test -run '^$' -bench 'ReadAt|FindByIdMmap' -benchtime 200000x ./database/db
+var readModes = []string{"reopen", "pread", "mmap"}
+
+func openReadMode(b *testing.B, mode string) *Db {
+	db, _ := openFormat(b, ".jsonl", formatStudents)
+	db.Recorder.Cache = nil
+	file := db.Recorder.Storage.(*storage.File)
+	switch mode {
+	case "reopen":
+		db.Recorder.Storage = reopeningFile{file}
+	case "mmap":
+		file.Mmap = true
+	}
+	return db
+}
+
+func BenchmarkReadAt(b *testing.B) {
+	for _, mode := range readModes {
+		b.Run(mode, func(b *testing.B) {
+			db := openReadMode(b, mode)
+			var offsets []int64
+			for _, id := range rand.New(rand.NewSource(1)).Perm(formatStudents)[:100_000] {
+				offset, _, err := db.IdIndex.Get(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				offsets = append(offsets, offset)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := db.Recorder.Storage.ReadAt(offsets[i%len(offsets)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindByIdMmap(b *testing.B) {
+	for _, mode := range readModes[1:] {
+		b.Run(mode, func(b *testing.B) {
+			db := openReadMode(b, mode)
+			ids := rand.New(rand.NewSource(1)).Perm(formatStudents)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := db.Recorder.FindById(ids[i%len(ids)], db.IdIndex); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
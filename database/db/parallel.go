@@ -0,0 +1,157 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/scanner"
+)
+
+// Files smaller than this are parsed on one goroutine, splitting them costs
+// more than it saves.
+const parallelLoadMinSize = 4 * 1024 * 1024
+
+// A worker hands over the lines it parsed in batches of parseBatch, and may
+// run at most queuedBatches batches ahead of the goroutine filling the
+// indexes, so the parsed file is never held in memory as a whole.
+const (
+	parseBatch    = 4096
+	queuedBatches = 4
+)
+
+type parsedLine struct {
+	student models.Student
+	offset  int64
+}
+
+// indexParallel splits the file into chunks that start right after a newline
+// and decodes them on separate goroutines. The indexes are filled on this
+// goroutine while the chunks are still being parsed, chunk after chunk in
+// file order, so a student edited several times keeps its last version.
+func (db *Db) indexParallel(path string, size int64, workers int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bounds, err := chunkBounds(file, size, workers)
+	if err != nil {
+		return err
+	}
+
+	queues := make([]chan []parsedLine, len(bounds)-1)
+	errs := make([]error, len(bounds)-1)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan []parsedLine, queuedBatches)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(queues[i])
+			errs[i] = parseChunk(file, bounds[i], bounds[i+1], func(batch []parsedLine) bool {
+				select {
+				case queues[i] <- batch:
+					return true
+				case <-stop:
+					return false
+				}
+			})
+		}(i)
+	}
+
+	for i := range queues {
+		for batch := range queues[i] {
+			for _, line := range batch {
+				if err := db.applyRecord(line.offset, line.student); err != nil {
+					close(stop)
+					wg.Wait()
+					return err
+				}
+			}
+		}
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// chunkBounds returns n+1 offsets, every inner one is the start of a line.
+func chunkBounds(file *os.File, size int64, n int) ([]int64, error) {
+	bounds := []int64{0}
+	buf := make([]byte, 4096)
+
+	for i := 1; i < n; i++ {
+		pos := max(size*int64(i)/int64(n), bounds[len(bounds)-1])
+		for pos < size {
+			read, err := file.ReadAt(buf, pos)
+			if idx := bytes.IndexByte(buf[:read], '\n'); idx >= 0 {
+				pos += int64(idx) + 1
+				break
+			}
+			pos += int64(read)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if pos >= size {
+			break
+		}
+		if pos > bounds[len(bounds)-1] {
+			bounds = append(bounds, pos)
+		}
+	}
+
+	return append(bounds, size), nil
+}
+
+// parseChunk decodes the lines between start and end and passes them to emit
+// in batches, until emit returns false.
+func parseChunk(file *os.File, start, end int64, emit func([]parsedLine) bool) error {
+	var skipped []error
+	batch := make([]parsedLine, 0, parseBatch)
+
+	reader := codec.JSONL.NewReader(io.NewSectionReader(file, start, end-start), start)
+
+	for {
+		student, offset, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var tooLarge *scanner.RecordTooLargeError
+		if errors.As(err, &tooLarge) {
+			skipped = append(skipped, err)
+			continue
+		}
+		var decodeErr *codec.DecodeError
+		if errors.As(err, &decodeErr) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, parsedLine{student: student, offset: offset})
+		if len(batch) == parseBatch {
+			if !emit(batch) {
+				return nil
+			}
+			batch = make([]parsedLine, 0, parseBatch)
+		}
+	}
+
+	if len(batch) > 0 {
+		emit(batch)
+	}
+	return errors.Join(skipped...)
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/recorder"
+	"github.com/kgugunava/database/scanner"
+	"github.com/kgugunava/database/storage"
+)
+
+// writeStudents writes students with ids [0, n) to path in the codec of its
+// extension and returns the writer to append more records with.
+func writeStudents(t testing.TB, path string, n int) (write func(models.Student), done func() int64) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriterSize(file, 1024*1024)
+	c := codec.ForPath(path)
+
+	write = func(student models.Student) {
+		data, err := c.Encode(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	done = func() int64 {
+		defer file.Close()
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	for id := 0; id < n; id++ {
+		write(models.Student{Id: id, Name: fmt.Sprintf("student %d", id), Gpa: float64(id%50) / 10, Active: id%3 == 0, Version: 1})
+	}
+	return write, done
+}
+
+// writeDataFile writes n students as a JSONL data file, every tenth of them
+// edited later in the file and every hundredth deleted, as a real log has.
+func writeDataFile(t testing.TB, path string, n int) int64 {
+	t.Helper()
+	write, done := writeStudents(t, path, n)
+	for id := 0; id < n; id += 10 {
+		write(models.Student{Id: id, Name: fmt.Sprintf("edited %d", id), Gpa: 4.5, Active: true, Version: 2})
+	}
+	for id := 5; id < n; id += 100 {
+		write(models.Student{Id: id, Version: 2, Deleted: true})
+	}
+	return done()
+}
+
+// loadingDb is a Db over the data file at path with empty indexes.
+func loadingDb(path string) *Db {
+	s := scanner.NewScanner()
+	r := recorder.NewRecorder(s)
+	r.Storage = storage.NewFile(path, codec.JSONL)
+	return NewDb(path, s, r)
+}
+
+func TestIndexParallelMatchesSequential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	size := writeDataFile(t, path, 20000)
+
+	sequential := loadingDb(path)
+	if err := sequential.Recorder.Storage.Iterate(sequential.applyRecord); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := sequential.Select(Query{})
+
+	for _, workers := range []int{2, 3, 8} {
+		parallel := loadingDb(path)
+		if err := parallel.indexParallel(path, size, workers); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := parallel.Select(Query{})
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%d workers: indexes differ from the sequential load", workers)
+		}
+		for id := 0; id < 20000; id += 997 {
+			wantOffset, wantOk, _ := sequential.IdIndex.Get(id)
+			gotOffset, gotOk, _ := parallel.IdIndex.Get(id)
+			if wantOffset != gotOffset || wantOk != gotOk {
+				t.Fatalf("%d workers: offset of %d is %d, want %d", workers, id, gotOffset, wantOffset)
+			}
+		}
+	}
+}
+
+// The data file has 200 000 students, about 15 MB, well above
+// parallelLoadMinSize.
+const benchmarkStudents = 200000
+
+func benchmarkLoadIndex(b *testing.B, load func(db *Db, path string, size int64) error) {
+	path := filepath.Join(b.TempDir(), "input.jsonl")
+	size := writeDataFile(b, path, benchmarkStudents)
+	b.SetBytes(size)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db := loadingDb(path)
+		b.StartTimer()
+		if err := load(db, path, size); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadIndexSequential(b *testing.B) {
+	benchmarkLoadIndex(b, func(db *Db, path string, size int64) error {
+		return db.Recorder.Storage.Iterate(db.applyRecord)
+	})
+}
+
+func BenchmarkLoadIndexChunked(b *testing.B) {
+	for _, workers := range []int{2, 4, runtime.NumCPU()} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkLoadIndex(b, func(db *Db, path string, size int64) error {
+				return db.indexParallel(path, size, workers)
+			})
+		})
+	}
+}
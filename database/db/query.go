@@ -0,0 +1,129 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/models"
+)
+
+// Query picks and orders the live students for an export. The zero Query is
+// every student by id.
+type Query struct {
+	Ids     *bitmap.Bitmap             // only these ids, every student when nil
+	Where   func(models.Student) bool // further filter, optional
+	OrderBy string                     // one of OrderFields, id when empty
+	Desc    bool
+}
+
+// OrderFields are the fields a Query can be ordered by.
+var OrderFields = []string{"id", "name", "gpa", "active", "version"}
+
+// Select returns the students matching query. They come from RecordInfo,
+// which holds every field of a live student, so the data file is not read.
+func (db *Db) Select(query Query) ([]models.Student, error) {
+	ids, err := db.selectIds(query)
+	if err != nil {
+		return nil, err
+	}
+
+	students := make([]models.Student, len(ids))
+	for i, id := range ids {
+		students[i] = db.student(id)
+	}
+	return students, nil
+}
+
+// selectIds is Select for a caller that goes through the students one by
+// one, e.g. an export of the whole table: it keeps only their ids, and the
+// students are made from RecordInfo as they are needed.
+func (db *Db) selectIds(query Query) ([]int, error) {
+	orderBy := query.OrderBy
+	if orderBy == "" {
+		orderBy = "id"
+	}
+	if !slices.Contains(OrderFields, orderBy) {
+		return nil, fmt.Errorf("cannot order by unknown field %q", orderBy)
+	}
+
+	var ids []int
+	add := func(id int, info models.RecordInfo) {
+		if query.Where == nil || query.Where(info.Student(id)) {
+			ids = append(ids, id)
+		}
+	}
+	if query.Ids != nil {
+		ids = make([]int, 0, query.Ids.Len())
+		query.Ids.Iterate(func(id int) bool {
+			if info, exists := db.RecordInfo.Get(id); exists {
+				add(id, info)
+			}
+			return true
+		})
+	} else {
+		ids = make([]int, 0, db.RecordInfo.Len())
+		for id, info := range db.RecordInfo.All() {
+			add(id, info)
+		}
+	}
+
+	switch orderBy {
+	case "id":
+		slices.Sort(ids)
+		if query.Desc {
+			slices.Reverse(ids)
+		}
+	case "name":
+		sortByKey(db, ids, query.Desc, func(info models.RecordInfo) string { return info.Name })
+	case "gpa":
+		sortByKey(db, ids, query.Desc, func(info models.RecordInfo) float64 { return info.Gpa })
+	case "active":
+		sortByKey(db, ids, query.Desc, func(info models.RecordInfo) int { return boolKey(info.Active) })
+	case "version":
+		sortByKey(db, ids, query.Desc, func(info models.RecordInfo) int { return info.Version })
+	}
+	return ids, nil
+}
+
+// sortByKey orders ids by a field of their RecordInfo, which is looked up
+// once per id rather than on every comparison. Ties are ordered by id, so
+// equal keys come out the same every time.
+func sortByKey[K cmp.Ordered](db *Db, ids []int, desc bool, key func(models.RecordInfo) K) {
+	type keyed struct {
+		key K
+		id  int
+	}
+	rows := make([]keyed, len(ids))
+	for i, id := range ids {
+		info, _ := db.RecordInfo.Get(id)
+		rows[i] = keyed{key(info), id}
+	}
+	slices.SortFunc(rows, func(a, b keyed) int {
+		c := cmp.Compare(a.key, b.key)
+		if c == 0 {
+			c = cmp.Compare(a.id, b.id)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+	for i, row := range rows {
+		ids[i] = row.id
+	}
+}
+
+func boolKey(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// student makes a live student of RecordInfo.
+func (db *Db) student(id int) models.Student {
+	info, _ := db.RecordInfo.Get(id)
+	return info.Student(id)
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/kgugunava/database/models"
+)
+
+// The snapshot stores RecordInfo together with the offset of every live
+// student, which is enough to rebuild all indexes without parsing JSON.
+//
+// Layout (little endian):
+//
+//	magic "SIDX" | format uint32 | data size int64 | data crc uint32 | count uint64
+//	count x (id varint | offset uvarint | version uvarint | name len uvarint | name | gpa float64 bits | active byte)
+//	crc32 of everything above uint32
+const (
+	snapshotMagic   = "SIDX"
+	snapshotFormat  = 2
+	snapshotTrailer = 4
+)
+
+var errSnapshotInvalid = errors.New("index snapshot does not match the data file")
+
+func snapshotPath(dbFilePath string) string {
+	return dbFilePath + ".idx"
+}
+
+// dataChecksum is the CRC32 of the first size bytes of the data file. The
+// whole part the snapshot covers is read, so a change anywhere in it is
+// noticed; crc32 reads much faster than the records can be parsed.
+func dataChecksum(file *os.File, size int64) (uint32, error) {
+	return checksumSection(file, 0, size, 0)
+}
+
+// SaveSnapshot writes the current indexes next to the data file. The file is
+// replaced atomically, so a crash leaves either the old or the new snapshot.
+func (db *Db) SaveSnapshot() error {
+	data, err := os.Open(db.FilePath)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	info, err := data.Stat()
+	if err != nil {
+		return err
+	}
+
+	checksum, err := dataChecksum(data, info.Size())
+	if err != nil {
+		return err
+	}
+
+	path := snapshotPath(db.FilePath)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := tmp.Chmod(0644); err != nil {
+		return err
+	}
+
+	hash := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(tmp, hash))
+
+	w.WriteString(snapshotMagic)
+	binary.Write(w, binary.LittleEndian, uint32(snapshotFormat))
+	binary.Write(w, binary.LittleEndian, info.Size())
+	binary.Write(w, binary.LittleEndian, checksum)
+	binary.Write(w, binary.LittleEndian, uint64(db.IdIndex.Len()))
+
+	// entries go in id order, so the id index can be bulk loaded from them
+	buf := make([]byte, binary.MaxVarintLen64)
+	entries, entriesErr := db.IdIndex.All()
+	for id, offset := range entries {
+		rec, _ := db.RecordInfo.Get(id)
+		w.Write(buf[:binary.PutVarint(buf, int64(id))])
+		w.Write(buf[:binary.PutUvarint(buf, uint64(offset))])
+		w.Write(buf[:binary.PutUvarint(buf, uint64(rec.Version))])
+		w.Write(buf[:binary.PutUvarint(buf, uint64(len(rec.Name)))])
+		w.WriteString(rec.Name)
+		binary.Write(w, binary.LittleEndian, math.Float64bits(rec.Gpa))
+		if rec.Active {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	}
+
+	if err := entriesErr(); err != nil {
+		return fmt.Errorf("error reading id index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := binary.Write(tmp, binary.LittleEndian, hash.Sum32()); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	if err := db.IdIndex.SetMeta(dataStamp(info.Size(), checksum)); err != nil {
+		return err
+	}
+	return db.IdIndex.Commit()
+}
+
+// loadSnapshot fills the indexes from the snapshot and returns how many bytes
+// of the data file it covers. Lines after that point still have to be indexed.
+// The id index tree is reused when it was saved together with the snapshot;
+// RecordInfo and the other indexes are decoded from it as a whole.
+func (db *Db) loadSnapshot() (int64, error) {
+	raw, err := os.ReadFile(snapshotPath(db.FilePath))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(raw) < len(snapshotMagic)+snapshotTrailer || string(raw[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, errSnapshotInvalid
+	}
+	body := raw[:len(raw)-snapshotTrailer]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(raw[len(body):]) {
+		return 0, errSnapshotInvalid
+	}
+
+	r := bytes.NewReader(body[len(snapshotMagic):])
+	var header struct {
+		Format   uint32
+		Size     int64
+		Checksum uint32
+		Count    uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil || header.Format != snapshotFormat {
+		return 0, errSnapshotInvalid
+	}
+
+	data, err := os.Open(db.FilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer data.Close()
+
+	info, err := data.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() < header.Size {
+		return 0, errSnapshotInvalid
+	}
+	checksum, err := dataChecksum(data, header.Size)
+	if err != nil {
+		return 0, err
+	}
+	if checksum != header.Checksum {
+		return 0, errSnapshotInvalid
+	}
+
+	offsets := make([]idOffset, 0, header.Count)
+	db.RecordInfo = models.NewRecordTable(int(header.Count))
+	db.Recorder.Indexes.Clear()
+	db.Recorder.Cache.Clear()
+
+	for i := uint64(0); i < header.Count; i++ {
+		id, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, errSnapshotInvalid
+		}
+		offset, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, errSnapshotInvalid
+		}
+		version, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, errSnapshotInvalid
+		}
+		nameLen, err := binary.ReadUvarint(r)
+		if err != nil || nameLen > uint64(r.Len()) {
+			return 0, errSnapshotInvalid
+		}
+		name := make([]byte, nameLen)
+		io.ReadFull(r, name)
+		var gpaBits uint64
+		if err := binary.Read(r, binary.LittleEndian, &gpaBits); err != nil {
+			return 0, errSnapshotInvalid
+		}
+		active, err := r.ReadByte()
+		if err != nil {
+			return 0, errSnapshotInvalid
+		}
+
+		db.indexStudent(models.Student{
+			Id:      int(id),
+			Name:    string(name),
+			Gpa:     math.Float64frombits(gpaBits),
+			Active:  active == 1,
+			Version: int(version),
+		})
+		offsets = append(offsets, idOffset{int(id), int64(offset)})
+	}
+
+	if err := db.attachIdIndex(dataStamp(header.Size, header.Checksum), offsets); err != nil {
+		return 0, err
+	}
+
+	return header.Size, nil
+}
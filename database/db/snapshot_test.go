@@ -0,0 +1,156 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/recorder"
+	"github.com/kgugunava/database/scanner"
+)
+
+func newTestDb(t testing.TB, path string) *Db {
+	t.Helper()
+	s := scanner.NewScanner()
+	return NewDb(path, s, recorder.NewRecorder(s))
+}
+
+func addStudents(t testing.TB, db *Db, from, to int) {
+	t.Helper()
+	var records []models.Record
+	for id := from; id < to; id++ {
+		records = append(records, db.Recorder.MakeNewRecord(models.Student{
+			Id:     id,
+			Name:   fmt.Sprintf("student %d", id),
+			Gpa:    float64(id%50) / 10,
+			Active: id%3 == 0,
+		}))
+	}
+	_, err := db.Recorder.AddNewRecordsFromList(records, db.IdIndex, db.RecordInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotNotSavedAfterFailedOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	db := newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 100)
+	// no snapshot yet, so the next Open has to load the data file
+	db.Recorder.Storage.Close()
+	db.IdIndex.Close()
+	releaseLock(db.lockFile)
+
+	// the id index cannot be stored, which fails Open after the records
+	// were read
+	os.Remove(idIndexPath(path))
+	if err := os.Mkdir(idIndexPath(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db = newTestDb(t, path)
+	if err := db.Open(false); err == nil {
+		db.Close()
+		t.Fatal("Open succeeded without a place for the id index")
+	}
+	if _, err := os.Stat(snapshotPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("failed Open left a snapshot behind: %v", err)
+	}
+
+	os.Remove(idIndexPath(path))
+	db = newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	if db.RecordInfo.Len() != 100 {
+		t.Errorf("loaded %d students, want 100", db.RecordInfo.Len())
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(snapshotPath(path)); err != nil {
+		t.Fatalf("no snapshot after a clean Close: %v", err)
+	}
+}
+
+func TestSnapshotNoticesEditInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	db := newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	addStudents(t, db, 0, 5000)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// far from both ends of the file, and the size stays the same
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := bytes.Replace(data, []byte(`"student 2500"`), []byte(`"STUDENT 2500"`), 1)
+	if bytes.Equal(edited, data) {
+		t.Fatal("student 2500 is not in the file")
+	}
+	if err := os.WriteFile(path, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = newTestDb(t, path)
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if info, _ := db.RecordInfo.Get(2500); info.Name != "STUDENT 2500" {
+		t.Fatalf("indexes have %q, the snapshot was used for a changed file", info.Name)
+	}
+}
+
+// BenchmarkOpenSnapshot opens a JSONL database of 1 000 000 students from its
+// snapshot, the checksum of the data file included:
+//
+//	go test -run '^$' -bench OpenSnapshot -benchtime 5x ./database/db
+func BenchmarkOpenSnapshot(b *testing.B) {
+	db, _ := openFormat(b, ".jsonl", formatStudents)
+	if err := db.Close(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		db = newTestDb(b, db.FilePath)
+		if err := db.Open(false); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		if err := db.Close(); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
+
+func BenchmarkDataChecksum(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "input.jsonl")
+	_, done := writeStudents(b, path, formatStudents)
+	size := done()
+	file, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	b.SetBytes(size)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := dataChecksum(file, size); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
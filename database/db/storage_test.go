@@ -0,0 +1,141 @@
+package db
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/storage"
+)
+
+// TestRecorderOnEveryStorage runs the same changes through the Recorder on
+// every storage backend and checks that each one returns the same students,
+// before and after the database is opened again.
+func TestRecorderOnEveryStorage(t *testing.T) {
+	for _, backend := range []struct {
+		name string
+		file string
+		// memory is handed to every Db of the test, as it cannot be reopened
+		memory bool
+	}{
+		{"jsonl", "input.jsonl", false},
+		{"binary", "input.sdb", false},
+		{"paged", "input" + storage.PagedExtension, false},
+		{"memory", "input.jsonl", true},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), backend.file)
+			memory := storage.NewMemory()
+			open := func() *Db {
+				t.Helper()
+				db := newTestDb(t, path)
+				if backend.memory {
+					db.Recorder.Storage = memory
+				}
+				if err := db.Open(false); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { db.Close() })
+				return db
+			}
+			reopen := func(db *Db) *Db {
+				t.Helper()
+				if err := db.Close(); err != nil {
+					t.Fatal(err)
+				}
+				return open()
+			}
+
+			want := make(map[int]models.Student)
+			db := open()
+			addStudents(t, db, 0, 20)
+			for id := range 20 {
+				info, _ := db.RecordInfo.Get(id)
+				want[id] = info.Student(id)
+			}
+
+			edit := func(db *Db, id int, name string) {
+				t.Helper()
+				student := want[id]
+				student.Name = name
+				if err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(student), student.Version, db.IdIndex, db.RecordInfo); err != nil {
+					t.Fatal(err)
+				}
+				student.Version++
+				want[id] = student
+			}
+			remove := func(db *Db, id int) {
+				t.Helper()
+				if err := db.Recorder.DeleteRecordById(id, db.IdIndex, db.RecordInfo); err != nil {
+					t.Fatal(err)
+				}
+				delete(want, id)
+			}
+
+			// a longer name does not fit where the record was
+			edit(db, 3, "a much longer name than the one the student had before")
+			edit(db, 4, "x")
+			remove(db, 5)
+			remove(db, 7)
+			checkStudents(t, db, want)
+
+			db = reopen(db)
+			checkStudents(t, db, want)
+
+			edit(db, 3, "renamed again")
+			remove(db, 0)
+			added := models.Student{Id: 5, Name: "back again", Gpa: 2.5, Active: true}
+			if err := db.Recorder.AddNewRecord(db.Recorder.MakeNewRecord(added), db.IdIndex, db.RecordInfo); err != nil {
+				t.Fatal(err)
+			}
+			added.Version = 1
+			want[5] = added
+			checkStudents(t, db, want)
+
+			db = reopen(db)
+			checkStudents(t, db, want)
+		})
+	}
+}
+
+func checkStudents(t *testing.T, db *Db, want map[int]models.Student) {
+	t.Helper()
+
+	ids := slices.Sorted(maps.Keys(want))
+	var wantList []models.Student
+	for _, id := range ids {
+		wantList = append(wantList, want[id])
+	}
+	if got := selectAll(t, db); !slices.Equal(got, wantList) {
+		t.Fatalf("Select returned %v, want %v", got, wantList)
+	}
+
+	for id := range 21 {
+		student, err := db.Recorder.FindById(id, db.IdIndex)
+		wanted, exists := want[id]
+		if !exists {
+			if err == nil {
+				t.Fatalf("deleted student %d was found", id)
+			}
+			continue
+		}
+		if err != nil || *student != wanted {
+			t.Fatalf("FindById(%d) = %v, %v, want %v", id, student, err, wanted)
+		}
+
+		named, err := db.Recorder.FindByName(wanted.Name, db.IdIndex)
+		if err != nil || !slices.Contains(named, wanted) {
+			t.Fatalf("FindByName(%q) = %v, %v", wanted.Name, named, err)
+		}
+	}
+
+	ranged, err := db.Recorder.FindByIdRange(0, 9, db.IdIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := slices.IndexFunc(wantList, func(s models.Student) bool { return s.Id > 9 }); !slices.Equal(ranged, wantList[:n]) {
+		t.Fatalf("FindByIdRange(0, 9) = %v", ranged)
+	}
+}
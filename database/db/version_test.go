@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/recorder"
+)
+
+func TestEditStaleVersion(t *testing.T) {
+	db := newTestDb(t, filepath.Join(t.TempDir(), "input.jsonl"))
+	if err := db.Open(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	addStudents(t, db, 0, 10)
+
+	// two users load the same student, the first one saves
+	read, err := db.Recorder.FindById(4, db.IdIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Version != 1 {
+		t.Fatalf("a new student has version %d", read.Version)
+	}
+	first, second := *read, *read
+	first.Name = "first"
+	second.Name = "second"
+	if err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(first), read.Version, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Recorder.EditRecord(db.Recorder.MakeNewRecord(second), read.Version, db.IdIndex, db.RecordInfo)
+	var conflict *recorder.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("stale edit returned %v", err)
+	}
+	if conflict.Id != 4 || conflict.ExpectedVersion != 1 || conflict.Current.Version != 2 || conflict.Current.Name != "first" {
+		t.Fatalf("conflict is %+v", conflict)
+	}
+	if stored, _ := db.Recorder.FindById(4, db.IdIndex); stored.Name != "first" || stored.Version != 2 {
+		t.Fatalf("the stale edit changed the student to %+v", stored)
+	}
+
+	// overwriting on purpose goes through with the current version
+	if err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(second), conflict.Current.Version, db.IdIndex, db.RecordInfo); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := db.Recorder.FindById(4, db.IdIndex); stored.Name != "second" || stored.Version != 3 {
+		t.Fatalf("after overwriting the student is %+v", stored)
+	}
+}
+
+func TestVersionSurvivesReload(t *testing.T) {
+	for _, ext := range formatExtensions {
+		t.Run(ext, func(t *testing.T) {
+			db := newTestDb(t, filepath.Join(t.TempDir(), "input"+ext))
+			if err := db.Open(false); err != nil {
+				t.Fatal(err)
+			}
+			addStudents(t, db, 0, 10)
+			for version := 1; version < 4; version++ {
+				student, err := db.Recorder.FindById(2, db.IdIndex)
+				if err != nil {
+					t.Fatal(err)
+				}
+				student.Gpa = float64(version)
+				if err := db.Recorder.EditRecord(db.Recorder.MakeNewRecord(*student), version, db.IdIndex, db.RecordInfo); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			check := func(db *Db) {
+				t.Helper()
+				student, err := db.Recorder.FindById(2, db.IdIndex)
+				if err != nil {
+					t.Fatal(err)
+				}
+				info, _ := db.RecordInfo.Get(2)
+				if student.Version != 4 || info.Version != 4 || student.Gpa != 3 {
+					t.Fatalf("after reloading the student is %+v, RecordInfo has version %d", student, info.Version)
+				}
+				if other, _ := db.Recorder.FindById(3, db.IdIndex); other.Version != 1 {
+					t.Fatalf("an unedited student has version %d", other.Version)
+				}
+				err = db.Recorder.EditRecord(db.Recorder.MakeNewRecord(*student), 3, db.IdIndex, db.RecordInfo)
+				var conflict *recorder.ConflictError
+				if !errors.As(err, &conflict) {
+					t.Fatalf("edit with an old version after reloading returned %v", err)
+				}
+			}
+
+			// from the snapshot, then from the data file alone
+			db = reopen(t, db)
+			check(db)
+			os.Remove(snapshotPath(db.FilePath))
+			db = reopen(t, db)
+			check(db)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Package idlist keeps the ids of one index bucket as a sorted slice. A bucket
+// with a handful of ids then costs 8 bytes per id instead of a whole
+// map[int]bool.
+package idlist
+
+import "slices"
+
+// Add inserts id into the sorted ids and returns the updated slice.
+func Add(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
+
+// Remove deletes id from the sorted ids and returns the updated slice. It
+// shifts the elements in place, so callers that iterate over the slice while
+// removing must iterate over a copy.
+func Remove(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if !found {
+		return ids
+	}
+	ids = slices.Delete(ids, i, i+1)
+	// give the memory back once most of the bucket is gone
+	if cap(ids) > 16 && len(ids) < cap(ids)/4 {
+		ids = slices.Clone(ids)
+	}
+	return ids
+}
@@ -0,0 +1,60 @@
+package index
+
+import (
+	"github.com/kgugunava/database/bitmap"
+)
+
+// BitmapIndex keeps a compressed bitmap of ids per value. It suits fields
+// with few distinct values, where a set per value holds many ids.
+type BitmapIndex struct {
+	ids map[any]*bitmap.Bitmap
+}
+
+func NewBitmap() *BitmapIndex {
+	return &BitmapIndex{ids: make(map[any]*bitmap.Bitmap)}
+}
+
+func (b *BitmapIndex) Kind() Kind {
+	return Bitmap
+}
+
+func (b *BitmapIndex) Add(value any, id int) {
+	if b.ids[value] == nil {
+		b.ids[value] = bitmap.New()
+	}
+	b.ids[value].Add(id)
+}
+
+func (b *BitmapIndex) Remove(value any, id int) {
+	ids := b.ids[value]
+	if ids == nil {
+		return
+	}
+	ids.Remove(id)
+	if ids.Len() == 0 {
+		delete(b.ids, value)
+	}
+}
+
+func (b *BitmapIndex) Lookup(value any) []int {
+	if b.ids[value] == nil {
+		return nil
+	}
+	return b.ids[value].Ids()
+}
+
+// Bitmap returns the ids with value, the caller must not change it.
+func (b *BitmapIndex) Bitmap(value any) *bitmap.Bitmap {
+	if b.ids[value] == nil {
+		return bitmap.New()
+	}
+	return b.ids[value]
+}
+
+func (b *BitmapIndex) Values() int {
+	return len(b.ids)
+}
+
+func (b *BitmapIndex) Clear() {
+	b.ids = make(map[any]*bitmap.Bitmap)
+}
@@ -0,0 +1,55 @@
+package index
+
+import (
+	"slices"
+
+	"github.com/kgugunava/database/idlist"
+)
+
+// HashIndex is a map from value to the sorted ids that have it. K is any for
+// indexes created at runtime; the built-in name index uses string, which
+// saves boxing every distinct name into an interface.
+type HashIndex[K comparable] struct {
+	ids map[K][]int
+}
+
+func NewHash[K comparable]() *HashIndex[K] {
+	return &HashIndex[K]{ids: make(map[K][]int)}
+}
+
+func (h *HashIndex[K]) Kind() Kind {
+	return Hash
+}
+
+func (h *HashIndex[K]) Add(value any, id int) {
+	key := value.(K)
+	h.ids[key] = idlist.Add(h.ids[key], id)
+}
+
+func (h *HashIndex[K]) Remove(value any, id int) {
+	key := value.(K)
+	ids := idlist.Remove(h.ids[key], id)
+	if len(ids) == 0 {
+		delete(h.ids, key)
+		return
+	}
+	h.ids[key] = ids
+}
+
+// Lookup returns a copy, the bucket changes while its students are deleted.
+func (h *HashIndex[K]) Lookup(value any) []int {
+	return slices.Clone(h.ids[value.(K)])
+}
+
+// Bucket is Lookup without the copy, the caller must not change it.
+func (h *HashIndex[K]) Bucket(value any) []int {
+	return h.ids[value.(K)]
+}
+
+func (h *HashIndex[K]) Values() int {
+	return len(h.ids)
+}
+
+func (h *HashIndex[K]) Clear() {
+	h.ids = make(map[K][]int)
+}
@@ -0,0 +1,146 @@
+// Package index holds the secondary indexes over student fields: the built-in
+// ones on name, gpa and active and those created and dropped at runtime.
+package index
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/kgugunava/database/models"
+)
+
+type Kind string
+
+const (
+	// Hash answers lookups by an exact value.
+	Hash Kind = "hash"
+	// Ordered also answers range queries, its values are kept sorted.
+	Ordered Kind = "ordered"
+	// Bitmap stores compressed id sets, for fields with few distinct values.
+	Bitmap Kind = "bitmap"
+)
+
+// Kinds lists the index kinds in the order they are offered to the user.
+var Kinds = []Kind{Hash, Ordered, Bitmap}
+
+// Index maps field values to the ids of the students that have them.
+type Index interface {
+	Kind() Kind
+	Add(value any, id int)
+	Remove(value any, id int)
+	// Lookup returns the ids with the value in ascending order.
+	Lookup(value any) []int
+	// Values is the number of distinct values.
+	Values() int
+	Clear()
+}
+
+// RangeIndex is an index that can also answer from <= value <= to.
+type RangeIndex interface {
+	Index
+	Range(from, to any) []int
+}
+
+// Field describes a student field an index can be built on. A new field of
+// models.Student only needs an entry in Fields.
+type Field struct {
+	Value func(models.Student) any
+	Parse func(string) (any, error)
+}
+
+var Fields = map[string]Field{
+	"name": {
+		Value: func(s models.Student) any { return s.Name },
+		Parse: func(text string) (any, error) { return text, nil },
+	},
+	"gpa": {
+		Value: func(s models.Student) any { return s.Gpa },
+		Parse: func(text string) (any, error) { return strconv.ParseFloat(text, 64) },
+	},
+	"active": {
+		Value: func(s models.Student) any { return s.Active },
+		Parse: func(text string) (any, error) { return strconv.ParseBool(text) },
+	},
+	"version": {
+		Value: func(s models.Student) any { return s.Version },
+		Parse: func(text string) (any, error) { return strconv.Atoi(text) },
+	},
+}
+
+// FieldNames returns the indexable fields in alphabetical order.
+func FieldNames() []string {
+	names := make([]string, 0, len(Fields))
+	for name := range Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseValue converts text typed by the user into a value of field.
+func ParseValue(field, text string) (any, error) {
+	f, ok := Fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	value, err := f.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q: %w", field, text, err)
+	}
+	return value, nil
+}
+
+func New(kind Kind) (Index, error) {
+	switch kind {
+	case Hash:
+		return NewHash[any](), nil
+	case Ordered:
+		return NewOrdered(), nil
+	case Bitmap:
+		return NewBitmap(), nil
+	}
+	return nil, fmt.Errorf("unknown index kind %q", kind)
+}
+
+// orderable reports whether compare can order values like value.
+func orderable(value any) bool {
+	switch value.(type) {
+	case string, float64, int, bool:
+		return true
+	}
+	return false
+}
+
+// compare orders values of one field, false sorts before true. Registry.Create
+// only builds ordered indexes on fields that are orderable.
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return compareOrdered(a, b.(string))
+	case float64:
+		return compareOrdered(a, b.(float64))
+	case int:
+		return compareOrdered(a, b.(int))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	}
+	panic(fmt.Sprintf("index: unsupported value type %T", a))
+}
+
+func compareOrdered[T string | float64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
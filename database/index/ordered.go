@@ -0,0 +1,57 @@
+package index
+
+import (
+	"slices"
+	"sort"
+)
+
+// OrderedIndex keeps the distinct values in a sorted slice next to the hash
+// of ids, so a range query is a binary search followed by a walk over the
+// values in range. Adding a new distinct value costs O(values).
+type OrderedIndex struct {
+	HashIndex[any]
+	values []any
+}
+
+func NewOrdered() *OrderedIndex {
+	return &OrderedIndex{HashIndex: *NewHash[any]()}
+}
+
+func (o *OrderedIndex) Kind() Kind {
+	return Ordered
+}
+
+// search returns the position of the first value >= value.
+func (o *OrderedIndex) search(value any) int {
+	return sort.Search(len(o.values), func(i int) bool { return compare(o.values[i], value) >= 0 })
+}
+
+func (o *OrderedIndex) Add(value any, id int) {
+	if _, exists := o.ids[value]; !exists {
+		o.values = slices.Insert(o.values, o.search(value), value)
+	}
+	o.HashIndex.Add(value, id)
+}
+
+func (o *OrderedIndex) Remove(value any, id int) {
+	o.HashIndex.Remove(value, id)
+	if _, exists := o.ids[value]; exists {
+		return
+	}
+	if i := o.search(value); i < len(o.values) && compare(o.values[i], value) == 0 {
+		o.values = slices.Delete(o.values, i, i+1)
+	}
+}
+
+func (o *OrderedIndex) Range(from, to any) []int {
+	var ids []int
+	for i := o.search(from); i < len(o.values) && compare(o.values[i], to) <= 0; i++ {
+		ids = append(ids, o.HashIndex.Lookup(o.values[i])...)
+	}
+	return ids
+}
+
+func (o *OrderedIndex) Clear() {
+	o.HashIndex.Clear()
+	o.values = nil
+}
@@ -0,0 +1,197 @@
+package index
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/models"
+)
+
+// Definition is what is needed to recreate an index, without its contents.
+type Definition struct {
+	Field   string `json:"field"`
+	Kind    Kind   `json:"kind"`
+	Builtin bool   `json:"-"`
+}
+
+// Builtin are the indexes every Registry starts with, the ones searched by
+// name, GPA and active. One can be replaced by an index of another kind, and
+// dropping that brings the built-in one back.
+var Builtin = []Definition{
+	{Field: "name", Kind: Hash, Builtin: true},
+	{Field: "gpa", Kind: Bitmap, Builtin: true},
+	{Field: "active", Kind: Bitmap, Builtin: true},
+}
+
+// Registry holds the indexes, at most one per field. The Recorder keeps every
+// index in it up to date through Add and Remove.
+type Registry struct {
+	indexes map[string]Index
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{indexes: make(map[string]Index)}
+	for _, def := range Builtin {
+		r.indexes[def.Field] = newBuiltin(def)
+	}
+	return r
+}
+
+func newBuiltin(def Definition) Index {
+	if def.Field == "name" {
+		return NewHash[string]()
+	}
+	idx, _ := New(def.Kind)
+	return idx
+}
+
+// builtin returns the built-in definition for field.
+func builtin(field string) (Definition, bool) {
+	for _, def := range Builtin {
+		if def.Field == field {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// isBuiltin reports whether idx is the built-in index on field rather than
+// one that replaced it.
+func isBuiltin(field string, idx Index) bool {
+	def, ok := builtin(field)
+	return ok && idx.Kind() == def.Kind
+}
+
+// Create adds an empty index on field, the caller fills it with Add. An index
+// of another kind replaces the built-in one on its field.
+func (r *Registry) Create(field string, kind Kind) (Index, error) {
+	f, ok := Fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	if idx, exists := r.indexes[field]; exists && (!isBuiltin(field, idx) || idx.Kind() == kind) {
+		return nil, fmt.Errorf("index on %s already exists", field)
+	}
+
+	value := f.Value(models.Student{})
+	if !reflect.TypeOf(value).Comparable() {
+		return nil, fmt.Errorf("cannot index %s: values of type %T cannot be compared", field, value)
+	}
+	if kind == Ordered && !orderable(value) {
+		return nil, fmt.Errorf("cannot build an ordered index on %s: values of type %T cannot be ordered", field, value)
+	}
+
+	idx, err := New(kind)
+	if err != nil {
+		return nil, err
+	}
+	r.indexes[field] = idx
+	return idx, nil
+}
+
+// Drop removes the index on field. When it replaced a built-in one, a new
+// empty built-in index takes its place and is returned for the caller to fill.
+func (r *Registry) Drop(field string) (Index, error) {
+	idx, exists := r.indexes[field]
+	if !exists {
+		return nil, fmt.Errorf("no index on %s", field)
+	}
+	if isBuiltin(field, idx) {
+		return nil, fmt.Errorf("index on %s is built in and cannot be dropped", field)
+	}
+
+	if def, ok := builtin(field); ok {
+		r.indexes[field] = newBuiltin(def)
+		return r.indexes[field], nil
+	}
+	delete(r.indexes, field)
+	return nil, nil
+}
+
+func (r *Registry) Get(field string) (Index, bool) {
+	if r == nil {
+		return nil, false
+	}
+	idx, ok := r.indexes[field]
+	return idx, ok
+}
+
+// Lookup returns the ids with value in the index on field, none when there
+// is no such index.
+func (r *Registry) Lookup(field string, value any) []int {
+	idx, exists := r.Get(field)
+	if !exists {
+		return nil
+	}
+	return idx.Lookup(value)
+}
+
+// Bitmap returns the ids with value as a bitmap to combine with others via
+// bitmap.And, Or and AndNot. Bitmap indexes return their own set, which the
+// caller must not change, other kinds build one from Lookup.
+func (r *Registry) Bitmap(field string, value any) (*bitmap.Bitmap, error) {
+	idx, exists := r.Get(field)
+	if !exists {
+		return nil, fmt.Errorf("no index on %s", field)
+	}
+	if b, ok := idx.(*BitmapIndex); ok {
+		return b.Bitmap(value), nil
+	}
+	return bitmap.Of(idx.Lookup(value)...), nil
+}
+
+// InternName returns the copy of name that table already holds for another
+// student with that name, so they all share one string.
+func (r *Registry) InternName(name string, table *models.RecordTable) string {
+	idx, _ := r.Get("name")
+	if names, ok := idx.(interface{ Bucket(any) []int }); ok {
+		return table.Intern(name, names.Bucket(name))
+	}
+	return name
+}
+
+// Definitions lists the indexes ordered by field.
+func (r *Registry) Definitions() []Definition {
+	if r == nil {
+		return nil
+	}
+	defs := make([]Definition, 0, len(r.indexes))
+	for field, idx := range r.indexes {
+		defs = append(defs, Definition{Field: field, Kind: idx.Kind(), Builtin: isBuiltin(field, idx)})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Field < defs[j].Field })
+	return defs
+}
+
+// Add indexes a new or changed student in every index.
+func (r *Registry) Add(student models.Student) {
+	if r == nil {
+		return
+	}
+	for field, idx := range r.indexes {
+		idx.Add(Fields[field].Value(student), student.Id)
+	}
+}
+
+// Remove takes the student out of every index, student must hold the values
+// it was added with.
+func (r *Registry) Remove(student models.Student) {
+	if r == nil {
+		return
+	}
+	for field, idx := range r.indexes {
+		idx.Remove(Fields[field].Value(student), student.Id)
+	}
+}
+
+// Clear empties every index and keeps the definitions.
+func (r *Registry) Clear() {
+	if r == nil {
+		return
+	}
+	for _, idx := range r.indexes {
+		idx.Clear()
+	}
+}
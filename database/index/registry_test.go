@@ -0,0 +1,136 @@
+package index
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kgugunava/database/models"
+)
+
+func TestIndexKinds(t *testing.T) {
+	for _, kind := range Kinds {
+		t.Run(string(kind), func(t *testing.T) {
+			idx, err := New(kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, gpa := range []float64{3.5, 4.0, 3.5, 2.0, 4.0} {
+				idx.Add(gpa, id)
+			}
+			idx.Remove(3.5, 0)
+			idx.Remove(2.0, 3)
+
+			if ids := idx.Lookup(3.5); !slices.Equal(ids, []int{2}) {
+				t.Errorf("Lookup(3.5) = %v", ids)
+			}
+			if ids := idx.Lookup(2.0); len(ids) != 0 {
+				t.Errorf("Lookup(2.0) = %v after removing its only id", ids)
+			}
+			if idx.Values() != 2 {
+				t.Errorf("Values() = %d, want 2", idx.Values())
+			}
+
+			ordered, ok := idx.(RangeIndex)
+			if ok != (kind == Ordered) {
+				t.Fatalf("%s index is a RangeIndex: %t", kind, ok)
+			}
+			if ok {
+				if ids := ordered.Range(3.0, 4.0); !slices.Equal(ids, []int{2, 1, 4}) {
+					t.Errorf("Range(3, 4) = %v", ids)
+				}
+			}
+
+			idx.Clear()
+			if idx.Values() != 0 || len(idx.Lookup(4.0)) != 0 {
+				t.Error("Clear left values behind")
+			}
+		})
+	}
+}
+
+func TestRegistryBuiltin(t *testing.T) {
+	r := NewRegistry()
+	r.Add(models.Student{Id: 1, Name: "Anna", Gpa: 4.5, Active: true})
+	r.Add(models.Student{Id: 2, Name: "Anna", Gpa: 3.5})
+
+	if ids := r.Lookup("name", "Anna"); !slices.Equal(ids, []int{1, 2}) {
+		t.Fatalf("name index has %v", ids)
+	}
+	if ids, _ := r.Bitmap("active", true); !slices.Equal(ids.Ids(), []int{1}) {
+		t.Fatalf("active index has %v", ids.Ids())
+	}
+	for _, def := range r.Definitions() {
+		if !def.Builtin {
+			t.Fatalf("%s is not built in", def.Field)
+		}
+	}
+
+	if _, err := r.Drop("gpa"); err == nil {
+		t.Fatal("dropped the built-in gpa index")
+	}
+	if _, err := r.Create("gpa", Bitmap); err == nil {
+		t.Fatal("created a second bitmap index on gpa")
+	}
+
+	// an ordered index replaces the built-in one until it is dropped
+	idx, err := r.Create("gpa", Ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Values() != 0 {
+		t.Fatal("the new index is not empty")
+	}
+	if _, err := r.Create("gpa", Hash); err == nil {
+		t.Fatal("replaced an index that is not built in")
+	}
+	if defs := r.Definitions(); defs[1].Field != "gpa" || defs[1].Builtin || defs[1].Kind != Ordered {
+		t.Fatalf("gpa is listed as %+v", defs[1])
+	}
+
+	builtin, err := r.Drop("gpa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Get("gpa"); builtin == nil || got != builtin || builtin.Kind() != Bitmap {
+		t.Fatalf("dropping the ordered index left %v", got)
+	}
+
+	if _, err := r.Create("version", Ordered); err != nil {
+		t.Fatal(err)
+	}
+	if builtin, err := r.Drop("version"); err != nil || builtin != nil {
+		t.Fatalf("Drop(version) = %v, %v", builtin, err)
+	}
+	if _, exists := r.Get("version"); exists {
+		t.Fatal("the version index is still there")
+	}
+}
+
+func TestCreateRejectsUnsupportedField(t *testing.T) {
+	Fields["tags"] = Field{Value: func(s models.Student) any { return []string{s.Name} }}
+	Fields["age"] = Field{Value: func(s models.Student) any { return time.Duration(s.Id) }}
+	t.Cleanup(func() {
+		delete(Fields, "tags")
+		delete(Fields, "age")
+	})
+
+	for _, test := range []struct {
+		field string
+		kind  Kind
+		ok    bool
+	}{
+		{"tags", Hash, false},
+		{"tags", Bitmap, false},
+		{"age", Ordered, false},
+		{"age", Hash, true},
+		{"version", Ordered, true},
+		{"unknown", Hash, false},
+	} {
+		r := NewRegistry()
+		_, err := r.Create(test.field, test.kind)
+		if (err == nil) != test.ok {
+			t.Errorf("Create(%s, %s) = %v", test.field, test.kind, err)
+		}
+	}
+}
@@ -5,6 +5,8 @@ type Student struct {
 	Name string `json:"name"`
 	Gpa float64 `json:"gpa"`
 	Active bool `json:"active"`
+	Version int `json:"version"`
+	Deleted bool `json:"deleted,omitempty"` // tombstone written on delete
 }
 
 type Record struct {
@@ -13,7 +15,18 @@ type Record struct {
 }
 
 type RecordInfo struct {
-	Name   string
-	Gpa    float64
-	Active bool
-}
\ No newline at end of file
+	Name    string
+	Gpa     float64
+	Active  bool
+	Version int
+}
+// Student rebuilds the indexed part of a student from its RecordInfo.
+func (info RecordInfo) Student(id int) Student {
+	return Student{
+		Id:      id,
+		Name:    info.Name,
+		Gpa:     info.Gpa,
+		Active:  info.Active,
+		Version: info.Version,
+	}
+}
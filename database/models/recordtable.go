@@ -0,0 +1,114 @@
+package models
+
+import "iter"
+
+// RecordTable holds the RecordInfo of every live student as a struct of
+// arrays: a column per field instead of a map entry per student. Rows of
+// deleted students are reused by later ones.
+type RecordTable struct {
+	rows     map[int]int32 // id - row
+	names    []string
+	gpas     []float64
+	versions []uint32
+	active   []uint64 // one bit per row
+	free     []int32
+}
+
+func NewRecordTable(capacity int) *RecordTable {
+	return &RecordTable{
+		rows:     make(map[int]int32, capacity),
+		names:    make([]string, 0, capacity),
+		gpas:     make([]float64, 0, capacity),
+		versions: make([]uint32, 0, capacity),
+		active:   make([]uint64, 0, (capacity+63)/64),
+	}
+}
+
+func (t *RecordTable) Len() int {
+	return len(t.rows)
+}
+
+func (t *RecordTable) Get(id int) (RecordInfo, bool) {
+	row, exists := t.rows[id]
+	if !exists {
+		return RecordInfo{}, false
+	}
+	return t.info(row), true
+}
+
+func (t *RecordTable) info(row int32) RecordInfo {
+	return RecordInfo{
+		Name:    t.names[row],
+		Gpa:     t.gpas[row],
+		Active:  t.active[row/64]&(1<<(row%64)) != 0,
+		Version: int(t.versions[row]),
+	}
+}
+
+// Intern returns the copy of name already stored for the students in ids,
+// the name index bucket of name, so every student with that name shares one
+// string. A new name is returned as is.
+func (t *RecordTable) Intern(name string, ids []int) string {
+	for _, id := range ids {
+		if row, exists := t.rows[id]; exists && t.names[row] == name {
+			return t.names[row]
+		}
+	}
+	return name
+}
+
+// Set adds the student or replaces what is stored for it.
+func (t *RecordTable) Set(id int, info RecordInfo) {
+	row, exists := t.rows[id]
+	if !exists {
+		row = t.newRow()
+		t.rows[id] = row
+	}
+
+	t.names[row] = info.Name
+	t.gpas[row] = info.Gpa
+	t.versions[row] = uint32(info.Version)
+	if info.Active {
+		t.active[row/64] |= 1 << (row % 64)
+	} else {
+		t.active[row/64] &^= 1 << (row % 64)
+	}
+}
+
+func (t *RecordTable) newRow() int32 {
+	if n := len(t.free); n > 0 {
+		row := t.free[n-1]
+		t.free = t.free[:n-1]
+		return row
+	}
+
+	row := int32(len(t.names))
+	t.names = append(t.names, "")
+	t.gpas = append(t.gpas, 0)
+	t.versions = append(t.versions, 0)
+	if int(row)/64 == len(t.active) {
+		t.active = append(t.active, 0)
+	}
+	return row
+}
+
+func (t *RecordTable) Delete(id int) {
+	row, exists := t.rows[id]
+	if !exists {
+		return
+	}
+	delete(t.rows, id)
+	t.names[row] = ""
+	t.free = append(t.free, row)
+}
+
+// All iterates over the students in no particular order.
+func (t *RecordTable) All() iter.Seq2[int, RecordInfo] {
+	return func(yield func(int, RecordInfo) bool) {
+		for id, row := range t.rows {
+			if !yield(id, t.info(row)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+)
+
+func testStudents(n int) []models.Student {
+	students := make([]models.Student, n)
+	for i := range students {
+		students[i] = models.Student{
+			Id:     i*7 - 100,
+			Name:   fmt.Sprintf("Студент %d", i%300),
+			Gpa:    float64(i%401) / 100,
+			Active: i%3 == 0,
+		}
+	}
+	if n > 2 {
+		students[1].Name = ""
+		students[2].Gpa = math.Inf(1)
+	}
+	return students
+}
+
+// write writes students with row groups of groupRows and returns the file.
+func write(t testing.TB, students []models.Student, groupRows int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.groupRows = groupRows
+	for _, student := range students {
+		if err := w.Write(student); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func read(data []byte) ([]models.Student, error) {
+	var students []models.Student
+	err := Read(bytes.NewReader(data), int64(len(data)), func(student models.Student) error {
+		students = append(students, student)
+		return nil
+	})
+	return students, err
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 999, 1000, 2500} {
+		students := testStudents(n)
+		data := write(t, students, 1000)
+
+		meta, err := readFooter(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if groups := len(meta.list(4)); groups != (n+999)/1000 {
+			t.Fatalf("%d students: %d row groups", n, groups)
+		}
+
+		got, err := read(data)
+		if err != nil {
+			t.Fatalf("%d students: %v", n, err)
+		}
+		if !slices.Equal(got, students) {
+			t.Fatalf("%d students: read back %d different ones", n, len(got))
+		}
+	}
+}
+
+func TestReadDamaged(t *testing.T) {
+	data := write(t, testStudents(2500), 1000)
+	for _, damaged := range [][]byte{
+		data[:len(data)-1],
+		data[:len(data)/2],
+		append(slices.Clone(data[:len(data)-8]), 0xff, 0xff, 0xff, 0x7f, 'P', 'A', 'R', '1'),
+	} {
+		if _, err := read(damaged); err == nil {
+			t.Fatalf("a damaged file of %d bytes was read", len(damaged))
+		}
+	}
+}
+
+func TestEncodeUnknownColumn(t *testing.T) {
+	if _, _, _, err := encodeColumn("email", testStudents(3)); err == nil {
+		t.Fatal("encodeColumn encoded an unknown column")
+	}
+}
+
+// The benchmarks write and read 250 000 students:
+//
+//	go test -run '^$' -bench . ./database/parquet
+const benchmarkStudents = 250_000
+
+func BenchmarkWrite(b *testing.B) {
+	students := testStudents(benchmarkStudents)
+	var size int
+	for i := 0; i < b.N; i++ {
+		size = len(write(b, students, RowGroupRows))
+	}
+	b.ReportMetric(float64(size)/1e6, "file-MB")
+}
+
+func BenchmarkRead(b *testing.B) {
+	data := write(b, testStudents(benchmarkStudents), RowGroupRows)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := read(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
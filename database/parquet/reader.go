@@ -0,0 +1,275 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kgugunava/database/models"
+)
+
+// ErrUnsupported is returned for a valid Parquet file that uses what Read
+// does not support, such as dictionary encoding or nullable columns.
+var ErrUnsupported = errors.New("unsupported Parquet file")
+
+// Read calls fn for every student of a Parquet file in the layout Writer
+// writes, in the order they were written. Only one row group is in memory at
+// a time. Files of other writers are read as long as they have the same
+// four required columns, plain-encoded, uncompressed or gzip-compressed.
+// Students have no Version, the file does not keep it.
+func Read(r io.ReaderAt, size int64, fn func(models.Student) error) error {
+	meta, err := readFooter(r, size)
+	if err != nil {
+		return err
+	}
+
+	positions, err := columnPositions(meta.list(2))
+	if err != nil {
+		return err
+	}
+
+	var values columnValues
+	for i, item := range meta.list(4) {
+		group, _ := item.(thriftStruct)
+		rows, _ := group.int(3)
+		chunks := group.list(1)
+		if rows < 0 {
+			return fmt.Errorf("row group %d: bad row count %d", i, rows)
+		}
+
+		values.reset()
+		for col, position := range positions {
+			if position >= len(chunks) {
+				return fmt.Errorf("row group %d: column %s is missing", i, columns[col].name)
+			}
+			chunk, _ := chunks[position].(thriftStruct)
+			err = readChunk(r, size, chunk.strct(3), col, rows, &values)
+			if err != nil {
+				return fmt.Errorf("row group %d, column %s: %w", i, columns[col].name, err)
+			}
+		}
+
+		for row := range int(rows) {
+			err = fn(models.Student{
+				Id:     int(values.ids[row]),
+				Name:   values.names[row],
+				Gpa:    values.gpas[row],
+				Active: values.actives[row],
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readFooter(r io.ReaderAt, size int64) (thriftStruct, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, errors.New("file is too small for Parquet")
+	}
+	head := make([]byte, len(magic))
+	tail := make([]byte, 4+len(magic))
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head, magic) || !bytes.Equal(tail[4:], magic) {
+		return nil, errors.New("not a Parquet file")
+	}
+
+	footerSize := int64(binary.LittleEndian.Uint32(tail))
+	if footerSize > size-int64(len(head)+len(tail)) {
+		return nil, errors.New("bad footer size, the file may be truncated")
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-int64(len(tail))-footerSize); err != nil {
+		return nil, err
+	}
+
+	reader := thriftReader{buf: footer}
+	meta, err := reader.readStruct(0)
+	if err != nil {
+		return nil, fmt.Errorf("error reading footer: %w", err)
+	}
+	return meta, nil
+}
+
+// columnPositions checks the schema and returns where each of columns is
+// among the chunks of a row group.
+func columnPositions(schema []any) ([]int, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("file has no schema")
+	}
+
+	positions := make([]int, len(columns))
+	for col := range positions {
+		positions[col] = -1
+	}
+	for i, item := range schema[1:] {
+		element, _ := item.(thriftStruct)
+		if _, nested := element.int(5); nested {
+			return nil, fmt.Errorf("%w: nested columns", ErrUnsupported)
+		}
+		for col, want := range columns {
+			if string(element.bytes(4)) != want.name {
+				continue
+			}
+			kind, _ := element.int(1)
+			if kind != int64(want.kind) {
+				return nil, fmt.Errorf("%w: column %s has type %d", ErrUnsupported, want.name, kind)
+			}
+			if repetition, _ := element.int(3); repetition != repetitionRequired {
+				return nil, fmt.Errorf("%w: column %s is not required", ErrUnsupported, want.name)
+			}
+			positions[col] = i
+		}
+	}
+
+	for col, position := range positions {
+		if position < 0 {
+			return nil, fmt.Errorf("file has no column %s", columns[col].name)
+		}
+	}
+	return positions, nil
+}
+
+// columnValues are the columns of one row group.
+type columnValues struct {
+	ids     []int64
+	names   []string
+	gpas    []float64
+	actives []bool
+}
+
+func (v *columnValues) reset() {
+	v.ids = v.ids[:0]
+	v.names = v.names[:0]
+	v.gpas = v.gpas[:0]
+	v.actives = v.actives[:0]
+}
+
+func readChunk(r io.ReaderAt, size int64, meta thriftStruct, col int, rows int64, values *columnValues) error {
+	if meta == nil {
+		return errors.New("chunk has no metadata")
+	}
+	if _, ok := meta.int(11); ok {
+		return fmt.Errorf("%w: dictionary pages", ErrUnsupported)
+	}
+	codec, _ := meta.int(4)
+	if codec != codecUncompressed && codec != codecGzip {
+		return fmt.Errorf("%w: compression codec %d", ErrUnsupported, codec)
+	}
+
+	offset, _ := meta.int(9)
+	length, _ := meta.int(7)
+	if offset < 0 || length < 0 || offset+length > size {
+		return errors.New("chunk is outside the file")
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return err
+	}
+
+	reader := thriftReader{buf: data}
+	for read := int64(0); read < rows; {
+		header, err := reader.readStruct(0)
+		if err != nil {
+			return fmt.Errorf("error reading page header: %w", err)
+		}
+		compressedSize, _ := header.int(3)
+		if compressedSize < 0 {
+			return fmt.Errorf("bad page size %d", compressedSize)
+		}
+		page, err := reader.bytes(uint64(compressedSize))
+		if err != nil {
+			return errors.New("page is truncated")
+		}
+		if kind, _ := header.int(1); kind != pageData {
+			return fmt.Errorf("%w: page type %d", ErrUnsupported, kind)
+		}
+
+		dataHeader := header.strct(5)
+		n, _ := dataHeader.int(1)
+		if encoding, _ := dataHeader.int(2); encoding != encodingPlain {
+			return fmt.Errorf("%w: encoding %d", ErrUnsupported, encoding)
+		}
+		if n <= 0 || n > rows-read {
+			return fmt.Errorf("page has %d values", n)
+		}
+
+		if codec == codecGzip {
+			uncompressedSize, _ := header.int(2)
+			page, err = gunzip(page, uncompressedSize)
+			if err != nil {
+				return err
+			}
+		}
+		err = decodePage(col, page, int(n), values)
+		if err != nil {
+			return err
+		}
+		read += n
+	}
+	return nil
+}
+
+func gunzip(data []byte, size int64) ([]byte, error) {
+	zip, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing page: %w", err)
+	}
+	page, err := io.ReadAll(io.LimitReader(zip, size+1))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing page: %w", err)
+	}
+	if int64(len(page)) != size {
+		return nil, errors.New("page has the wrong size")
+	}
+	return page, nil
+}
+
+func decodePage(col int, page []byte, n int, values *columnValues) error {
+	errShort := errors.New("page is shorter than its values")
+
+	switch columns[col].kind {
+	case columnInt64, columnDouble:
+		if len(page) < 8*n {
+			return errShort
+		}
+		for i := range n {
+			bits := binary.LittleEndian.Uint64(page[8*i:])
+			if columns[col].kind == columnInt64 {
+				values.ids = append(values.ids, int64(bits))
+			} else {
+				values.gpas = append(values.gpas, math.Float64frombits(bits))
+			}
+		}
+	case columnByteArray:
+		for range n {
+			if len(page) < 4 {
+				return errShort
+			}
+			length := binary.LittleEndian.Uint32(page)
+			if uint64(length) > uint64(len(page)-4) {
+				return errShort
+			}
+			values.names = append(values.names, string(page[4:4+length]))
+			page = page[4+length:]
+		}
+	case columnBoolean:
+		if len(page) < (n+7)/8 {
+			return errShort
+		}
+		for i := range n {
+			values.actives = append(values.actives, page[i/8]&(1<<(i%8)) != 0)
+		}
+	}
+	return nil
+}
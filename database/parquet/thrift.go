@@ -0,0 +1,304 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Parquet metadata is Thrift structs in the compact protocol. Only the part
+// of the protocol the metadata uses is here.
+const (
+	typeTrue   = 1
+	typeFalse  = 2
+	typeByte   = 3
+	typeI16    = 4
+	typeI32    = 5
+	typeI64    = 6
+	typeDouble = 7
+	typeBinary = 8
+	typeList   = 9
+	typeSet    = 10
+	typeMap    = 11
+	typeStruct = 12
+)
+
+// thriftWriter encodes a struct field by field. Nested structs are begun
+// with beginStruct or beginElement and closed with endStruct.
+type thriftWriter struct {
+	buf   []byte
+	last  int16   // id of the previous field of the current struct
+	stack []int16 // last of the enclosing structs
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (w *thriftWriter) field(id int16, kind byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|kind)
+	} else {
+		w.buf = append(w.buf, kind)
+		w.varint(zigzag(int64(id)))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, typeI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, typeI64)
+	w.varint(zigzag(v))
+}
+
+func (w *thriftWriter) binary(id int16, v []byte) {
+	w.field(id, typeBinary)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.binary(id, []byte(v))
+}
+
+// list starts a list field of n elements, which are written next with the
+// element methods.
+func (w *thriftWriter) list(id int16, kind byte, n int) {
+	w.field(id, typeList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|kind)
+	} else {
+		w.buf = append(w.buf, 0xF0|kind)
+		w.varint(uint64(n))
+	}
+}
+
+func (w *thriftWriter) i32Element(v int32) {
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) stringElement(v string) {
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, typeStruct)
+	w.beginElement()
+}
+
+// beginElement starts a struct that is an element of a list.
+func (w *thriftWriter) beginElement() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf = append(w.buf, 0)
+	if len(w.stack) > 0 {
+		w.last = w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+	}
+}
+
+// thriftStruct is a decoded struct by field id. Values are int64 for every
+// integer type, bool, float64, []byte, []any for lists and sets, and
+// thriftStruct. Maps are skipped, the metadata read here has none.
+type thriftStruct map[int16]any
+
+var errThriftTruncated = errors.New("metadata is truncated")
+
+// maxThriftDepth bounds the nesting of structs and lists, so broken metadata
+// cannot exhaust the stack.
+const maxThriftDepth = 32
+
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, errThriftTruncated
+	}
+	r.pos++
+	return r.buf[r.pos-1], nil
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.buf)-r.pos) {
+		return nil, errThriftTruncated
+	}
+	r.pos += int(n)
+	return r.buf[r.pos-int(n) : r.pos], nil
+}
+
+func (r *thriftReader) readStruct(depth int) (thriftStruct, error) {
+	if depth > maxThriftDepth {
+		return nil, errors.New("metadata is nested too deep")
+	}
+	s := make(thriftStruct)
+	var last int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+
+		kind := b & 0x0F
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		var value any
+		switch kind {
+		case typeTrue:
+			value = true
+		case typeFalse:
+			value = false
+		default:
+			value, err = r.value(kind, depth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if value != nil {
+			s[id] = value
+		}
+	}
+}
+
+func (r *thriftReader) value(kind byte, depth int) (any, error) {
+	switch kind {
+	case typeTrue, typeFalse:
+		// a bool element of a list is a byte of its own
+		b, err := r.byte()
+		return b == typeTrue, err
+	case typeByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case typeI16, typeI32, typeI64:
+		return r.zigzag()
+	case typeDouble:
+		b, err := r.bytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case typeBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		return r.bytes(n)
+	case typeList, typeSet:
+		return r.readList(depth + 1)
+	case typeMap:
+		return nil, r.skipMap(depth + 1)
+	case typeStruct:
+		return r.readStruct(depth + 1)
+	}
+	return nil, fmt.Errorf("unknown metadata type %d", kind)
+}
+
+func (r *thriftReader) readList(depth int) ([]any, error) {
+	if depth > maxThriftDepth {
+		return nil, errors.New("metadata is nested too deep")
+	}
+	b, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	n := uint64(b >> 4)
+	if n == 15 {
+		n, err = r.varint()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// every element takes a byte at least
+	if n > uint64(len(r.buf)-r.pos) {
+		return nil, errThriftTruncated
+	}
+
+	list := make([]any, n)
+	for i := range list {
+		list[i], err = r.value(b&0x0F, depth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (r *thriftReader) skipMap(depth int) error {
+	n, err := r.varint()
+	if err != nil || n == 0 {
+		return err
+	}
+	kinds, err := r.byte()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if _, err := r.value(kinds>>4, depth); err != nil {
+			return err
+		}
+		if _, err := r.value(kinds&0x0F, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s thriftStruct) int(id int16) (int64, bool) {
+	v, ok := s[id].(int64)
+	return v, ok
+}
+
+func (s thriftStruct) bytes(id int16) []byte {
+	v, _ := s[id].([]byte)
+	return v
+}
+
+func (s thriftStruct) list(id int16) []any {
+	v, _ := s[id].([]any)
+	return v
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
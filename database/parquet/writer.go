@@ -0,0 +1,319 @@
+// Package parquet writes students to Apache Parquet files and reads them
+// back, without a dependency on a Parquet library. The file has the columns
+// id (INT64), name (BYTE_ARRAY, UTF-8 string), gpa (DOUBLE) and active
+// (BOOLEAN), none of them nullable, in plain encoding with gzip-compressed
+// pages. pandas, DuckDB and Spark read such files as they are.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/kgugunava/database/models"
+)
+
+var magic = []byte("PAR1")
+
+// CreatedBy is the application the files name as their writer.
+const CreatedBy = "github.com/kgugunava/database"
+
+// A row group is written once it has RowGroupRows students or its names take
+// rowGroupBytes, so the writer keeps at most that much in memory.
+const (
+	RowGroupRows  = 100_000
+	rowGroupBytes = 64 * 1024 * 1024
+)
+
+// Values of the enums of the Parquet metadata.
+const (
+	columnBoolean   = 0
+	columnInt64     = 2
+	columnDouble    = 5
+	columnByteArray = 6
+
+	repetitionRequired = 0
+	convertedUTF8      = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	codecGzip          = 2
+	pageData           = 0
+)
+
+type column struct {
+	name string
+	kind int32
+}
+
+// columns are in the order of the schema and of the chunks of a row group.
+var columns = []column{
+	{"id", columnInt64},
+	{"name", columnByteArray},
+	{"gpa", columnDouble},
+	{"active", columnBoolean},
+}
+
+type chunkMeta struct {
+	offset       int64
+	compressed   int64
+	uncompressed int64
+	min, max     []byte // plain-encoded, no statistics when nil
+}
+
+type rowGroupMeta struct {
+	rows   int64
+	chunks []chunkMeta
+}
+
+// Writer writes students to a Parquet file as they come, a row group at a
+// time. Close must be called to write the footer, without which the file
+// cannot be read.
+type Writer struct {
+	w         io.Writer
+	offset    int64
+	groupRows int // RowGroupRows, fewer in tests
+	rows      []models.Student
+	nameBytes int
+	groups    []rowGroupMeta
+	numRows   int64
+
+	zip    *gzip.Writer
+	zipped bytes.Buffer
+	err    error // the first error writing, every later call returns it
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	pw := &Writer{w: w, groupRows: RowGroupRows}
+	pw.zip = gzip.NewWriter(&pw.zipped)
+	pw.write(magic)
+	if pw.err != nil {
+		return nil, pw.err
+	}
+	return pw, nil
+}
+
+func (pw *Writer) write(data []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+func (pw *Writer) Write(student models.Student) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	pw.rows = append(pw.rows, student)
+	pw.nameBytes += len(student.Name)
+	if len(pw.rows) >= pw.groupRows || pw.nameBytes >= rowGroupBytes {
+		pw.flushRowGroup()
+	}
+	return pw.err
+}
+
+// Close writes the last row group and the footer. It does not close the
+// underlying writer.
+func (pw *Writer) Close() error {
+	if len(pw.rows) > 0 {
+		pw.flushRowGroup()
+	}
+	footer := pw.footer()
+	pw.write(footer)
+	pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	pw.write(magic)
+	return pw.err
+}
+
+func (pw *Writer) flushRowGroup() {
+	group := rowGroupMeta{rows: int64(len(pw.rows))}
+	for _, col := range columns {
+		data, min, max, err := encodeColumn(col.name, pw.rows)
+		if err != nil {
+			pw.err = err
+			return
+		}
+		if len(data) > math.MaxInt32 {
+			pw.err = errors.New("column is too large for a page")
+			return
+		}
+
+		pw.zipped.Reset()
+		pw.zip.Reset(&pw.zipped)
+		pw.zip.Write(data)
+		err = pw.zip.Close()
+		if err != nil {
+			pw.err = err
+			return
+		}
+
+		var header thriftWriter
+		header.i32(1, pageData)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(pw.zipped.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(pw.rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := chunkMeta{
+			offset:       pw.offset,
+			compressed:   int64(len(header.buf) + pw.zipped.Len()),
+			uncompressed: int64(len(header.buf) + len(data)),
+			min:          min,
+			max:          max,
+		}
+		pw.write(header.buf)
+		pw.write(pw.zipped.Bytes())
+		group.chunks = append(group.chunks, chunk)
+	}
+
+	pw.groups = append(pw.groups, group)
+	pw.numRows += group.rows
+	pw.rows = pw.rows[:0]
+	pw.nameBytes = 0
+}
+
+// encodeColumn encodes a column of students in plain encoding and returns
+// its smallest and largest values, nil for a gpa column with a NaN in it,
+// as NaN has no place in the order.
+func encodeColumn(name string, students []models.Student) (data, min, max []byte, err error) {
+	switch name {
+	case "id":
+		lo, hi := int64(students[0].Id), int64(students[0].Id)
+		for _, student := range students {
+			data = binary.LittleEndian.AppendUint64(data, uint64(student.Id))
+			lo, hi = minMax(lo, hi, int64(student.Id))
+		}
+		return data, binary.LittleEndian.AppendUint64(nil, uint64(lo)), binary.LittleEndian.AppendUint64(nil, uint64(hi)), nil
+
+	case "name":
+		lo, hi := students[0].Name, students[0].Name
+		for _, student := range students {
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(student.Name)))
+			data = append(data, student.Name...)
+			lo, hi = minMax(lo, hi, student.Name)
+		}
+		return data, []byte(lo), []byte(hi), nil
+
+	case "gpa":
+		lo, hi := students[0].Gpa, students[0].Gpa
+		nan := false
+		for _, student := range students {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(student.Gpa))
+			lo, hi = minMax(lo, hi, student.Gpa)
+			nan = nan || math.IsNaN(student.Gpa)
+		}
+		if nan {
+			return data, nil, nil, nil
+		}
+		return data, binary.LittleEndian.AppendUint64(nil, math.Float64bits(lo)), binary.LittleEndian.AppendUint64(nil, math.Float64bits(hi)), nil
+
+	case "active":
+		data = make([]byte, (len(students)+7)/8)
+		var lo, hi byte = 1, 0
+		for i, student := range students {
+			if student.Active {
+				data[i/8] |= 1 << (i % 8)
+				hi = 1
+			} else {
+				lo = 0
+			}
+		}
+		return data, []byte{lo}, []byte{hi}, nil
+	}
+	return nil, nil, nil, fmt.Errorf("unknown column %s", name)
+}
+
+func minMax[T int64 | float64 | string](lo, hi, v T) (T, T) {
+	return min(lo, v), max(hi, v)
+}
+
+func (pw *Writer) footer() []byte {
+	var t thriftWriter
+	t.i32(1, 1)
+
+	t.list(2, typeStruct, 1+len(columns))
+	t.beginElement()
+	t.string(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.endStruct()
+	for _, col := range columns {
+		t.beginElement()
+		t.i32(1, col.kind)
+		t.i32(3, repetitionRequired)
+		t.string(4, col.name)
+		if col.kind == columnByteArray {
+			t.i32(6, convertedUTF8)
+			t.beginStruct(10) // LogicalType
+			t.beginStruct(1)  // STRING
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, pw.numRows)
+
+	t.list(4, typeStruct, len(pw.groups))
+	for _, group := range pw.groups {
+		var compressed, uncompressed int64
+		t.beginElement()
+		t.list(1, typeStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			compressed += chunk.compressed
+			uncompressed += chunk.uncompressed
+
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3) // ColumnMetaData
+			t.i32(1, columns[i].kind)
+			t.list(2, typeI32, 1)
+			t.i32Element(encodingPlain)
+			t.list(3, typeBinary, 1)
+			t.stringElement(columns[i].name)
+			t.i32(4, codecGzip)
+			t.i64(5, group.rows)
+			t.i64(6, chunk.uncompressed)
+			t.i64(7, chunk.compressed)
+			t.i64(9, chunk.offset)
+			if chunk.min != nil {
+				t.beginStruct(12) // Statistics
+				t.i64(3, 0)       // null_count
+				t.binary(5, chunk.max)
+				t.binary(6, chunk.min)
+				t.endStruct()
+			}
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, uncompressed)
+		t.i64(3, group.rows)
+		t.i64(5, group.chunks[0].offset)
+		t.i64(6, compressed)
+		t.endStruct()
+	}
+
+	t.string(6, CreatedBy)
+
+	// min and max of the statistics follow the order of the column types
+	t.list(7, typeStruct, len(columns))
+	for range columns {
+		t.beginElement()
+		t.beginStruct(1) // TypeDefinedOrder
+		t.endStruct()
+		t.endStruct()
+	}
+
+	t.endStruct()
+	return t.buf
+}
@@ -0,0 +1,126 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/models"
+)
+
+var (
+	ErrDuplicateId   = errors.New("record with this id already exists")
+	ErrIndexesBehind = errors.New("records were stored but the indexes were not updated")
+)
+
+// RowError is a row of a bulk load that was not added.
+type RowError struct {
+	Row int // position in the loaded list, from 0
+	Id  int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d (id %d): %v", e.Row, e.Id, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// BulkResult tells which rows of a bulk load were added and which failed.
+type BulkResult struct {
+	Added  int
+	Failed []RowError
+}
+
+// AddNewRecordsFromList is the bulk load path. Unlike calling AddNewRecord for
+// every record it checks all ids before writing anything, writes the records
+// through one storage batch and only then updates the indexes, in one pass.
+// Rows that cannot be added are reported in the result and do not stop the
+// others; the returned error means the batch itself failed and nothing was
+// added.
+//
+// The one exception is a failure of a file-backed idIndex after the batch was
+// committed. The records are stored then, but only the first result.Added of
+// them are in the indexes, and the error wraps ErrIndexesBehind: the indexes
+// have to be rebuilt from storage, e.g. with Db.LoadIndex, before the next
+// write.
+func (r *Recorder) AddNewRecordsFromList(records []models.Record, idIndex *btree.Tree, recordInfo *models.RecordTable) (*BulkResult, error) {
+	if r.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	valid, failed := checkNewIds(records, recordInfo)
+	result := &BulkResult{Failed: failed}
+	fail := func(row, id int, err error) {
+		result.Failed = append(result.Failed, RowError{Row: row, Id: id, Err: err})
+	}
+
+	batch, err := r.Storage.Batch()
+	if err != nil {
+		return nil, fmt.Errorf("error starting bulk write: %w", err)
+	}
+
+	students := make([]models.Student, 0, len(valid))
+	offsets := make([]int64, 0, len(valid))
+	for _, row := range valid {
+		student := records[row].Student
+		if student.Version == 0 {
+			student.Version = 1
+		}
+
+		offset, err := batch.Append(student)
+		if err != nil {
+			fail(row, student.Id, err)
+			continue
+		}
+		students = append(students, student)
+		offsets = append(offsets, offset)
+	}
+
+	slices.SortFunc(result.Failed, func(a, b RowError) int { return a.Row - b.Row })
+
+	err = batch.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error writing records: %w", err)
+	}
+
+	for i, student := range students {
+		err := idIndex.Put(student.Id, offsets[i])
+		if err != nil {
+			return result, fmt.Errorf("%w: error updating id index: %w", ErrIndexesBehind, err)
+		}
+		r.indexNewStudent(student, recordInfo)
+		result.Added++
+	}
+
+	return result, nil
+}
+
+// checkNewIds returns the rows whose ids are free and the rows whose ids
+// already exist or repeat an earlier row.
+func checkNewIds(records []models.Record, recordInfo *models.RecordTable) ([]int, []RowError) {
+	var failed []RowError
+
+	// recordInfo holds exactly the live ids and is cheaper to ask than idIndex
+	seen := make(map[int]bool, len(records))
+	valid := make([]int, 0, len(records))
+	for row, record := range records {
+		id := record.Student.Id
+		if _, exists := recordInfo.Get(id); exists {
+			failed = append(failed, RowError{Row: row, Id: id, Err: ErrDuplicateId})
+			continue
+		}
+		if seen[id] {
+			err := fmt.Errorf("%w earlier in the list", ErrDuplicateId)
+			failed = append(failed, RowError{Row: row, Id: id, Err: err})
+			continue
+		}
+		seen[id] = true
+		valid = append(valid, row)
+	}
+
+	return valid, failed
+}
@@ -0,0 +1,96 @@
+package recorder
+
+import (
+	"container/list"
+
+	"github.com/kgugunava/database/models"
+)
+
+// DefaultCacheSize is how many decoded students the Recorder keeps.
+const DefaultCacheSize = 10000
+
+// StudentCache keeps recently read students by id with LRU eviction, so hot
+// records are not read and decoded again. Edits and deletes remove the
+// student, the next read gets the stored version.
+type StudentCache struct {
+	capacity int
+	entries  map[int]*list.Element
+	lru      *list.List // front is the most recently used student
+
+	Hits   int
+	Misses int
+}
+
+func NewStudentCache(capacity int) *StudentCache {
+	return &StudentCache{
+		capacity: max(capacity, 1),
+		entries:  make(map[int]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *StudentCache) Get(id int) (models.Student, bool) {
+	if c == nil {
+		return models.Student{}, false
+	}
+	elem, ok := c.entries[id]
+	if !ok {
+		c.Misses++
+		return models.Student{}, false
+	}
+	c.Hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(models.Student), true
+}
+
+func (c *StudentCache) Put(student models.Student) {
+	if c == nil {
+		return
+	}
+	if elem, ok := c.entries[student.Id]; ok {
+		elem.Value = student
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	if c.lru.Len() >= c.capacity {
+		oldest := c.lru.Back()
+		delete(c.entries, oldest.Value.(models.Student).Id)
+		c.lru.Remove(oldest)
+	}
+	c.entries[student.Id] = c.lru.PushFront(student)
+}
+
+func (c *StudentCache) Remove(id int) {
+	if c == nil {
+		return
+	}
+	if elem, ok := c.entries[id]; ok {
+		delete(c.entries, id)
+		c.lru.Remove(elem)
+	}
+}
+
+// Clear drops every student, the counters are kept.
+func (c *StudentCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.entries = make(map[int]*list.Element)
+	c.lru.Init()
+}
+
+func (c *StudentCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	return c.lru.Len()
+}
+
+// HitRate is the share of reads served from the cache, 0 before any read.
+func (c *StudentCache) HitRate() float64 {
+	if c == nil || c.Hits+c.Misses == 0 {
+		return 0
+	}
+	return float64(c.Hits) / float64(c.Hits+c.Misses)
+}
@@ -0,0 +1,279 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/scanner"
+)
+
+// CSVQuoting is how fields of a CSV file are quoted.
+type CSVQuoting string
+
+const (
+	// QuoteMinimal quotes a field only when it has to, as RFC 4180 does.
+	QuoteMinimal CSVQuoting = "minimal"
+	// QuoteAll quotes every field when writing, reading is as for QuoteMinimal.
+	QuoteAll CSVQuoting = "all"
+	// QuoteNone takes quotes as ordinary characters, fields cannot contain the
+	// delimiter or line breaks then.
+	QuoteNone CSVQuoting = "none"
+)
+
+var CSVQuotings = []CSVQuoting{QuoteMinimal, QuoteAll, QuoteNone}
+
+// CSVEncoding is the character encoding of a CSV file.
+type CSVEncoding string
+
+const (
+	// EncodingAuto reads UTF-8, with or without a BOM, and falls back to
+	// Windows-1251 when the file is not valid UTF-8. Files are written as UTF-8.
+	EncodingAuto        CSVEncoding = "auto"
+	EncodingUTF8        CSVEncoding = "utf-8"
+	EncodingUTF8BOM     CSVEncoding = "utf-8-bom" // what Excel expects to open UTF-8 files
+	EncodingWindows1251 CSVEncoding = "windows-1251"
+)
+
+var CSVEncodings = []CSVEncoding{EncodingAuto, EncodingUTF8, EncodingUTF8BOM, EncodingWindows1251}
+
+// csvDelimiters are the delimiters tried when a dialect does not set one.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CSVDialect describes how a CSV file is written. The zero value reads any
+// of the usual dialects and writes RFC 4180 files in UTF-8.
+type CSVDialect struct {
+	Delimiter rune // found from the first line when 0, ',' when writing
+	Quoting   CSVQuoting
+	Encoding  CSVEncoding
+	// DecimalComma writes "4,5" instead of "4.5", as spreadsheets with a
+	// Russian locale do. Both are always read.
+	DecimalComma bool
+}
+
+// decodeCSV turns the contents of a CSV file into UTF-8 text without a BOM.
+func decodeCSV(data []byte, encoding CSVEncoding) (string, error) {
+	switch encoding {
+	case "", EncodingAuto:
+		if bytes.HasPrefix(data, utf8BOM) || utf8.Valid(data) {
+			return string(bytes.TrimPrefix(data, utf8BOM)), nil
+		}
+		fallthrough
+	case EncodingWindows1251:
+		text, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("error decoding windows-1251: %w", err)
+		}
+		return string(text), nil
+	case EncodingUTF8, EncodingUTF8BOM:
+		data = bytes.TrimPrefix(data, utf8BOM)
+		if !utf8.Valid(data) {
+			return "", errors.New("file is not valid UTF-8")
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("unknown encoding %q", encoding)
+}
+
+// detectDelimiter picks the usual delimiter found most often in the first line.
+func detectDelimiter(text string) rune {
+	first, _, _ := strings.Cut(text, "\n")
+	best, count := csvDelimiters[0], 0
+	for _, delimiter := range csvDelimiters {
+		if n := strings.Count(first, string(delimiter)); n > count {
+			best, count = delimiter, n
+		}
+	}
+	return best
+}
+
+// parseCSV splits decoded text into rows. Rows may have any number of fields,
+// missing ones are empty cells.
+func parseCSV(text string, dialect CSVDialect) ([][]string, error) {
+	delimiter := dialect.Delimiter
+	if delimiter == 0 {
+		delimiter = detectDelimiter(text)
+	}
+
+	if dialect.Quoting == QuoteNone {
+		var rows [][]string
+		// the text is in memory already, so lines of any length are read
+		lines := scanner.NewRecordReader(strings.NewReader(text), 0)
+		lines.MaxRecordSize = 0
+		for {
+			line, _, err := lines.Next()
+			if err == io.EOF {
+				return rows, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error parsing CSV: %w", err)
+			}
+			rows = append(rows, strings.Split(string(line), string(delimiter)))
+		}
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CSV: %w", err)
+	}
+	return rows, nil
+}
+
+// readCSV reads a whole CSV file as rows of text.
+func readCSV(csvPath string, dialect CSVDialect) ([][]string, error) {
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening CSV file: %w", err)
+	}
+	text, err := decodeCSV(data, dialect.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return parseCSV(text, dialect)
+}
+
+// CSVHeader returns the first row of a CSV file, from which the user picks
+// the columns to import.
+func CSVHeader(csvPath string, dialect CSVDialect) ([]string, error) {
+	rows, err := readCSV(csvPath, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return rows[0], nil
+}
+
+// ImportFromCSV adds the students from a CSV file like ImportFromXLSX does
+// from a sheet: the same header mapping, validation, conflict policy and
+// report. Row numbers in the report count CSV records, which differ from line
+// numbers only when a quoted field has line breaks.
+func (r *Recorder) ImportFromCSV(csvPath string, dialect CSVDialect, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) (*ImportReport, error) {
+	if r.ReadOnly && !options.DryRun {
+		return nil, ErrReadOnly
+	}
+
+	rows, err := readCSV(csvPath, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV file is empty")
+	}
+
+	report, err := r.importRows(rows, options, idIndex, recordInfo)
+	if err != nil {
+		return report, fmt.Errorf("error adding records from CSV: %w", err)
+	}
+	return report, nil
+}
+
+// CSVWriter writes rows of text in a dialect.
+type CSVWriter struct {
+	dialect CSVDialect
+	w       *bufio.Writer
+	csv     *csv.Writer // for QuoteMinimal, the other quotings are written directly
+	line    strings.Builder
+}
+
+func NewCSVWriter(w io.Writer, dialect CSVDialect) (*CSVWriter, error) {
+	if dialect.Delimiter == 0 {
+		dialect.Delimiter = ','
+	}
+	if dialect.Delimiter == '"' || dialect.Delimiter == '\r' || dialect.Delimiter == '\n' {
+		return nil, fmt.Errorf("invalid delimiter %q", dialect.Delimiter)
+	}
+
+	cw := &CSVWriter{dialect: dialect, w: bufio.NewWriter(w)}
+	if dialect.Quoting == "" || dialect.Quoting == QuoteMinimal {
+		cw.csv = csv.NewWriter(&cw.line)
+		cw.csv.Comma = dialect.Delimiter
+		cw.csv.UseCRLF = true
+	}
+	if dialect.Encoding == EncodingUTF8BOM {
+		_, err := cw.w.Write(utf8BOM)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cw, nil
+}
+
+// Write writes one row. A row that cannot be written in the dialect, such as
+// a name with characters Windows-1251 does not have, returns an error and
+// leaves the file as it was.
+func (cw *CSVWriter) Write(fields []string) error {
+	cw.line.Reset()
+	delimiter := string(cw.dialect.Delimiter)
+
+	switch {
+	case cw.csv != nil:
+		cw.csv.Write(fields)
+		cw.csv.Flush()
+		if err := cw.csv.Error(); err != nil {
+			return err
+		}
+	case cw.dialect.Quoting == QuoteAll:
+		for i, field := range fields {
+			if i > 0 {
+				cw.line.WriteString(delimiter)
+			}
+			cw.line.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+		}
+		cw.line.WriteString("\r\n")
+	case cw.dialect.Quoting == QuoteNone:
+		for i, field := range fields {
+			if strings.Contains(field, delimiter) || strings.ContainsAny(field, "\r\n") {
+				return fmt.Errorf("field %q needs quoting", field)
+			}
+			if i > 0 {
+				cw.line.WriteString(delimiter)
+			}
+			cw.line.WriteString(field)
+		}
+		cw.line.WriteString("\r\n")
+	default:
+		return fmt.Errorf("unknown quoting %q", cw.dialect.Quoting)
+	}
+
+	line := cw.line.String()
+	if cw.dialect.Encoding == EncodingWindows1251 {
+		encoded, err := charmap.Windows1251.NewEncoder().String(line)
+		if err != nil {
+			return fmt.Errorf("row cannot be written in windows-1251: %w", err)
+		}
+		line = encoded
+	}
+	_, err := cw.w.WriteString(line)
+	return err
+}
+
+func (cw *CSVWriter) Flush() error {
+	return cw.w.Flush()
+}
+
+// StudentFields formats a student as the fields of ImportFields.
+func (d CSVDialect) StudentFields(student models.Student) []string {
+	gpa := strconv.FormatFloat(student.Gpa, 'f', -1, 64)
+	if d.DecimalComma {
+		gpa = strings.Replace(gpa, ".", ",", 1)
+	}
+	return []string{strconv.Itoa(student.Id), student.Name, gpa, strconv.FormatBool(student.Active)}
+}
@@ -0,0 +1,192 @@
+package recorder
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	cp1251, err := charmap.Windows1251.NewEncoder().String("id;имя\n1;Ёлкина Юлия\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		data     string
+		encoding CSVEncoding
+		text     string
+		err      bool
+	}{
+		{name: "utf-8", data: "id,name\n1,Анна\n", text: "id,name\n1,Анна\n"},
+		{name: "bom", data: "\xef\xbb\xbfid,name\n", text: "id,name\n"},
+		{name: "bom named", data: "\xef\xbb\xbfid,name\n", encoding: EncodingUTF8BOM, text: "id,name\n"},
+		{name: "windows-1251 found", data: cp1251, text: "id;имя\n1;Ёлкина Юлия\n"},
+		{name: "windows-1251 named", data: cp1251, encoding: EncodingWindows1251, text: "id;имя\n1;Ёлкина Юлия\n"},
+		{name: "not utf-8", data: cp1251, encoding: EncodingUTF8, err: true},
+		{name: "unknown encoding", data: "id\n", encoding: "koi8-r", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, err := decodeCSV([]byte(test.data), test.encoding)
+			if test.err != (err != nil) || text != test.text {
+				t.Fatalf("decodeCSV = %q, %v, want %q", text, err, test.text)
+			}
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		text      string
+		delimiter rune
+	}{
+		{text: "id,name,gpa,active\n1,a,2,3", delimiter: ','},
+		{text: "id;name;gpa;active\n1;4,5;2;3", delimiter: ';'},
+		{text: "id\tname\tgpa\n", delimiter: '\t'},
+		{text: "id|name|gpa", delimiter: '|'},
+		{text: "id;name,with,commas;gpa;active", delimiter: ';'},
+		{text: "id\n1,2,3,4", delimiter: ','},
+	}
+
+	for _, test := range tests {
+		if delimiter := detectDelimiter(test.text); delimiter != test.delimiter {
+			t.Errorf("detectDelimiter(%q) = %q, want %q", test.text, delimiter, test.delimiter)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	longName := strings.Repeat("я", 1<<20)
+	tests := []struct {
+		name    string
+		text    string
+		dialect CSVDialect
+		rows    [][]string
+	}{
+		{
+			name: "quoted delimiter, quote and line break",
+			text: "id;name\r\n1;\"Петрова; \"\"Аня\"\"\r\nАнна\"\r\n2;short\r\n",
+			rows: [][]string{{"id", "name"}, {"1", "Петрова; \"Аня\"\nАнна"}, {"2", "short"}},
+		},
+		{
+			name:    "all quoted",
+			text:    "\"id\",\"name\"\n\"1\",\"Anna\"\n",
+			dialect: CSVDialect{Quoting: QuoteAll},
+			rows:    [][]string{{"id", "name"}, {"1", "Anna"}},
+		},
+		{
+			name:    "quotes are text without quoting",
+			text:    "id|name\r\n1|Anna \"Ann\" O'Neil\r\n\r\n2|x|extra\n",
+			dialect: CSVDialect{Quoting: QuoteNone},
+			rows:    [][]string{{"id", "name"}, {"1", "Anna \"Ann\" O'Neil"}, {""}, {"2", "x", "extra"}},
+		},
+		{
+			name:    "lines of any length without quoting",
+			text:    "id,name\n1," + longName,
+			dialect: CSVDialect{Quoting: QuoteNone},
+			rows:    [][]string{{"id", "name"}, {"1", longName}},
+		},
+		{
+			name:    "delimiter given",
+			text:    "id,name;gpa\n1,Anna;4",
+			dialect: CSVDialect{Delimiter: ';'},
+			rows:    [][]string{{"id,name", "gpa"}, {"1,Anna", "4"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := parseCSV(test.text, test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(rows, test.rows, slices.Equal) {
+				t.Fatalf("parseCSV = %q, want %q", rows, test.rows)
+			}
+		})
+	}
+
+	if _, err := parseCSV("id,name\n1,\"unterminated\n", CSVDialect{}); err == nil {
+		t.Fatal("parseCSV accepted an unterminated quote")
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	row := []string{"1", `Анна "Аня", Петрова`, "4,5"}
+	tests := []struct {
+		name    string
+		dialect CSVDialect
+		out     string
+		err     bool
+	}{
+		{
+			name: "minimal",
+			out:  "1,\"Анна \"\"Аня\"\", Петрова\",\"4,5\"\r\n",
+		},
+		{
+			name:    "all",
+			dialect: CSVDialect{Delimiter: ';', Quoting: QuoteAll},
+			out:     "\"1\";\"Анна \"\"Аня\"\", Петрова\";\"4,5\"\r\n",
+		},
+		{
+			name:    "none",
+			dialect: CSVDialect{Delimiter: '\t', Quoting: QuoteNone},
+			out:     "1\tАнна \"Аня\", Петрова\t4,5\r\n",
+		},
+		{
+			name:    "none with the delimiter in a field",
+			dialect: CSVDialect{Quoting: QuoteNone},
+			err:     true,
+		},
+		{
+			name:    "bom",
+			dialect: CSVDialect{Delimiter: ';', Encoding: EncodingUTF8BOM},
+			out:     "\xef\xbb\xbf1;\"Анна \"\"Аня\"\", Петрова\";4,5\r\n",
+		},
+		{
+			name:    "invalid delimiter",
+			dialect: CSVDialect{Delimiter: '"'},
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w, err := NewCSVWriter(&out, test.dialect)
+			if err == nil {
+				err = w.Write(row)
+			}
+			if err == nil {
+				err = w.Flush()
+			}
+			if test.err != (err != nil) || out.String() != test.out {
+				t.Fatalf("wrote %q, %v, want %q", out.String(), err, test.out)
+			}
+		})
+	}
+
+	// a row with a letter windows-1251 lacks is left out and the writer goes on
+	var out bytes.Buffer
+	w, err := NewCSVWriter(&out, CSVDialect{Encoding: EncodingWindows1251})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]string{"1", "Zoë 😀"}); err == nil {
+		t.Fatal("a name without windows-1251 letters was written")
+	}
+	if err := w.Write([]string{"2", "Юлия"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2,\xde\xeb\xe8\xff\r\n"; out.String() != want {
+		t.Fatalf("wrote %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,507 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/models"
+)
+
+// ImportFields are the student fields an imported table can have, in the
+// order columns are taken when the table has no header.
+var ImportFields = []string{"id", "name", "gpa", "active"}
+
+// headerAliases are the header texts recognised for a field when the caller
+// does not map it, compared case-insensitively.
+var headerAliases = map[string][]string{
+	"id":     {"id", "ид", "№", "номер"},
+	"name":   {"name", "имя", "фио", "student", "студент"},
+	"gpa":    {"gpa", "средний балл", "балл", "score"},
+	"active": {"active", "активен", "активный", "status", "статус"},
+}
+
+// ConflictPolicy says what an import does with a row whose id exists already.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing student and reports the row as a conflict.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing student with the row.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictUpsert takes the non-empty fields of the row and keeps the rest.
+	ConflictUpsert ConflictPolicy = "upsert"
+	// ConflictFail adds nothing if any row conflicts.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ConflictPolicies lists the policies in the order they are offered to the user.
+var ConflictPolicies = []ConflictPolicy{ConflictSkip, ConflictOverwrite, ConflictUpsert, ConflictFail}
+
+// ErrImportConflict is returned by an import under ConflictFail that found
+// conflicts, nothing is added then.
+var ErrImportConflict = errors.New("import has conflicting ids")
+
+// ErrNoName is why a row that would add a student without a name is skipped,
+// which happens to upserts that bring only some fields.
+var ErrNoName = errors.New("no name for a new student")
+
+// ImportOptions describe the layout of an imported table.
+type ImportOptions struct {
+	Sheet string // XLSX sheet or table of an SQL dump, the first one when empty
+	// Columns maps fields of ImportFields to header texts of the first row,
+	// fields left out are found by their usual header names.
+	Columns map[string]string
+	// NoHeader means the first row is already data and the columns go in the
+	// order of ImportFields.
+	NoHeader bool
+	// DryRun only reports what the import would do, see ApplyImport; it works on
+	// a read-only database too.
+	DryRun bool
+	// OnConflict is ConflictSkip when empty.
+	OnConflict ConflictPolicy
+}
+
+// ImportRow is a row of an imported table turned into a student.
+type ImportRow struct {
+	Row     int // row number in the table from 1, the header included
+	Student models.Student
+	// Missing are the fields the row has no value for, which ConflictUpsert
+	// keeps from the existing student.
+	Missing []string
+}
+
+// ImportReport tells what an import did or, before it is applied, would do.
+// Row numbers in it are those of the table.
+type ImportReport struct {
+	Applied   bool
+	Policy    ConflictPolicy
+	Rows      int         // data rows read, blank ones not counted
+	Insert    []ImportRow // rows that are added, or would be
+	Update    []ImportRow // rows that overwrite or merge into an existing student
+	Skipped   []RowError  // rows that could not be read, with the reason
+	Conflicts []RowError  // rows whose id exists already or repeats an earlier row
+
+	planned []ImportRow // the rows that could be read, ApplyImport plans them again
+}
+
+// ImportCounts are the number of rows of an import by outcome.
+type ImportCounts struct {
+	Inserted    int
+	Overwritten int
+	Merged      int
+	Skipped     int
+	Conflicts   int
+}
+
+func (rep *ImportReport) Counts() ImportCounts {
+	counts := ImportCounts{
+		Inserted:  len(rep.Insert),
+		Skipped:   len(rep.Skipped),
+		Conflicts: len(rep.Conflicts),
+	}
+	if rep.Policy == ConflictUpsert {
+		counts.Merged = len(rep.Update)
+	} else {
+		counts.Overwritten = len(rep.Update)
+	}
+	return counts
+}
+
+func (rep *ImportReport) Summary() string {
+	counts := rep.Counts()
+	outcomes := []string{fmt.Sprintf("%d to insert", counts.Inserted)}
+	switch rep.Policy {
+	case ConflictOverwrite:
+		outcomes = append(outcomes, fmt.Sprintf("%d to overwrite", counts.Overwritten))
+	case ConflictUpsert:
+		outcomes = append(outcomes, fmt.Sprintf("%d to merge", counts.Merged))
+	}
+	summary := strings.Join(outcomes, ", ")
+	if rep.Applied {
+		summary = strings.NewReplacer("to insert", "inserted", "to overwrite", "overwritten", "to merge", "merged").Replace(summary)
+	}
+	return fmt.Sprintf("%d rows read: %s, %d skipped, %d conflicts", rep.Rows, summary, counts.Skipped, counts.Conflicts)
+}
+
+// buildImportReport parses the data rows of a table and checks their ids
+// without changing the database. first is the table row number of rows[0].
+func buildImportReport(rows [][]string, first int, cols columnMap, policy ConflictPolicy, recordInfo *models.RecordTable) *ImportReport {
+	report := newImportReport(policy)
+
+	var parsed []ImportRow
+	for i, row := range rows {
+		if blankRow(row) {
+			continue
+		}
+		report.Rows++
+
+		student, missing, err := parseRow(row, cols)
+		if err != nil {
+			report.Skipped = append(report.Skipped, RowError{Row: first + i, Id: student.Id, Err: err})
+			continue
+		}
+		parsed = append(parsed, ImportRow{Row: first + i, Student: student, Missing: missing})
+	}
+
+	report.plan(parsed, recordInfo)
+	return report
+}
+
+func newImportReport(policy ConflictPolicy) *ImportReport {
+	if policy == "" {
+		policy = ConflictSkip
+	}
+	return &ImportReport{Policy: policy}
+}
+
+// plan sorts rows into Insert, Update and Conflicts by the ids in the database
+// now and the policy of the report, replacing what an earlier plan found. An
+// id repeated in the import is always a conflict, as it is not clear which of
+// the rows is meant.
+func (rep *ImportReport) plan(rows []ImportRow, recordInfo *models.RecordTable) {
+	rep.planned = rows
+	rep.Insert, rep.Update, rep.Conflicts = nil, nil, nil
+	rep.Skipped = slices.DeleteFunc(rep.Skipped, func(rowErr RowError) bool {
+		return errors.Is(rowErr.Err, ErrNoName)
+	})
+
+	// recordInfo holds exactly the live ids and is cheaper to ask than idIndex
+	seen := make(map[int]bool, len(rows))
+	for _, row := range rows {
+		id := row.Student.Id
+		if seen[id] {
+			err := fmt.Errorf("%w earlier in the list", ErrDuplicateId)
+			rep.Conflicts = append(rep.Conflicts, RowError{Row: row.Row, Id: id, Err: err})
+			continue
+		}
+		seen[id] = true
+
+		if _, exists := recordInfo.Get(id); !exists {
+			if slices.Contains(row.Missing, "name") {
+				rep.Skipped = append(rep.Skipped, RowError{Row: row.Row, Id: id, Err: ErrNoName})
+				continue
+			}
+			rep.Insert = append(rep.Insert, row)
+			continue
+		}
+		switch rep.Policy {
+		case ConflictOverwrite, ConflictUpsert:
+			rep.Update = append(rep.Update, row)
+		default:
+			rep.Conflicts = append(rep.Conflicts, RowError{Row: row.Row, Id: id, Err: ErrDuplicateId})
+		}
+	}
+
+	slices.SortFunc(rep.Skipped, func(a, b RowError) int { return a.Row - b.Row })
+	slices.SortFunc(rep.Conflicts, func(a, b RowError) int { return a.Row - b.Row })
+}
+
+// merged is the student a row of the report turns existing into.
+func (rep *ImportReport) merged(row ImportRow, existing models.Student) models.Student {
+	student := row.Student
+	if rep.Policy != ConflictUpsert {
+		return student
+	}
+	for _, field := range row.Missing {
+		switch field {
+		case "name":
+			student.Name = existing.Name
+		case "gpa":
+			student.Gpa = existing.Gpa
+		case "active":
+			student.Active = existing.Active
+		}
+	}
+	return student
+}
+
+// importRows imports the rows of a table, the first of which may be a
+// header, as options say.
+func (r *Recorder) importRows(rows [][]string, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) (*ImportReport, error) {
+	cols, hasHeader, err := mapColumns(rows[0], options)
+	if err != nil {
+		return nil, err
+	}
+	first := 1
+	if hasHeader {
+		rows = rows[1:]
+		first = 2
+	}
+
+	report := buildImportReport(rows, first, cols, options.OnConflict, recordInfo)
+	return report, r.finishImport(report, options, idIndex, recordInfo)
+}
+
+// finishImport applies a report unless options ask for a dry run.
+func (r *Recorder) finishImport(report *ImportReport, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+	if options.DryRun {
+		return nil
+	}
+	return r.ApplyImport(report, idIndex, recordInfo)
+}
+
+func importRecords(rows []ImportRow) []models.Record {
+	records := make([]models.Record, len(rows))
+	for i, row := range rows {
+		records[i] = models.Record{Id: row.Student.Id, Student: row.Student}
+	}
+	return records
+}
+
+// ApplyImport adds and updates the rows a report plans to, so what the user
+// reviewed after a dry run is exactly what gets imported. The rows are
+// planned again first, as the database may have changed since the dry run:
+// a row whose id was taken meanwhile becomes an update or a conflict by the
+// policy, a conflict whose id was freed is inserted, and upserts merge into
+// the student as it is now. Under ConflictFail
+// any conflict returns ErrImportConflict before anything is written.
+func (r *Recorder) ApplyImport(report *ImportReport, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+	if report.Applied {
+		return errors.New("import was already applied")
+	}
+	if r.ReadOnly {
+		return ErrReadOnly
+	}
+
+	report.plan(report.planned, recordInfo)
+
+	if report.Policy == ConflictFail && len(report.Conflicts) > 0 {
+		return fmt.Errorf("%w: %d rows, the first at row %d", ErrImportConflict, len(report.Conflicts), report.Conflicts[0].Row)
+	}
+
+	result, err := r.AddNewRecordsFromList(importRecords(report.Insert), idIndex, recordInfo)
+	if err != nil {
+		return err
+	}
+
+	failed := make(map[int]bool, len(result.Failed))
+	for _, rowErr := range result.Failed {
+		failed[rowErr.Row] = true
+		rowErr.Row = report.Insert[rowErr.Row].Row
+		if errors.Is(rowErr.Err, ErrDuplicateId) {
+			report.Conflicts = append(report.Conflicts, rowErr)
+		} else {
+			report.Skipped = append(report.Skipped, rowErr)
+		}
+	}
+	inserted := report.Insert[:0]
+	for i, row := range report.Insert {
+		if !failed[i] {
+			inserted = append(inserted, row)
+		}
+	}
+	report.Insert = inserted
+
+	updated := report.Update[:0]
+	for _, row := range report.Update {
+		info, _ := recordInfo.Get(row.Student.Id)
+		student := report.merged(row, info.Student(row.Student.Id))
+		record := models.Record{Id: student.Id, Student: student}
+
+		err := r.EditRecord(record, info.Version, idIndex, recordInfo)
+		if err != nil {
+			report.Skipped = append(report.Skipped, RowError{Row: row.Row, Id: row.Student.Id, Err: err})
+			continue
+		}
+		updated = append(updated, row)
+	}
+	report.Update = updated
+
+	slices.SortFunc(report.Skipped, func(a, b RowError) int { return a.Row - b.Row })
+	slices.SortFunc(report.Conflicts, func(a, b RowError) int { return a.Row - b.Row })
+	report.Applied = true
+
+	return nil
+}
+
+// columnMap is the position of every field in a row, -1 when the table has
+// no column for it.
+type columnMap map[string]int
+
+func positionalColumns() columnMap {
+	cols := make(columnMap, len(ImportFields))
+	for i, field := range ImportFields {
+		cols[field] = i
+	}
+	return cols
+}
+
+func normalizeHeader(text string) string {
+	return strings.ToLower(strings.TrimSpace(text))
+}
+
+// mapColumns finds the columns of the fields in the first row of a table and
+// tells whether that row is a header. Columns the caller maps are taken
+// first, the usual header names only find columns left over. A first row
+// without any known header is taken as a header only if its id cell is not a
+// number, so tables without a header do not lose their first student.
+func mapColumns(first []string, options ImportOptions) (columnMap, bool, error) {
+	if options.NoHeader {
+		return positionalColumns(), false, nil
+	}
+
+	positions := make(map[string]int, len(first))
+	for i, text := range first {
+		if _, exists := positions[normalizeHeader(text)]; !exists {
+			positions[normalizeHeader(text)] = i
+		}
+	}
+
+	cols := make(columnMap, len(ImportFields))
+	taken := make(map[int]bool, len(ImportFields))
+	matched := false
+	for _, field := range ImportFields {
+		cols[field] = -1
+		if header, ok := options.Columns[field]; ok {
+			pos, exists := positions[normalizeHeader(header)]
+			if !exists {
+				return nil, false, fmt.Errorf("column %q for %s not found in the header", header, field)
+			}
+			cols[field] = pos
+			taken[pos] = true
+			matched = true
+		}
+	}
+	for _, field := range ImportFields {
+		if _, ok := options.Columns[field]; ok {
+			continue
+		}
+		for _, alias := range headerAliases[field] {
+			if pos, exists := positions[alias]; exists && !taken[pos] {
+				cols[field] = pos
+				taken[pos] = true
+				matched = true
+				break
+			}
+		}
+	}
+
+	if !matched {
+		cols = positionalColumns()
+		_, err := parseLocaleInt(cell(first, cols["id"]))
+		return cols, err != nil, nil
+	}
+
+	required := []string{"id", "name"}
+	if options.OnConflict == ConflictUpsert {
+		// upserts may only bring some fields, such as new grades by id
+		required = required[:1]
+	}
+	for _, field := range required {
+		if cols[field] < 0 {
+			return nil, false, fmt.Errorf("no %s column in the header", field)
+		}
+	}
+	return cols, true, nil
+}
+
+// cell returns the text of a cell as written. The number and bool parsers
+// ignore the spaces around it, a name keeps them, so that it survives a
+// round trip through an export.
+func cell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+// blankRow reports rows without any text, which tables often end with.
+func blankRow(row []string) bool {
+	for _, text := range row {
+		if strings.TrimSpace(text) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRow turns a table row into a student and lists the fields with empty
+// cells. Missing gpa and active cells are zero values, id is required.
+func parseRow(row []string, cols columnMap) (models.Student, []string, error) {
+	var student models.Student
+	var missing []string
+
+	id, err := parseLocaleInt(cell(row, cols["id"]))
+	if err != nil {
+		return student, nil, fmt.Errorf("invalid id %q", cell(row, cols["id"]))
+	}
+	student.Id = id
+
+	student.Name = cell(row, cols["name"])
+	if strings.TrimSpace(student.Name) == "" {
+		student.Name = ""
+		missing = append(missing, "name")
+	}
+
+	if text := cell(row, cols["gpa"]); strings.TrimSpace(text) != "" {
+		student.Gpa, err = parseLocaleFloat(text)
+		if err != nil {
+			return student, nil, fmt.Errorf("invalid gpa %q", text)
+		}
+	} else {
+		missing = append(missing, "gpa")
+	}
+	if text := cell(row, cols["active"]); strings.TrimSpace(text) != "" {
+		student.Active, err = parseLocaleBool(text)
+		if err != nil {
+			return student, nil, fmt.Errorf("invalid active value %q", text)
+		}
+	} else {
+		missing = append(missing, "active")
+	}
+
+	return student, missing, nil
+}
+
+// stripDigitGroups removes the spaces spreadsheets put between groups of
+// digits, including the non-breaking ones.
+func stripDigitGroups(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(text))
+}
+
+func parseLocaleInt(text string) (int, error) {
+	return strconv.Atoi(stripDigitGroups(text))
+}
+
+// parseLocaleFloat accepts both "4.5" and "4,5". When a number has both
+// separators the last one is the decimal point and the other groups digits.
+func parseLocaleFloat(text string) (float64, error) {
+	text = stripDigitGroups(text)
+
+	comma, dot := strings.LastIndex(text, ","), strings.LastIndex(text, ".")
+	switch {
+	case comma >= 0 && dot >= 0 && comma > dot:
+		text = strings.ReplaceAll(text, ".", "")
+		text = strings.Replace(text, ",", ".", 1)
+	case comma >= 0 && dot >= 0:
+		text = strings.ReplaceAll(text, ",", "")
+	case comma >= 0:
+		text = strings.Replace(text, ",", ".", 1)
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+var boolWords = map[string]bool{
+	"true": true, "1": true, "yes": true, "y": true, "t": true, "+": true,
+	"да": true, "д": true, "истина": true,
+	"false": false, "0": false, "no": false, "n": false, "f": false, "-": false,
+	"нет": false, "н": false, "ложь": false,
+}
+
+func parseLocaleBool(text string) (bool, error) {
+	value, ok := boolWords[strings.ToLower(strings.TrimSpace(text))]
+	if !ok {
+		return false, fmt.Errorf("not a boolean: %q", text)
+	}
+	return value, nil
+}
@@ -0,0 +1,193 @@
+package recorder
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseRowKeepsName(t *testing.T) {
+	student, missing, err := parseRow([]string{" 1 204 ", "  Anna  Maria ", " 3,5 ", " да "}, positionalColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.Id != 1204 || student.Gpa != 3.5 || !student.Active || len(missing) != 0 {
+		t.Fatalf("parseRow = %+v, missing %v", student, missing)
+	}
+	if student.Name != "  Anna  Maria " {
+		t.Fatalf("name %q was changed", student.Name)
+	}
+}
+
+func TestParseRowBlankCells(t *testing.T) {
+	student, missing, err := parseRow([]string{"7", "   ", " ", "\t"}, positionalColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.Name != "" || !slices.Equal(missing, []string{"name", "gpa", "active"}) {
+		t.Fatalf("parseRow = %+v, missing %v", student, missing)
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   []string
+		options ImportOptions
+		cols    columnMap
+		header  bool
+		err     bool
+	}{
+		{
+			name:   "english header",
+			first:  []string{"id", "name", "gpa", "active"},
+			cols:   columnMap{"id": 0, "name": 1, "gpa": 2, "active": 3},
+			header: true,
+		},
+		{
+			name:   "russian aliases in another case",
+			first:  []string{"№", " ФИО ", "Средний балл", "Статус"},
+			cols:   columnMap{"id": 0, "name": 1, "gpa": 2, "active": 3},
+			header: true,
+		},
+		{
+			name:   "reordered and extra columns",
+			first:  []string{"group", "Active", "Name", "email", "ID", "GPA"},
+			cols:   columnMap{"id": 4, "name": 2, "gpa": 5, "active": 1},
+			header: true,
+		},
+		{
+			name:   "missing optional columns",
+			first:  []string{"name", "id"},
+			cols:   columnMap{"id": 1, "name": 0, "gpa": -1, "active": -1},
+			header: true,
+		},
+		{
+			name:    "explicit mapping wins over aliases",
+			first:   []string{"id", "name", "Login", "Full name", "grade"},
+			options: ImportOptions{Columns: map[string]string{"name": "full name", "gpa": "Grade"}},
+			cols:    columnMap{"id": 0, "name": 3, "gpa": 4, "active": -1},
+			header:  true,
+		},
+		{
+			name:    "mapped column takes an alias column away",
+			first:   []string{"student", "id", "name"},
+			options: ImportOptions{Columns: map[string]string{"id": "name"}},
+			cols:    columnMap{"id": 2, "name": 0, "gpa": -1, "active": -1},
+			header:  true,
+		},
+		{
+			name:    "mapped column not in the header",
+			first:   []string{"id", "name"},
+			options: ImportOptions{Columns: map[string]string{"gpa": "score"}},
+			err:     true,
+		},
+		{
+			name:  "no name column",
+			first: []string{"id", "gpa"},
+			err:   true,
+		},
+		{
+			name:    "upsert needs only the id",
+			first:   []string{"id", "gpa"},
+			options: ImportOptions{OnConflict: ConflictUpsert},
+			cols:    columnMap{"id": 0, "name": -1, "gpa": 1, "active": -1},
+			header:  true,
+		},
+		{
+			name:  "first row is a student",
+			first: []string{"12", "Anna", "4,5", "да"},
+			cols:  positionalColumns(),
+		},
+		{
+			name:   "unknown header",
+			first:  []string{"a", "b", "c", "d"},
+			cols:   positionalColumns(),
+			header: true,
+		},
+		{
+			name:    "no header option",
+			first:   []string{"id", "name", "gpa", "active"},
+			options: ImportOptions{NoHeader: true},
+			cols:    positionalColumns(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cols, header, err := mapColumns(test.first, test.options)
+			if test.err {
+				if err == nil {
+					t.Fatalf("mapColumns = %v, want an error", cols)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(cols, test.cols) || header != test.header {
+				t.Fatalf("mapColumns = %v, header %v, want %v, header %v", cols, header, test.cols, test.header)
+			}
+		})
+	}
+}
+
+func TestParseLocaleFloat(t *testing.T) {
+	tests := []struct {
+		text  string
+		value float64
+		err   bool
+	}{
+		{text: "4.5", value: 4.5},
+		{text: "4,5", value: 4.5},
+		{text: " 3,75 ", value: 3.75},
+		{text: "5", value: 5},
+		{text: "1 234,5", value: 1234.5},
+		{text: "1\u00a0234,5", value: 1234.5},
+		{text: "1.234,5", value: 1234.5},
+		{text: "1,234.5", value: 1234.5},
+		{text: "-0,5", value: -0.5},
+		{text: "", err: true},
+		{text: "4,5,6", err: true},
+		{text: "abc", err: true},
+	}
+
+	for _, test := range tests {
+		value, err := parseLocaleFloat(test.text)
+		if test.err != (err != nil) || !test.err && value != test.value {
+			t.Errorf("parseLocaleFloat(%q) = %v, %v", test.text, value, err)
+		}
+	}
+}
+
+func TestParseLocaleBool(t *testing.T) {
+	tests := []struct {
+		text  string
+		value bool
+		err   bool
+	}{
+		{text: "true", value: true},
+		{text: "TRUE", value: true},
+		{text: "1", value: true},
+		{text: "yes", value: true},
+		{text: "да", value: true},
+		{text: " Да ", value: true},
+		{text: "истина", value: true},
+		{text: "false", value: false},
+		{text: "0", value: false},
+		{text: "no", value: false},
+		{text: "нет", value: false},
+		{text: "НЕТ", value: false},
+		{text: "ложь", value: false},
+		{text: "", err: true},
+		{text: "maybe", err: true},
+		{text: "2", err: true},
+	}
+
+	for _, test := range tests {
+		value, err := parseLocaleBool(test.text)
+		if test.err != (err != nil) || value != test.value {
+			t.Errorf("parseLocaleBool(%q) = %v, %v", test.text, value, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/scanner"
+)
+
+// jsonStudent is an imported JSON element. Fields are pointers to tell a
+// missing or null field, which ConflictUpsert keeps, from a zero value.
+type jsonStudent struct {
+	Id     *int     `json:"id"`
+	Name   *string  `json:"name"`
+	Gpa    *float64 `json:"gpa"`
+	Active *bool    `json:"active"`
+}
+
+// parseJSONStudent turns a JSON object into a student and lists the fields it
+// has no value for. Other keys, such as version in exported files, are
+// ignored.
+func parseJSONStudent(data []byte) (models.Student, []string, error) {
+	var student models.Student
+	var element jsonStudent
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return student, nil, errors.New("not a JSON object")
+	}
+	err := json.Unmarshal(data, &element)
+	if err != nil {
+		return student, nil, err
+	}
+
+	if element.Id == nil {
+		return student, nil, errors.New("no id")
+	}
+	student.Id = *element.Id
+
+	var missing []string
+	if element.Name != nil {
+		student.Name = *element.Name
+	} else {
+		missing = append(missing, "name")
+	}
+	if element.Gpa != nil {
+		student.Gpa = *element.Gpa
+	} else {
+		missing = append(missing, "gpa")
+	}
+	if element.Active != nil {
+		student.Active = *element.Active
+	} else {
+		missing = append(missing, "active")
+	}
+	return student, missing, nil
+}
+
+// ImportFromJSON adds the students from a JSON array or NDJSON read from r,
+// which may be a file or stdin, with the same report and conflict policy as
+// the table imports; options.Columns, NoHeader and Sheet do not apply. Rows of
+// the report are elements of the array or lines of NDJSON. An element that is
+// not a student is skipped with the reason. An array that is not valid JSON
+// cannot be read past the error: the element with it is skipped with the byte
+// it is at, and the elements before it are imported.
+func (r *Recorder) ImportFromJSON(reader io.Reader, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) (*ImportReport, error) {
+	if r.ReadOnly && !options.DryRun {
+		return nil, ErrReadOnly
+	}
+
+	report := newImportReport(options.OnConflict)
+	var parsed []ImportRow
+	err := r.Scanner.ReadJSON(reader, func(element scanner.JSONElement) error {
+		report.Rows++
+
+		err := element.Err
+		var student models.Student
+		var missing []string
+		if err == nil {
+			student, missing, err = parseJSONStudent(element.Data)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, RowError{Row: element.Index, Id: student.Id, Err: err})
+			return nil
+		}
+		parsed = append(parsed, ImportRow{Row: element.Index, Student: student, Missing: missing})
+		return nil
+	})
+	var broken *scanner.ElementError
+	if errors.As(err, &broken) {
+		report.Rows++
+		report.Skipped = append(report.Skipped, RowError{Row: broken.Index, Err: broken.Err})
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading JSON: %w", err)
+	}
+
+	report.plan(parsed, recordInfo)
+
+	err = r.finishImport(report, options, idIndex, recordInfo)
+	if err != nil {
+		return report, fmt.Errorf("error adding records from JSON: %w", err)
+	}
+	return report, nil
+}
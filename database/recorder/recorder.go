@@ -1,26 +1,47 @@
 package recorder
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
-	"bufio"
-	"strconv"
 
 	"github.com/xuri/excelize/v2"
 
+	"github.com/kgugunava/database/bitmap"
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/index"
 	"github.com/kgugunava/database/models"
 	"github.com/kgugunava/database/scanner"
+	"github.com/kgugunava/database/storage"
 )
 
 type Recorder struct {
 	Scanner *scanner.Scanner
+	Storage storage.Storage
+	ReadOnly bool
+	Indexes *index.Registry // runtime indexes, kept up to date on every change
+	Cache *StudentCache
 }
 
+var ErrReadOnly = errors.New("database is opened in read-only mode")
+
 func NewRecorder(scanner *scanner.Scanner) *Recorder {
-	return &Recorder{Scanner: scanner}
+	return &Recorder{
+		Scanner: scanner,
+		Indexes: index.NewRegistry(),
+		Cache:   NewStudentCache(DefaultCacheSize),
+	}
+}
+
+// ConflictError is returned by EditRecord when the student was changed by
+// someone else after the caller read it.
+type ConflictError struct {
+	Id              int
+	ExpectedVersion int
+	Current         models.Student
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("record with ID %d was modified: expected version %d, current version %d", e.Id, e.ExpectedVersion, e.Current.Version)
 }
 
 func (r *Recorder) MakeNewRecord(data models.Student) models.Record {
@@ -38,436 +59,416 @@ func (r *Recorder) MakeRecordsFromList(data []models.Student) []models.Record {
 	return records
 }
 
-func (r *Recorder) AddNewRecord(record models.Record, dbFilePath string, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
-	if _, exists := idIndex[record.Student.Id]; exists {
-		err := errors.New("record with this id already exists")
-		log.Fatal("Record with this id already exists: ", err)
-		return err
+func (r *Recorder) AddNewRecord(record models.Record, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+	if r.ReadOnly {
+		return ErrReadOnly
 	}
 
-	file, err := os.OpenFile(dbFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	_, exists, err := idIndex.Get(record.Student.Id)
 	if err != nil {
-		log.Fatal("Error while openning file for add new record: ", err)
 		return err
 	}
-	defer file.Close()
-
-	data, err := json.Marshal(record.Student)
-	if err != nil {
-		log.Fatal("Error while marshalling json new record: ", err)
-		return err
+	if exists {
+		return fmt.Errorf("%w: %d", ErrDuplicateId, record.Student.Id)
 	}
 
-	_, err = file.Write(append(data, '\n'))
-	if err != nil {
-		log.Fatal("Error while writing new record to file: ", err)
-		return err
+	if record.Student.Version == 0 {
+		record.Student.Version = 1
 	}
 
-	fileInfo, err := file.Stat()
+	offset, err := r.Storage.Append(record.Student)
 	if err != nil {
-		log.Fatal("Error while getting file info: ", err)
-		return err
-	}
-
-	offset := fileInfo.Size() - int64(len(data)) - 1
-	idIndex[record.Student.Id] = offset
-	if nameIndex[record.Student.Name] == nil {
-		nameIndex[record.Student.Name] = make(map[int]bool)
-	}
-	nameIndex[record.Student.Name][record.Student.Id] = true
-
-	if gpaIndex[record.Student.Gpa] == nil {
-		gpaIndex[record.Student.Gpa] = make(map[int]bool)
+		return fmt.Errorf("error writing new record: %w", err)
 	}
-	gpaIndex[record.Student.Gpa][record.Student.Id] = true
 
-	if activeIndex[record.Student.Active] == nil {
-		activeIndex[record.Student.Active] = make(map[int]bool)
-	}
-	activeIndex[record.Student.Active][record.Student.Id] = true
-
-	recordInfo[record.Student.Id] = models.RecordInfo{
-		Name:   record.Student.Name,
-		Gpa:    record.Student.Gpa,
-		Active: record.Student.Active,
+	err = idIndex.Put(record.Student.Id, offset)
+	if err != nil {
+		return fmt.Errorf("error updating id index: %w", err)
 	}
+	r.indexNewStudent(record.Student, recordInfo)
 
 	return nil
 }
 
-func (r *Recorder) AddNewRecordsFromList(records []models.Record, dbFilePath string, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) {
-	for _, record := range records {
-		r.AddNewRecord(record, dbFilePath, idIndex, nameIndex, gpaIndex, activeIndex, recordInfo)
-	}
+// indexNewStudent adds a student that is not indexed yet to every index but
+// the id index.
+func (r *Recorder) indexNewStudent(student models.Student, recordInfo *models.RecordTable) {
+	student.Name = r.Indexes.InternName(student.Name, recordInfo)
+
+	recordInfo.Set(student.Id, models.RecordInfo{
+		Name:    student.Name,
+		Gpa:     student.Gpa,
+		Active:  student.Active,
+		Version: student.Version,
+	})
+	r.Indexes.Add(student)
 }
 
-func (r *Recorder) DeleteRecordById(id int, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
-	info, exists := recordInfo[id]
+func (r *Recorder) DeleteRecordById(id int, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+	if r.ReadOnly {
+		return ErrReadOnly
+	}
+
+	info, exists := recordInfo.Get(id)
 	if !exists {
 		return fmt.Errorf("record with ID %d does not exist", id)
 	}
 
-	delete(idIndex, id)
-
-	delete(nameIndex[info.Name], id)
-	if len(nameIndex[info.Name]) == 0 {
-		delete(nameIndex, info.Name)
+	offset, _, err := idIndex.Get(id)
+	if err != nil {
+		return err
 	}
 
-	delete(gpaIndex[info.Gpa], id)
-	if len(gpaIndex[info.Gpa]) == 0 {
-		delete(gpaIndex, info.Gpa)
+	err = r.Storage.Delete(offset, models.Student{Id: id, Version: info.Version})
+	if err != nil {
+		return fmt.Errorf("error deleting record with ID %d: %w", id, err)
 	}
 
-	delete(activeIndex[info.Active], id)
-	if len(activeIndex[info.Active]) == 0 {
-		delete(activeIndex, info.Active)
+	_, err = idIndex.Delete(id)
+	if err != nil {
+		return fmt.Errorf("error updating id index: %w", err)
 	}
 
-	delete(recordInfo, id)
+	recordInfo.Delete(id)
+	r.Indexes.Remove(info.Student(id))
+	r.Cache.Remove(id)
 
 	return nil
 }
 
-func (r *Recorder) DeleteRecordByName(name string, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
-	ids, exists := nameIndex[name]
-	if !exists {
-		return fmt.Errorf("no records found with name: %s", name)
+func (r *Recorder) DeleteRecordByName(name string, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+	if r.ReadOnly {
+		return ErrReadOnly
 	}
 
-	for id := range ids {
-		delete(idIndex, id)
-
-		info := recordInfo[id]
-		delete(gpaIndex[info.Gpa], id)
-		if len(gpaIndex[info.Gpa]) == 0 {
-			delete(gpaIndex, info.Gpa)
-		}
+	ids := r.Indexes.Lookup("name", name)
+	if len(ids) == 0 {
+		return fmt.Errorf("no records found with name: %s", name)
+	}
 
-		delete(activeIndex[info.Active], id)
-		if len(activeIndex[info.Active]) == 0 {
-			delete(activeIndex, info.Active)
+	for _, id := range ids {
+		err := r.DeleteRecordById(id, idIndex, recordInfo)
+		if err != nil {
+			return err
 		}
-
-		delete(recordInfo, id)
 	}
 
-	delete(nameIndex, name)
-
 	return nil
 }
 
-func (r *Recorder) DeleteRecordByGpa(gpa float64, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
-    ids, exists := gpaIndex[gpa]
-    if !exists {
-        return fmt.Errorf("no records found with GPA: %f", gpa)
+func (r *Recorder) DeleteRecordByGpa(gpa float64, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+    if r.ReadOnly {
+        return ErrReadOnly
     }
 
-    for id := range ids {
-        delete(idIndex, id)
-
-        info := recordInfo[id]
-        delete(nameIndex[info.Name], id)
-        if len(nameIndex[info.Name]) == 0 {
-            delete(nameIndex, info.Name)
-        }
+    ids := r.Indexes.Lookup("gpa", gpa)
+    if len(ids) == 0 {
+        return fmt.Errorf("no records found with GPA: %f", gpa)
+    }
 
-        delete(activeIndex[info.Active], id)
-        if len(activeIndex[info.Active]) == 0 {
-            delete(activeIndex, info.Active)
+    for _, id := range ids {
+        err := r.DeleteRecordById(id, idIndex, recordInfo)
+        if err != nil {
+            return err
         }
-
-        delete(recordInfo, id)
     }
 
-    delete(gpaIndex, gpa)
-
     return nil
 }
 
 
-func (r *Recorder) DeleteRecordByActive(active bool, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
-    ids, exists := activeIndex[active]
-    if !exists {
-        return fmt.Errorf("no records found with active: %t", active)
+func (r *Recorder) DeleteRecordByActive(active bool, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+    if r.ReadOnly {
+        return ErrReadOnly
     }
 
-    for id := range ids {
-        delete(idIndex, id)
-
-        info := recordInfo[id]
-        delete(nameIndex[info.Name], id)
-        if len(nameIndex[info.Name]) == 0 {
-            delete(nameIndex, info.Name)
-        }
+    ids := r.Indexes.Lookup("active", active)
+    if len(ids) == 0 {
+        return fmt.Errorf("no records found with active: %t", active)
+    }
 
-        delete(gpaIndex[info.Gpa], id)
-        if len(gpaIndex[info.Gpa]) == 0 {
-            delete(gpaIndex, info.Gpa)
+    for _, id := range ids {
+        err := r.DeleteRecordById(id, idIndex, recordInfo)
+        if err != nil {
+            return err
         }
-
-        delete(recordInfo, id)
     }
 
-    delete(activeIndex, active)
-
     return nil
 }
 
-func (r *Recorder) EditRecord(newRecord models.Record, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
+// EditRecord applies newRecord only if the stored version of the student still
+// equals expectedVersion. The new state is stored with the next version
+// number, otherwise a *ConflictError with the current state is returned.
+func (r *Recorder) EditRecord(newRecord models.Record, expectedVersion int, idIndex *btree.Tree, recordInfo *models.RecordTable) error {
+    if r.ReadOnly {
+        return ErrReadOnly
+    }
+
     id := newRecord.Student.Id
 
-    oldInfo, exists := recordInfo[id]
+    oldInfo, exists := recordInfo.Get(id)
     if !exists {
         return fmt.Errorf("record with ID %d does not exist", id)
     }
 
-    delete(nameIndex[oldInfo.Name], id)
-    if len(nameIndex[oldInfo.Name]) == 0 {
-        delete(nameIndex, oldInfo.Name)
-    }
-
-    delete(gpaIndex[oldInfo.Gpa], id)
-    if len(gpaIndex[oldInfo.Gpa]) == 0 {
-        delete(gpaIndex, oldInfo.Gpa)
-    }
-
-    delete(activeIndex[oldInfo.Active], id)
-    if len(activeIndex[oldInfo.Active]) == 0 {
-        delete(activeIndex, oldInfo.Active)
+    if oldInfo.Version != expectedVersion {
+        current, err := r.FindById(id, idIndex)
+        if err != nil {
+            return err
+        }
+        return &ConflictError{Id: id, ExpectedVersion: expectedVersion, Current: *current}
     }
 
-    newInfo := models.RecordInfo{
-        Name:   newRecord.Student.Name,
-        Gpa:    newRecord.Student.Gpa,
-        Active: newRecord.Student.Active,
-    }
+    newRecord.Student.Version = expectedVersion + 1
 
-    if nameIndex[newInfo.Name] == nil {
-        nameIndex[newInfo.Name] = make(map[int]bool)
+    oldOffset, _, err := idIndex.Get(id)
+    if err != nil {
+        return err
     }
-    nameIndex[newInfo.Name][id] = true
 
-    if gpaIndex[newInfo.Gpa] == nil {
-        gpaIndex[newInfo.Gpa] = make(map[int]bool)
+    offset, err := r.Storage.Update(oldOffset, newRecord.Student)
+    if err != nil {
+        return err
     }
-    gpaIndex[newInfo.Gpa][id] = true
-
-    if activeIndex[newInfo.Active] == nil {
-        activeIndex[newInfo.Active] = make(map[int]bool)
+    err = idIndex.Put(id, offset)
+    if err != nil {
+        return fmt.Errorf("error updating id index: %w", err)
     }
-    activeIndex[newInfo.Active][id] = true
 
-    recordInfo[id] = newInfo
+    r.Indexes.Remove(oldInfo.Student(id))
+    r.indexNewStudent(newRecord.Student, recordInfo)
+    r.Cache.Remove(id)
 
     return nil
 }
 
-func (r *Recorder) FindById(id int, idIndex map[int]int64, dbFilePath string) (*models.Student, error) {
-    offset, exists := idIndex[id]
+func (r *Recorder) FindById(id int, idIndex *btree.Tree) (*models.Student, error) {
+    student, exists, err := r.readStudent(id, idIndex)
+    if err != nil {
+        return nil, err
+    }
     if !exists {
         return nil, fmt.Errorf("record with ID %d not found", id)
     }
 
-    file, err := os.Open(dbFilePath)
+    return &student, nil
+}
+
+// FindByIdRange returns the students with from <= id <= to ordered by id.
+func (r *Recorder) FindByIdRange(from, to int, idIndex *btree.Tree) ([]models.Student, error) {
+    var ids []int
+    var offsets []int64
+    err := idIndex.Range(from, to, func(id int, offset int64) bool {
+        ids = append(ids, id)
+        offsets = append(offsets, offset)
+        return true
+    })
     if err != nil {
         return nil, err
     }
-    defer file.Close()
+    if len(offsets) == 0 {
+        return nil, fmt.Errorf("no records found with ID from %d to %d", from, to)
+    }
 
-    _, err = file.Seek(offset, 0)
-    if err != nil {
-        return nil, err
+    results := make([]models.Student, 0, len(offsets))
+    for i, offset := range offsets {
+        student, err := r.cachedStudentAt(ids[i], offset)
+        if err != nil {
+            return nil, err
+        }
+        results = append(results, student)
+    }
+
+    return results, nil
+}
+
+// readStudent returns the student from the cache or reads it from the
+// storage through the id index.
+func (r *Recorder) readStudent(id int, idIndex *btree.Tree) (models.Student, bool, error) {
+    if student, ok := r.Cache.Get(id); ok {
+        return student, true, nil
     }
 
-    scanner := bufio.NewScanner(file)
-    scanner.Scan()
-    line := scanner.Text()
+    offset, exists, err := idIndex.Get(id)
+    if err != nil || !exists {
+        return models.Student{}, false, err
+    }
 
-    var student models.Student
-    err = json.Unmarshal([]byte(line), &student)
+    student, err := r.readStudentAt(offset)
     if err != nil {
-        return nil, err
+        return student, false, err
     }
+    r.Cache.Put(student)
 
-    return &student, nil
+    return student, true, nil
 }
 
-func (r *Recorder) FindByName(name string, nameIndex map[string]map[int]bool, idIndex map[int]int64, dbFilePath string) ([]models.Student, error) {
-    ids, exists := nameIndex[name]
-    if !exists {
-        return nil, fmt.Errorf("no records found with name: %s", name)
+// cachedStudentAt is readStudent for a caller that already has the offset.
+func (r *Recorder) cachedStudentAt(id int, offset int64) (models.Student, error) {
+    if student, ok := r.Cache.Get(id); ok {
+        return student, nil
     }
 
-    var results []models.Student
-    file, err := os.Open(dbFilePath)
+    student, err := r.readStudentAt(offset)
     if err != nil {
-        return nil, err
+        return student, err
     }
-    defer file.Close()
+    r.Cache.Put(student)
 
-    for id := range ids {
-        offset, exists := idIndex[id]
-        if !exists {
-            continue
-        }
-
-        _, err = file.Seek(offset, 0)
-        if err != nil {
-            continue
-        }
+    return student, nil
+}
 
-        scanner := bufio.NewScanner(file)
-        scanner.Scan()
-        line := scanner.Text()
+// readStudentAt reads the record stored at offset.
+func (r *Recorder) readStudentAt(offset int64) (models.Student, error) {
+    student, err := r.Storage.ReadAt(offset)
+    if err != nil {
+        return student, fmt.Errorf("error reading record at offset %d: %w", offset, err)
+    }
 
-        var student models.Student
-        err := json.Unmarshal([]byte(line), &student)
-        if err != nil {
-            continue
-        }
+    return student, nil
+}
 
-        results = append(results, student)
+func (r *Recorder) FindByName(name string, idIndex *btree.Tree) ([]models.Student, error) {
+    ids := r.Indexes.Lookup("name", name)
+    if len(ids) == 0 {
+        return nil, fmt.Errorf("no records found with name: %s", name)
     }
 
-    return results, nil
+    return r.readStudents(ids, idIndex)
 }
 
-func (r *Recorder) FindByGpa(gpa float64, gpaIndex map[float64]map[int]bool, idIndex map[int]int64, dbFilePath string) ([]models.Student, error) {
-    ids, exists := gpaIndex[gpa]
-    if !exists {
+func (r *Recorder) FindByGpa(gpa float64, idIndex *btree.Tree) ([]models.Student, error) {
+    ids := r.Indexes.Lookup("gpa", gpa)
+    if len(ids) == 0 {
         return nil, fmt.Errorf("no records found with GPA: %f", gpa)
     }
 
-    var results []models.Student
-    file, err := os.Open(dbFilePath)
-    if err != nil {
-        return nil, err
-    }
-    defer file.Close()
-
-    for id := range ids {
-        offset, exists := idIndex[id]
-        if !exists {
-            continue
-        }
-
-        _, err = file.Seek(offset, 0)
-        if err != nil {
-            continue
-        }
+    return r.readStudents(ids, idIndex)
+}
 
-        scanner := bufio.NewScanner(file)
-        scanner.Scan()
-        line := scanner.Text()
+func (r *Recorder) FindByActive(active bool, idIndex *btree.Tree) ([]models.Student, error) {
+    ids := r.Indexes.Lookup("active", active)
+    if len(ids) == 0 {
+        return nil, fmt.Errorf("no records found with active: %t", active)
+    }
 
-        var student models.Student
-        err := json.Unmarshal([]byte(line), &student)
-        if err != nil {
-            continue
-        }
+    return r.readStudents(ids, idIndex)
+}
 
-        results = append(results, student)
+// FindByIndex looks value up in the runtime index on field.
+func (r *Recorder) FindByIndex(field string, value any, idIndex *btree.Tree) ([]models.Student, error) {
+    idx, exists := r.Indexes.Get(field)
+    if !exists {
+        return nil, fmt.Errorf("no index on %s", field)
     }
 
-    return results, nil
+    return r.readStudents(idx.Lookup(value), idIndex)
 }
 
-func (r *Recorder) FindByActive(active bool, activeIndex map[bool]map[int]bool, idIndex map[int]int64, dbFilePath string) ([]models.Student, error) {
-    ids, exists := activeIndex[active]
+// FindByIndexRange returns the students with from <= field <= to, the index
+// on field must be ordered.
+func (r *Recorder) FindByIndexRange(field string, from, to any, idIndex *btree.Tree) ([]models.Student, error) {
+    idx, exists := r.Indexes.Get(field)
     if !exists {
-        return nil, fmt.Errorf("no records found with active: %t", active)
+        return nil, fmt.Errorf("no index on %s", field)
     }
-
-    var results []models.Student
-    file, err := os.Open(dbFilePath)
-    if err != nil {
-        return nil, err
+    ordered, ok := idx.(index.RangeIndex)
+    if !ok {
+        return nil, fmt.Errorf("index on %s is %s, range queries need an ordered index", field, idx.Kind())
     }
-    defer file.Close()
 
-    for id := range ids {
-        offset, exists := idIndex[id]
-        if !exists {
-            continue
-        }
-
-        _, err = file.Seek(offset, 0)
-        if err != nil {
-            continue
-        }
+    return r.readStudents(ordered.Range(from, to), idIndex)
+}
 
-        scanner := bufio.NewScanner(file)
-        scanner.Scan()
-        line := scanner.Text()
+// FindByBitmap reads the students in ids, usually the result of combining
+// bitmaps of several indexes with bitmap.And, Or and AndNot.
+func (r *Recorder) FindByBitmap(ids *bitmap.Bitmap, idIndex *btree.Tree) ([]models.Student, error) {
+    return r.readStudents(ids.Ids(), idIndex)
+}
 
-        var student models.Student
-        err := json.Unmarshal([]byte(line), &student)
+func (r *Recorder) readStudents(ids []int, idIndex *btree.Tree) ([]models.Student, error) {
+    results := make([]models.Student, 0, len(ids))
+    for _, id := range ids {
+        student, exists, err := r.readStudent(id, idIndex)
         if err != nil {
+            return nil, err
+        }
+        if !exists {
             continue
         }
-
         results = append(results, student)
     }
 
     return results, nil
 }
 
-func (r *Recorder) ImportFromXLSX(xlsxPath string, dbFilePath string, idIndex map[int]int64, nameIndex map[string]map[int]bool, gpaIndex map[float64]map[int]bool, activeIndex map[bool]map[int]bool, recordInfo map[int]models.RecordInfo) error {
+// ImportFromXLSX adds the students from a sheet of an XLSX file, laid out as
+// options describe, and reports what happened to every row. Existing ids are
+// handled by options.OnConflict. With DryRun set nothing is added and the
+// report can be applied later with ApplyImport.
+func (r *Recorder) ImportFromXLSX(xlsxPath string, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) (*ImportReport, error) {
+    if r.ReadOnly && !options.DryRun {
+        return nil, ErrReadOnly
+    }
+
     f, err := excelize.OpenFile(xlsxPath)
     if err != nil {
-        return fmt.Errorf("error opening XLSX file: %w", err)
+        return nil, fmt.Errorf("error opening XLSX file: %w", err)
     }
     defer f.Close()
 
-    rows, err := f.GetRows("Sheet1")
+    sheet := options.Sheet
+    if sheet == "" && len(f.GetSheetList()) > 0 {
+        sheet = f.GetSheetList()[0]
+    }
+    rows, err := f.GetRows(sheet)
     if err != nil {
-        return fmt.Errorf("error reading sheet: %w", err)
+        return nil, fmt.Errorf("error reading sheet: %w", err)
     }
     if len(rows) == 0 {
-        return fmt.Errorf("XLSX file is empty")
+        return nil, fmt.Errorf("sheet %q is empty", sheet)
     }
-    rows = rows[1:]
 
-    for _, row := range rows {
-        if len(row) < 4 {
-            continue
-        }
+    report, err := r.importRows(rows, options, idIndex, recordInfo)
+    if err != nil {
+        return report, fmt.Errorf("error adding records from XLSX: %w", err)
+    }
+    return report, nil
+}
 
-        id, err := strconv.Atoi(row[0])
-        if err != nil {
-            continue
-        }
+// XLSXSheet is a sheet of an XLSX file with its first row, from which the
+// user picks the columns to import.
+type XLSXSheet struct {
+    Name   string
+    Header []string
+}
 
-        name := row[1]
-        gpa, err := strconv.ParseFloat(row[2], 64)
-        if err != nil {
-            continue
-        }
+// XLSXSheets lists the sheets of an XLSX file. Only the first row of every
+// sheet is read.
+func XLSXSheets(xlsxPath string) ([]XLSXSheet, error) {
+    f, err := excelize.OpenFile(xlsxPath)
+    if err != nil {
+        return nil, fmt.Errorf("error opening XLSX file: %w", err)
+    }
+    defer f.Close()
 
-        active, err := strconv.ParseBool(row[3])
+    var sheets []XLSXSheet
+    for _, name := range f.GetSheetList() {
+        rows, err := f.Rows(name)
         if err != nil {
-			continue
+            return nil, fmt.Errorf("error reading sheet %q: %w", name, err)
         }
-
-        student := models.Student{
-            Id:     id,
-            Name:   name,
-            Gpa:    gpa,
-            Active: active,
-        }
-		record := models.Record{
-            Id:      student.Id,
-            Student: student,
+        sheet := XLSXSheet{Name: name}
+        if rows.Next() {
+            sheet.Header, err = rows.Columns()
         }
-
-        err = r.AddNewRecord(record, dbFilePath, idIndex, nameIndex, gpaIndex, activeIndex, recordInfo)
+        rows.Close()
         if err != nil {
-            return fmt.Errorf("error adding record from XLSX: %w", err)
+            return nil, fmt.Errorf("error reading sheet %q: %w", name, err)
         }
+        sheets = append(sheets, sheet)
     }
 
-    fmt.Println("Import from XLSX completed")
-    return nil
-}
\ No newline at end of file
+    return sheets, nil
+}
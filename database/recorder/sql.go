@@ -0,0 +1,485 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kgugunava/database/btree"
+	"github.com/kgugunava/database/models"
+)
+
+// SQLTable is the table SQL dumps of the database create and fill.
+const SQLTable = "students"
+
+type sqlTokenKind int
+
+const (
+	sqlEOF    sqlTokenKind = iota
+	sqlWord                // keyword or unquoted identifier
+	sqlIdent               // "quoted identifier"
+	sqlString              // 'string', quotes doubled inside
+	sqlNumber
+	sqlPunct // one character such as ( ) , ; .
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string // unquoted for strings and quoted identifiers
+	line int
+}
+
+func (t sqlToken) is(kind sqlTokenKind, text string) bool {
+	return t.kind == kind && strings.EqualFold(t.text, text)
+}
+
+// sqlLexer splits an SQL script into tokens, skipping whitespace and
+// comments. It knows enough SQL to read INSERT statements and to skip the
+// others, including semicolons in their strings.
+type sqlLexer struct {
+	text   string
+	pos    int
+	line   int
+	peeked *sqlToken
+}
+
+func newSQLLexer(text string) *sqlLexer {
+	return &sqlLexer{text: text, line: 1}
+}
+
+func (l *sqlLexer) peek() (sqlToken, error) {
+	if l.peeked == nil {
+		token, err := l.scan()
+		if err != nil {
+			return token, err
+		}
+		l.peeked = &token
+	}
+	return *l.peeked, nil
+}
+
+func (l *sqlLexer) next() (sqlToken, error) {
+	token, err := l.peek()
+	l.peeked = nil
+	return token, err
+}
+
+func isSQLWordByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 ||
+		!first && (c == '$' || c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *sqlLexer) scan() (sqlToken, error) {
+	text := l.text
+	for l.pos < len(text) {
+		switch {
+		case text[l.pos] == '\n':
+			l.line++
+			l.pos++
+		case text[l.pos] == ' ' || text[l.pos] == '\t' || text[l.pos] == '\r':
+			l.pos++
+		case strings.HasPrefix(text[l.pos:], "--"):
+			end := strings.IndexByte(text[l.pos:], '\n')
+			if end < 0 {
+				end = len(text) - l.pos
+			}
+			l.pos += end
+		case strings.HasPrefix(text[l.pos:], "/*"):
+			end := strings.Index(text[l.pos+2:], "*/")
+			if end < 0 {
+				return sqlToken{}, fmt.Errorf("line %d: comment is not closed", l.line)
+			}
+			l.line += strings.Count(text[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return l.scanToken()
+		}
+	}
+	return sqlToken{kind: sqlEOF, line: l.line}, nil
+}
+
+func (l *sqlLexer) scanToken() (sqlToken, error) {
+	text := l.text
+	start, line := l.pos, l.line
+	c := text[start]
+
+	switch {
+	case c == '\'' || c == '"':
+		var b strings.Builder
+		for l.pos++; l.pos < len(text); l.pos++ {
+			if text[l.pos] == '\n' {
+				l.line++
+			}
+			if text[l.pos] != c {
+				b.WriteByte(text[l.pos])
+				continue
+			}
+			if l.pos+1 < len(text) && text[l.pos+1] == c {
+				b.WriteByte(c)
+				l.pos++
+				continue
+			}
+			l.pos++
+			kind := sqlString
+			if c == '"' {
+				kind = sqlIdent
+			}
+			return sqlToken{kind: kind, text: b.String(), line: line}, nil
+		}
+		return sqlToken{}, fmt.Errorf("line %d: quote is not closed", line)
+
+	case isDigit(c) || (c == '-' || c == '+' || c == '.') && l.pos+1 < len(text) && (isDigit(text[l.pos+1]) || text[l.pos+1] == '.'):
+		l.pos++
+		for l.pos < len(text) {
+			c := text[l.pos]
+			exponentSign := (c == '-' || c == '+') && (text[l.pos-1] == 'e' || text[l.pos-1] == 'E')
+			if !isDigit(c) && c != '.' && c != 'e' && c != 'E' && !exponentSign {
+				break
+			}
+			l.pos++
+		}
+		return sqlToken{kind: sqlNumber, text: text[start:l.pos], line: line}, nil
+
+	case isSQLWordByte(c, true):
+		for l.pos++; l.pos < len(text) && isSQLWordByte(text[l.pos], false); l.pos++ {
+		}
+		return sqlToken{kind: sqlWord, text: text[start:l.pos], line: line}, nil
+	}
+
+	l.pos++
+	return sqlToken{kind: sqlPunct, text: text[start:l.pos], line: line}, nil
+}
+
+// sqlInsert is an INSERT statement of a dump: the table, the columns if the
+// statement names them and the rows of values as text. NULL is an empty
+// cell, as a missing value is in a table.
+type sqlInsert struct {
+	table   string
+	columns []string
+	rows    [][]string
+	lines   []int // line each row of values starts on
+}
+
+// expect reads the next token and fails unless it is text, compared without
+// case.
+func (l *sqlLexer) expect(text string) error {
+	token, err := l.next()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(token.text, text) || token.kind == sqlString || token.kind == sqlIdent {
+		return fmt.Errorf("line %d: expected %s, found %q", token.line, text, token.text)
+	}
+	return nil
+}
+
+func (l *sqlLexer) name() (string, error) {
+	token, err := l.next()
+	if err != nil {
+		return "", err
+	}
+	if token.kind != sqlWord && token.kind != sqlIdent {
+		return "", fmt.Errorf("line %d: expected a name, found %q", token.line, token.text)
+	}
+	return token.text, nil
+}
+
+// skipStatement reads up to the end of the statement, the semicolon included.
+func (l *sqlLexer) skipStatement() error {
+	for {
+		token, err := l.next()
+		if err != nil {
+			return err
+		}
+		if token.kind == sqlEOF || token.is(sqlPunct, ";") {
+			return nil
+		}
+	}
+}
+
+// parseSQLDump calls fn for every INSERT statement of a script and skips the
+// other statements. A statement it cannot read stops the parsing, as
+// everything after it may be read wrong.
+func parseSQLDump(text string, fn func(sqlInsert) error) error {
+	lexer := newSQLLexer(text)
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case token.kind == sqlEOF:
+			return nil
+		case token.is(sqlWord, "insert"):
+			insert, err := lexer.insert()
+			if err != nil {
+				return err
+			}
+			err = fn(insert)
+			if err != nil {
+				return err
+			}
+		case !token.is(sqlPunct, ";"):
+			err = lexer.skipStatement()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// insert reads an INSERT statement after its first word:
+// INTO [schema.]table [(columns)] VALUES (values), ... [anything];
+func (l *sqlLexer) insert() (sqlInsert, error) {
+	var insert sqlInsert
+
+	err := l.expect("into")
+	if err != nil {
+		return insert, err
+	}
+	insert.table, err = l.name()
+	if err != nil {
+		return insert, err
+	}
+	if token, err := l.peek(); err == nil && token.is(sqlPunct, ".") {
+		l.next()
+		insert.table, err = l.name()
+		if err != nil {
+			return insert, err
+		}
+	}
+
+	token, err := l.peek()
+	if err != nil {
+		return insert, err
+	}
+	if token.is(sqlPunct, "(") {
+		l.next()
+		for {
+			column, err := l.name()
+			if err != nil {
+				return insert, err
+			}
+			insert.columns = append(insert.columns, column)
+			token, err := l.next()
+			if err != nil {
+				return insert, err
+			}
+			if token.is(sqlPunct, ")") {
+				break
+			}
+			if !token.is(sqlPunct, ",") {
+				return insert, fmt.Errorf("line %d: expected , or ) in the column list, found %q", token.line, token.text)
+			}
+		}
+	}
+
+	err = l.expect("values")
+	if err != nil {
+		return insert, err
+	}
+	for {
+		token, err := l.peek()
+		if err != nil {
+			return insert, err
+		}
+		row, err := l.values()
+		if err != nil {
+			return insert, err
+		}
+		insert.rows = append(insert.rows, row)
+		insert.lines = append(insert.lines, token.line)
+
+		token, err = l.peek()
+		if err != nil {
+			return insert, err
+		}
+		if !token.is(sqlPunct, ",") {
+			break
+		}
+		l.next()
+	}
+
+	// clauses such as ON CONFLICT DO NOTHING do not change the rows
+	return insert, l.skipStatement()
+}
+
+// values reads one parenthesised row of literal values.
+func (l *sqlLexer) values() ([]string, error) {
+	err := l.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	var row []string
+	for {
+		token, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case token.kind == sqlString || token.kind == sqlNumber:
+			row = append(row, token.text)
+		case token.is(sqlWord, "null"):
+			row = append(row, "")
+		case token.is(sqlWord, "true") || token.is(sqlWord, "false"):
+			row = append(row, strings.ToLower(token.text))
+		case token.is(sqlWord, "unistr"):
+			// sqlite3 .dump writes strings with control characters this way
+			text, err := l.unistr()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, text)
+		default:
+			return nil, fmt.Errorf("line %d: unsupported value %q, only literals can be imported", token.line, token.text)
+		}
+
+		token, err = l.next()
+		if err != nil {
+			return nil, err
+		}
+		if token.is(sqlPunct, ")") {
+			return row, nil
+		}
+		if !token.is(sqlPunct, ",") {
+			return nil, fmt.Errorf("line %d: expected , or ) in the values, found %q", token.line, token.text)
+		}
+	}
+}
+
+// unistr reads the argument of unistr('...'), whose \XXXX, \uXXXX,
+// \+XXXXXX and \UXXXXXXXX escapes are hexadecimal code points and \\ is a
+// backslash.
+func (l *sqlLexer) unistr() (string, error) {
+	err := l.expect("(")
+	if err != nil {
+		return "", err
+	}
+	token, err := l.next()
+	if err != nil {
+		return "", err
+	}
+	if token.kind != sqlString {
+		return "", fmt.Errorf("line %d: unistr takes a string", token.line)
+	}
+	err = l.expect(")")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	text := token.text
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteByte(text[i])
+			continue
+		}
+		if strings.HasPrefix(text[i+1:], "\\") {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+
+		digits, skip := 4, 1
+		switch {
+		case strings.HasPrefix(text[i+1:], "u"):
+			skip = 2
+		case strings.HasPrefix(text[i+1:], "+"):
+			digits, skip = 6, 2
+		case strings.HasPrefix(text[i+1:], "U"):
+			digits, skip = 8, 2
+		}
+		start := i + skip
+		if start+digits > len(text) {
+			return "", fmt.Errorf("line %d: invalid escape in unistr", token.line)
+		}
+		code, err := strconv.ParseUint(text[start:start+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", fmt.Errorf("line %d: invalid escape in unistr", token.line)
+		}
+		b.WriteRune(rune(code))
+		i = start + digits - 1
+	}
+	return b.String(), nil
+}
+
+// ImportFromSQL adds the students from an SQL script of INSERT statements,
+// such as one made by Db.ExportSQL or pg_dump --inserts, with the same report
+// and conflict policy as the table imports. Only the rows of one table are
+// taken: options.Sheet, or the first table the script inserts into. Columns
+// are found by the names in the statements, mapped by options.Columns like a
+// header, and statements without column names list id, name, gpa and active
+// in that order. Other statements are skipped. Rows of the report count the
+// tuples of the table from 1, whatever statements they are in, and the reason
+// a row is skipped names the line its values start on.
+func (r *Recorder) ImportFromSQL(reader io.Reader, options ImportOptions, idIndex *btree.Tree, recordInfo *models.RecordTable) (*ImportReport, error) {
+	if r.ReadOnly && !options.DryRun {
+		return nil, ErrReadOnly
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SQL: %w", err)
+	}
+
+	report := newImportReport(options.OnConflict)
+	table := options.Sheet
+	var parsed []ImportRow
+	err = parseSQLDump(string(data), func(insert sqlInsert) error {
+		if table == "" {
+			table = insert.table
+		}
+		if !strings.EqualFold(insert.table, table) {
+			return nil
+		}
+
+		cols := positionalColumns()
+		if insert.columns != nil {
+			var err error
+			cols, _, err = mapColumns(insert.columns, ImportOptions{Columns: options.Columns, OnConflict: options.OnConflict})
+			if err != nil {
+				return fmt.Errorf("line %d: %w", insert.lines[0], err)
+			}
+		}
+
+		for i, row := range insert.rows {
+			report.Rows++
+			tuple := report.Rows
+			if insert.columns != nil && len(row) != len(insert.columns) {
+				err := fmt.Errorf("line %d: %d values for %d columns", insert.lines[i], len(row), len(insert.columns))
+				report.Skipped = append(report.Skipped, RowError{Row: tuple, Err: err})
+				continue
+			}
+			student, missing, err := parseRow(row, cols)
+			if err != nil {
+				err = fmt.Errorf("line %d: %w", insert.lines[i], err)
+				report.Skipped = append(report.Skipped, RowError{Row: tuple, Id: student.Id, Err: err})
+				continue
+			}
+			parsed = append(parsed, ImportRow{Row: tuple, Student: student, Missing: missing})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading SQL: %w", err)
+	}
+	if table == "" {
+		return nil, errors.New("SQL script has no INSERT statements")
+	}
+
+	report.plan(parsed, recordInfo)
+
+	err = r.finishImport(report, options, idIndex, recordInfo)
+	if err != nil {
+		return report, fmt.Errorf("error adding records from SQL: %w", err)
+	}
+	return report, nil
+}
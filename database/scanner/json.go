@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ElementError is an element of JSON input that could not be read. Index is
+// the element of an array or the line of NDJSON, from 1.
+type ElementError struct {
+	Index int
+	Err   error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("element %d: %v", e.Index, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// JSONElement is one element of a JSON array or one line of NDJSON, not
+// decoded yet so that a bad element does not stop the others. Err is set
+// instead of Data for a line that could not be read at all, such as one over
+// MaxRecordSize.
+type JSONElement struct {
+	Index int
+	Data  json.RawMessage
+	Err   error
+}
+
+// ReadJSON calls fn for every element of r, which holds either a JSON array
+// or NDJSON, told apart by the first character after a UTF-8 BOM and spaces.
+// Blank NDJSON lines are skipped. Elements are read one at a time, so r can
+// be a large file or a stream such as stdin. An array that is not well-formed
+// JSON cannot be read past the broken place and ends with an *ElementError
+// for it, which tells the byte the error was found at, counted from 1.
+func (s *Scanner) ReadJSON(r io.Reader, fn func(JSONElement) error) error {
+	reader := bufio.NewReader(r)
+	var skipped int64
+	if b, _ := reader.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		n, _ := reader.Discard(len(utf8BOM))
+		skipped = int64(n)
+	}
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isJSONSpace(b[0]) {
+			break
+		}
+		reader.ReadByte()
+		skipped++
+	}
+
+	b, _ := reader.Peek(1)
+	if b[0] == '[' {
+		return readJSONArray(reader, skipped, fn)
+	}
+	return readNDJSON(reader, fn)
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+func isJSONSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// readJSONArray reads the array from r, which starts offset bytes into the
+// input.
+func readJSONArray(r io.Reader, offset int64, fn func(JSONElement) error) error {
+	decoder := json.NewDecoder(r)
+	decoder.Token() // the opening bracket ReadJSON has seen
+
+	// a syntax error knows where it is, other errors are placed at the start
+	// of the element
+	errorAt := func(err error) error {
+		at := offset + decoder.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			at = offset + syntaxErr.Offset
+		}
+		return fmt.Errorf("at byte %d: %w", at, err)
+	}
+
+	index := 0
+	for decoder.More() {
+		index++
+		var data json.RawMessage
+		err := decoder.Decode(&data)
+		if err != nil {
+			return &ElementError{Index: index, Err: errorAt(err)}
+		}
+		err = fn(JSONElement{Index: index, Data: data})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := decoder.Token()
+	if err != nil {
+		return &ElementError{Index: index + 1, Err: errorAt(fmt.Errorf("array is not closed: %w", err))}
+	}
+	return nil
+}
+
+func readNDJSON(r io.Reader, fn func(JSONElement) error) error {
+	reader := NewRecordReader(r, 0)
+	for index := 1; ; index++ {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var tooLarge *RecordTooLargeError
+		if errors.As(err, &tooLarge) {
+			err = fn(JSONElement{Index: index, Err: err})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		err = fn(JSONElement{Index: index, Data: bytes.Clone(line)})
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readElements returns the elements ReadJSON finds by index, "" for a blank
+// NDJSON line and "!" for one that could not be read, and the error it ends
+// with.
+func readElements(t *testing.T, r io.Reader) ([]string, error) {
+	t.Helper()
+	var elements []string
+	err := NewScanner().ReadJSON(r, func(element JSONElement) error {
+		// NDJSON counts lines, blank ones included
+		for len(elements) < element.Index-1 {
+			elements = append(elements, "")
+		}
+		if element.Err != nil {
+			elements = append(elements, "!")
+		} else {
+			elements = append(elements, string(element.Data))
+		}
+		return nil
+	})
+	return elements, err
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		elements []string
+	}{
+		{name: "array", input: `[{"id":1}, {"id":2},"x"]`, elements: []string{`{"id":1}`, `{"id":2}`, `"x"`}},
+		{name: "array over lines", input: "\n  [\n {\"id\":1},\n\n {\"id\":2}\n]\n", elements: []string{`{"id":1}`, `{"id":2}`}},
+		{name: "empty array", input: "[]"},
+		{name: "ndjson", input: "{\"id\":1}\r\n{\"id\":2}\n", elements: []string{`{"id":1}`, `{"id":2}`}},
+		{name: "ndjson with blank lines", input: "{\"id\":1}\n\n  \n{\"id\":4}", elements: []string{`{"id":1}`, "", "", `{"id":4}`}},
+		{name: "ndjson line that is not json", input: "{\"id\":1}\nnot json\n", elements: []string{`{"id":1}`, "not json"}},
+		{name: "array with bom", input: "\xef\xbb\xbf[{\"id\":1}]", elements: []string{`{"id":1}`}},
+		{name: "ndjson with bom", input: "\xef\xbb\xbf{\"id\":1}\n{\"id\":2}", elements: []string{`{"id":1}`, `{"id":2}`}},
+		{name: "empty", input: " \n"},
+		{name: "only a bom", input: "\xef\xbb\xbf"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			elements, err := readElements(t, strings.NewReader(test.input))
+			if err != nil || !slices.Equal(elements, test.elements) {
+				t.Fatalf("ReadJSON = %q, %v, want %q", elements, err, test.elements)
+			}
+		})
+	}
+}
+
+func TestReadJSONBrokenArray(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		elements []string
+		index    int
+		at       string
+	}{
+		{name: "bad element", input: `[{"id":1}, {"id":2,}, {"id":3}]`, elements: []string{`{"id":1}`}, index: 2, at: "at byte 20:"},
+		{name: "missing comma", input: `[{"id":1} {"id":2}]`, elements: []string{`{"id":1}`}, index: 2, at: "at byte 11:"},
+		{name: "offset counts the bom and spaces", input: "\xef\xbb\xbf \n[{\"id\":1}, {\"id\": tru}]", elements: []string{`{"id":1}`}, index: 2, at: "at byte 27:"},
+		{name: "not closed", input: `[{"id":1}, {"id":2}`, elements: []string{`{"id":1}`, `{"id":2}`}, index: 3, at: "at byte 19: unexpected end"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			elements, err := readElements(t, strings.NewReader(test.input))
+			var broken *ElementError
+			if !errors.As(err, &broken) || broken.Index != test.index || !strings.Contains(err.Error(), test.at) {
+				t.Fatalf("ReadJSON ended with %v, want element %d %s", err, test.index, test.at)
+			}
+			if !slices.Equal(elements, test.elements) {
+				t.Fatalf("elements before the error = %q, want %q", elements, test.elements)
+			}
+		})
+	}
+}
+
+func TestReadJSONLongLine(t *testing.T) {
+	input := "{\"id\":1}\n\"" + strings.Repeat("x", DefaultMaxRecordSize) + "\"\n{\"id\":3}\n"
+	elements, err := readElements(t, strings.NewReader(input))
+	if err != nil || !slices.Equal(elements, []string{`{"id":1}`, "!", `{"id":3}`}) {
+		t.Fatalf("ReadJSON = %d elements, %v", len(elements), err)
+	}
+}
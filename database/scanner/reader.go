@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxRecordSize bounds how much memory one line may take. Lines of any
+// length below it are read whole, unlike bufio.Scanner which gives up at 64 KiB.
+const DefaultMaxRecordSize = 16 * 1024 * 1024
+
+// RecordTooLargeError reports a line longer than MaxRecordSize. The reader
+// skips such a line, so the caller may log the error and keep reading.
+type RecordTooLargeError struct {
+	Offset int64
+	Size   int64
+	Limit  int
+}
+
+func (e *RecordTooLargeError) Error() string {
+	return fmt.Sprintf("record at offset %d is %d bytes, limit is %d", e.Offset, e.Size, e.Limit)
+}
+
+// RecordReader reads newline separated records and keeps track of the offset
+// of each one in the underlying file.
+type RecordReader struct {
+	MaxRecordSize int
+
+	r      *bufio.Reader
+	offset int64
+	line   []byte
+}
+
+// NewRecordReader reads from r, whose first byte is at offset in the file.
+func NewRecordReader(r io.Reader, offset int64) *RecordReader {
+	return &RecordReader{
+		MaxRecordSize: DefaultMaxRecordSize,
+		r:             bufio.NewReader(r),
+		offset:        offset,
+	}
+}
+
+// Next returns the next line without its newline and the offset it starts at.
+// The slice is only valid until the following call. At the end of input the
+// error is io.EOF; a last line without a trailing newline is still returned.
+func (rr *RecordReader) Next() ([]byte, int64, error) {
+	start := rr.offset
+	rr.line = rr.line[:0]
+	var size int64
+	tooLarge := false
+
+	for {
+		chunk, err := rr.r.ReadSlice('\n')
+		size += int64(len(chunk))
+
+		length := size
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+			length--
+		}
+
+		if !tooLarge {
+			if rr.MaxRecordSize > 0 && length > int64(rr.MaxRecordSize) {
+				tooLarge = true
+				rr.line = rr.line[:0]
+			} else {
+				rr.line = append(rr.line, chunk...)
+			}
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		rr.offset += size
+
+		if err != nil && err != io.EOF {
+			return nil, start, err
+		}
+		if err == io.EOF && size == 0 {
+			return nil, start, io.EOF
+		}
+
+		if tooLarge {
+			return nil, start, &RecordTooLargeError{Offset: start, Size: length, Limit: rr.MaxRecordSize}
+		}
+
+		line := rr.line[:length]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		return line, start, nil
+	}
+}
@@ -1,10 +1,10 @@
 package scanner
 
 import (
-	"log"
+	"fmt"
 	"os"
-	"bufio"
 	"encoding/json"
+	"io"
 
 	"github.com/kgugunava/database/models"
 )
@@ -17,31 +17,44 @@ func NewScanner() *Scanner {
 	return &Scanner{}
 }
 
-func (s *Scanner) ReadFileInList(fileName string) [][]byte {
+// ReadFileInList returns the lines of a file. A line longer than
+// DefaultMaxRecordSize fails with *RecordTooLargeError.
+func (s *Scanner) ReadFileInList(fileName string) ([][]byte, error) {
 	var res [][]byte
 
 	file, err := os.Open(fileName)
 	if err != nil {
-		log.Fatal("Error while openning file: ", err)
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		res = append(res, scanner.Bytes())
+	reader := NewRecordReader(file, 0)
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %s: %w", fileName, err)
+		}
+		res = append(res, append([]byte(nil), line...))
 	}
 
-	return res
+	return res, nil
 }
 
-func (s *Scanner) ParseJson(data [][]byte) []models.Student {
+// ParseJson decodes one student per line. Lines that are not a student are
+// reported by their index from 1 and do not stop the others.
+func (s *Scanner) ParseJson(data [][]byte) ([]models.Student, []ElementError) {
 	var res []models.Student
-	for _, value := range data {
+	var failed []ElementError
+	for i, value := range data {
 		var curStudent models.Student
 		if err := json.Unmarshal(value, &curStudent); err != nil {
-			log.Fatal("Error while parsing json: ", err)
+			failed = append(failed, ElementError{Index: i + 1, Err: err})
+			continue
 		}
 		res = append(res, curStudent)
 	}
-	return res
-}
\ No newline at end of file
+	return res, failed
+}
@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileInList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	err := os.WriteFile(path, []byte("{\"id\": 1}\n{\"id\": 2}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := NewScanner().ReadFileInList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || string(lines[1]) != "{\"id\": 2}" {
+		t.Fatalf("lines = %q", lines)
+	}
+}
+
+func TestReadFileInListErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewScanner().ReadFileInList(filepath.Join(dir, "missing.jsonl"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing file: err = %v", err)
+	}
+
+	path := filepath.Join(dir, "large.jsonl")
+	line := bytes.Repeat([]byte("x"), DefaultMaxRecordSize+1)
+	err = os.WriteFile(path, append(line, '\n'), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewScanner().ReadFileInList(path)
+	var tooLarge *RecordTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("large line: err = %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"container/list"
+	"os"
+)
+
+// DefaultPoolPages is how many pages a paged file keeps in memory, 1 MiB.
+const DefaultPoolPages = 256
+
+// BufferPool caches pages of a file with LRU eviction. Changed pages are only
+// marked dirty and reach the file when they are evicted or on Flush.
+type BufferPool struct {
+	file     *os.File
+	capacity int
+	frames   map[int64]*list.Element
+	lru      *list.List // front is the most recently used frame
+
+	Hits   int
+	Misses int
+}
+
+type frame struct {
+	pageNo int64
+	data   []byte
+	dirty  bool
+}
+
+func NewBufferPool(file *os.File, capacity int) *BufferPool {
+	return &BufferPool{
+		file:     file,
+		capacity: max(capacity, 1),
+		frames:   make(map[int64]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the cached page, reading it from the file on a miss. The slice
+// stays owned by the pool: callers that change it must call MarkDirty.
+func (bp *BufferPool) Get(pageNo int64) ([]byte, error) {
+	if elem, ok := bp.frames[pageNo]; ok {
+		bp.Hits++
+		bp.lru.MoveToFront(elem)
+		return elem.Value.(*frame).data, nil
+	}
+	bp.Misses++
+
+	data := make([]byte, PageSize)
+	if _, err := bp.file.ReadAt(data, pageNo*PageSize); err != nil {
+		return nil, err
+	}
+
+	if err := bp.add(&frame{pageNo: pageNo, data: data}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// NewPage caches an empty page past the end of the file, it is written on
+// eviction or Flush like any other dirty page.
+func (bp *BufferPool) NewPage(pageNo int64) ([]byte, error) {
+	data := make([]byte, PageSize)
+	if err := bp.add(&frame{pageNo: pageNo, data: data, dirty: true}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (bp *BufferPool) MarkDirty(pageNo int64) {
+	if elem, ok := bp.frames[pageNo]; ok {
+		elem.Value.(*frame).dirty = true
+	}
+}
+
+func (bp *BufferPool) add(f *frame) error {
+	for bp.lru.Len() >= bp.capacity {
+		if err := bp.evict(); err != nil {
+			return err
+		}
+	}
+	bp.frames[f.pageNo] = bp.lru.PushFront(f)
+	return nil
+}
+
+func (bp *BufferPool) evict() error {
+	elem := bp.lru.Back()
+	f := elem.Value.(*frame)
+	if f.dirty {
+		if _, err := bp.file.WriteAt(f.data, f.pageNo*PageSize); err != nil {
+			return err
+		}
+	}
+	bp.lru.Remove(elem)
+	delete(bp.frames, f.pageNo)
+	return nil
+}
+
+// Flush writes every dirty page, they stay cached.
+func (bp *BufferPool) Flush() error {
+	for elem := bp.lru.Back(); elem != nil; elem = elem.Prev() {
+		f := elem.Value.(*frame)
+		if !f.dirty {
+			continue
+		}
+		if _, err := bp.file.WriteAt(f.data, f.pageNo*PageSize); err != nil {
+			return err
+		}
+		f.dirty = false
+	}
+	return nil
+}
+
+// Drop forgets every cached page without writing it.
+func (bp *BufferPool) Drop() {
+	bp.frames = make(map[int64]*list.Element)
+	bp.lru.Init()
+}
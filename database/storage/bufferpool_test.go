@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newPoolFile creates a file of pages filled with their page number.
+func newPoolFile(t *testing.T, pages int) *os.File {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "pages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	for pageNo := range pages {
+		page := make([]byte, PageSize)
+		page[0] = byte(pageNo)
+		if _, err := file.Write(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file
+}
+
+func filePage(t *testing.T, file *os.File, pageNo int64) byte {
+	t.Helper()
+	page := make([]byte, PageSize)
+	if _, err := file.ReadAt(page, pageNo*PageSize); err != nil {
+		t.Fatal(err)
+	}
+	return page[0]
+}
+
+func TestBufferPoolLRU(t *testing.T) {
+	bp := NewBufferPool(newPoolFile(t, 4), 2)
+	for _, pageNo := range []int64{0, 1, 0, 2} {
+		page, err := bp.Get(pageNo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page[0] != byte(pageNo) {
+			t.Fatalf("page %d starts with %d", pageNo, page[0])
+		}
+	}
+	// page 1 was used least recently and is gone, 0 and 2 are cached
+	if bp.Hits != 1 || bp.Misses != 3 {
+		t.Fatalf("hits %d, misses %d, want 1 and 3", bp.Hits, bp.Misses)
+	}
+	for _, pageNo := range []int64{0, 2} {
+		if _, err := bp.Get(pageNo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bp.Hits != 3 {
+		t.Fatalf("pages 0 and 2 were not cached, hits %d", bp.Hits)
+	}
+	if _, err := bp.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if bp.Misses != 4 {
+		t.Fatalf("page 1 was still cached, misses %d", bp.Misses)
+	}
+}
+
+func TestBufferPoolWriteBack(t *testing.T) {
+	file := newPoolFile(t, 4)
+	bp := NewBufferPool(file, 2)
+
+	dirty, err := bp.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirty[0] = 100
+	bp.MarkDirty(0)
+	clean, err := bp.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a change without MarkDirty never reaches the file
+	clean[0] = 101
+	if got := filePage(t, file, 0); got != 0 {
+		t.Fatalf("a dirty page was written before eviction: %d", got)
+	}
+
+	for _, pageNo := range []int64{2, 3} {
+		if _, err := bp.Get(pageNo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := filePage(t, file, 0); got != 100 {
+		t.Fatalf("the evicted dirty page was not written: %d", got)
+	}
+	if got := filePage(t, file, 1); got != 1 {
+		t.Fatalf("the evicted clean page was written: %d", got)
+	}
+
+	// a new page is dirty from the start and Flush keeps it cached
+	page, err := bp.NewPage(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page[0] = 104
+	if err := bp.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := filePage(t, file, 4); got != 104 {
+		t.Fatalf("Flush did not write the new page: %d", got)
+	}
+	misses := bp.Misses
+	if _, err := bp.Get(4); err != nil {
+		t.Fatal(err)
+	}
+	if bp.Misses != misses {
+		t.Fatal("Flush dropped the page from the pool")
+	}
+
+	// Drop forgets changes that were not flushed
+	page[0] = 50
+	bp.Drop()
+	if got := filePage(t, file, 4); got != 104 {
+		t.Fatalf("Drop wrote the page: %d", got)
+	}
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+	"github.com/kgugunava/database/scanner"
+)
+
+// File is the append-only data file. Every change is a new record at the end,
+// so locations are byte offsets and never move.
+type File struct {
+	Path  string
+	Codec codec.Codec
+	// Mmap makes ReadAt decode records from a read-only mapping of the file
+	// instead of reading them, on platforms that have mmap.
+	Mmap bool
+
+	mu     sync.RWMutex
+	reader *os.File // opened on the first ReadAt, shared by all reads
+	mapped []byte
+}
+
+func NewFile(path string, c codec.Codec) *File {
+	return &File{Path: path, Codec: c}
+}
+
+func (f *File) Append(student models.Student) (int64, error) {
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	data, err := f.Codec.Encode(student)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		return 0, err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return fileInfo.Size() - int64(len(data)), nil
+}
+
+// fileBatch writes the records through one buffered writer and learns their
+// offsets by counting bytes instead of asking the file.
+type fileBatch struct {
+	file   *os.File
+	w      *bufio.Writer
+	codec  codec.Codec
+	start  int64
+	offset int64
+	err    error
+}
+
+func (f *File) Batch() (Batch, error) {
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &fileBatch{
+		file:   file,
+		w:      bufio.NewWriterSize(file, 1<<20),
+		codec:  f.Codec,
+		start:  info.Size(),
+		offset: info.Size(),
+	}, nil
+}
+
+func (b *fileBatch) Append(student models.Student) (int64, error) {
+	data, err := b.codec.Encode(student)
+	if err != nil {
+		return 0, err
+	}
+
+	loc := b.offset
+	if b.err == nil {
+		_, b.err = b.w.Write(data)
+	}
+	b.offset += int64(len(data))
+	return loc, nil
+}
+
+// Commit flushes the records. If that fails the file is cut back to where
+// the batch started, so a reload does not find half of them.
+func (b *fileBatch) Commit() error {
+	err := b.err
+	if err == nil {
+		err = b.w.Flush()
+	}
+	if err != nil {
+		err = errors.Join(err, b.file.Truncate(b.start))
+	}
+	return errors.Join(err, b.file.Close())
+}
+
+func (f *File) Update(loc int64, student models.Student) (int64, error) {
+	return f.Append(student)
+}
+
+// Delete appends a tombstone, the old record stays in the file.
+func (f *File) Delete(loc int64, student models.Student) error {
+	_, err := f.Append(models.Student{Id: student.Id, Version: student.Version + 1, Deleted: true})
+	return err
+}
+
+// ReadAt reads through one handle kept open until Close. os.File.ReadAt is a
+// pread, so concurrent reads don't need to share a file position.
+func (f *File) ReadAt(loc int64) (models.Student, error) {
+	if f.Mmap && mmapSupported {
+		return f.readMapped(loc)
+	}
+
+	file, err := f.readHandle()
+	if err != nil {
+		return models.Student{}, err
+	}
+
+	return codec.ReadAt(f.Codec, file, loc)
+}
+
+// readMapped decodes the record straight from the mapping. A record appended
+// after the file was mapped lies past its end, then the file is mapped again
+// with its new size.
+func (f *File) readMapped(loc int64) (models.Student, error) {
+	f.mu.RLock()
+	if loc < int64(len(f.mapped)) {
+		defer f.mu.RUnlock()
+		return codec.DecodeAt(f.Codec, f.mapped, loc)
+	}
+	f.mu.RUnlock()
+
+	err := f.remap()
+	if err != nil {
+		return models.Student{}, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return codec.DecodeAt(f.Codec, f.mapped, loc)
+}
+
+func (f *File) remap() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := f.openReader()
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// another reader may have remapped it in the meantime
+	if info.Size() <= int64(len(f.mapped)) {
+		return nil
+	}
+
+	data, err := mapFile(file, info.Size())
+	if err != nil {
+		return fmt.Errorf("error mapping %s: %w", f.Path, err)
+	}
+	f.unmap()
+	f.mapped = data
+	return nil
+}
+
+// unmap drops the mapping, the caller holds the write lock.
+func (f *File) unmap() error {
+	if f.mapped == nil {
+		return nil
+	}
+	err := unmapFile(f.mapped)
+	f.mapped = nil
+	return err
+}
+
+func (f *File) readHandle() (*os.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.openReader()
+}
+
+func (f *File) openReader() (*os.File, error) {
+	if f.reader == nil {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		f.reader = file
+	}
+	return f.reader, nil
+}
+
+func (f *File) Iterate(fn func(loc int64, student models.Student) error) error {
+	return f.IterateFrom(0, fn)
+}
+
+// IterateFrom goes over the records starting at offset. Records that cannot
+// be decoded are skipped like before; oversized ones are skipped too but
+// reported together at the end.
+func (f *File) IterateFrom(offset int64, fn func(loc int64, student models.Student) error) error {
+	return f.IterateRange(offset, math.MaxInt64, fn)
+}
+
+// IterateRange is IterateFrom for the records that start before to. Whatever
+// is appended at to or later while it runs, even a record still being
+// written, is not read.
+func (f *File) IterateRange(offset, to int64, fn func(loc int64, student models.Student) error) error {
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	reader := f.Codec.NewReader(file, offset)
+	var skipped []error
+
+	for {
+		student, loc, err := reader.Next()
+		if err == io.EOF || loc >= to {
+			break
+		}
+		var tooLarge *scanner.RecordTooLargeError
+		if errors.As(err, &tooLarge) {
+			skipped = append(skipped, err)
+			continue
+		}
+		var decodeErr *codec.DecodeError
+		if errors.As(err, &decodeErr) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", f.Path, err)
+		}
+
+		if err := fn(loc, student); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(skipped...)
+}
+
+func (f *File) Reset() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// touching a mapped page past the end of a truncated file is a SIGBUS
+	err := f.unmap()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path, nil, 0644)
+}
+
+func (f *File) Sync() error {
+	file, err := os.OpenFile(f.Path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return file.Sync()
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.unmap()
+	if f.reader != nil {
+		err = errors.Join(err, f.reader.Close())
+		f.reader = nil
+	}
+	return err
+}
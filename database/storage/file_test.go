@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+)
+
+func TestIterateRange(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSONL, codec.Binary} {
+		path := filepath.Join(t.TempDir(), "input"+c.Extension())
+		file := NewFile(path, c)
+		defer file.Close()
+
+		var offsets []int64
+		for id := range 4 {
+			offset, err := file.Append(models.Student{Id: id, Name: "student", Version: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			offsets = append(offsets, offset)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a record another process has only begun to write
+		record, _ := c.Encode(models.Student{Id: 9, Name: "partial", Version: 1})
+		out, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(record[:len(record)/2])
+		out.Close()
+
+		for _, bounds := range [][3]int64{
+			{0, info.Size(), 4},
+			{offsets[1], info.Size(), 3},
+			{0, offsets[2], 2},
+			{offsets[3], offsets[3], 0},
+		} {
+			var ids []int
+			err := file.IterateRange(bounds[0], bounds[1], func(loc int64, student models.Student) error {
+				ids = append(ids, student.Id)
+				return nil
+			})
+			if err != nil || len(ids) != int(bounds[2]) {
+				t.Fatalf("%s: IterateRange(%d, %d) = %v, %v", c.Name(), bounds[0], bounds[1], ids, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "container/heap"
+
+// freeSpace is the free-space map of a paged file: the reusable bytes of every
+// page, see pageFree, kept in a max-heap so that Append finds the page with
+// the most room in O(1) and a page changes its place in O(log pages).
+type freeSpace struct {
+	free  []int   // page - reusable bytes
+	index []int   // page - position in pages
+	pages []int64 // heap of page numbers, the roomiest first
+}
+
+// set records the free bytes of a page, pageNo may be the next new page.
+func (f *freeSpace) set(pageNo int64, free int) {
+	if pageNo == int64(len(f.free)) {
+		f.free = append(f.free, free)
+		f.index = append(f.index, 0)
+		heap.Push(f, pageNo)
+		return
+	}
+	f.free[pageNo] = free
+	heap.Fix(f, f.index[pageNo])
+}
+
+// roomiest returns the page with the most free bytes, -1 when there are no pages.
+func (f *freeSpace) roomiest() (int64, int) {
+	if len(f.pages) == 0 {
+		return -1, 0
+	}
+	return f.pages[0], f.free[f.pages[0]]
+}
+
+func (f *freeSpace) reset() {
+	*f = freeSpace{}
+}
+
+func (f *freeSpace) Len() int {
+	return len(f.pages)
+}
+
+func (f *freeSpace) Less(i, j int) bool {
+	return f.free[f.pages[i]] > f.free[f.pages[j]]
+}
+
+func (f *freeSpace) Swap(i, j int) {
+	f.pages[i], f.pages[j] = f.pages[j], f.pages[i]
+	f.index[f.pages[i]] = i
+	f.index[f.pages[j]] = j
+}
+
+func (f *freeSpace) Push(x any) {
+	pageNo := x.(int64)
+	f.index[pageNo] = len(f.pages)
+	f.pages = append(f.pages, pageNo)
+}
+
+func (f *freeSpace) Pop() any {
+	pageNo := f.pages[len(f.pages)-1]
+	f.pages = f.pages[:len(f.pages)-1]
+	return pageNo
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/kgugunava/database/models"
+)
+
+// Memory keeps records in a map and loses them on exit. It is meant for tests
+// and experiments that should not touch the disk.
+type Memory struct {
+	records map[int64]models.Student
+	next    int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{records: make(map[int64]models.Student)}
+}
+
+func (m *Memory) Append(student models.Student) (int64, error) {
+	loc := m.next
+	m.next++
+	m.records[loc] = student
+	return loc, nil
+}
+
+func (m *Memory) Update(loc int64, student models.Student) (int64, error) {
+	if _, exists := m.records[loc]; !exists {
+		return 0, ErrNotFound
+	}
+	m.records[loc] = student
+	return loc, nil
+}
+
+func (m *Memory) Delete(loc int64, student models.Student) error {
+	if _, exists := m.records[loc]; !exists {
+		return ErrNotFound
+	}
+	delete(m.records, loc)
+	return nil
+}
+
+func (m *Memory) ReadAt(loc int64) (models.Student, error) {
+	student, exists := m.records[loc]
+	if !exists {
+		return student, ErrNotFound
+	}
+	return student, nil
+}
+
+func (m *Memory) Iterate(fn func(loc int64, student models.Student) error) error {
+	locs := make([]int64, 0, len(m.records))
+	for loc := range m.records {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool { return locs[i] < locs[j] })
+
+	for _, loc := range locs {
+		if err := fn(loc, m.records[loc]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Reset() error {
+	m.records = make(map[int64]models.Student)
+	return nil
+}
+
+func (m *Memory) Sync() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) Batch() (Batch, error) {
+	return &appendBatch{st: m}, nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// Without mmap File.Mmap is ignored and reads go through ReadAt on the file.
+const mmapSupported = false
+
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
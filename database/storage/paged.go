@@ -0,0 +1,407 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+)
+
+// Paged stores records in fixed-size slotted pages, so deleted space can be
+// reused instead of growing the file forever. Pages go through a BufferPool,
+// changes reach the disk when a page is evicted, on Sync and on Close.
+//
+// Page layout (little endian):
+//
+//	slot count uint16 | data start uint16 | slots (offset uint16, length uint16)...
+//	free space ... record data packed towards the end of the page
+//
+// A slot with length 0 is free. Records are encoded with codec.Binary, which
+// carries its own checksum. A location is page number << 16 | slot number.
+type Paged struct {
+	file     *os.File
+	pool     *BufferPool
+	pages    int64
+	freeMap  freeSpace
+	readOnly bool
+}
+
+const (
+	PageSize       = 4096
+	pageHeaderSize = 4
+	slotSize       = 4
+	// MaxRecordSize is the largest encoded record that fits into an empty page.
+	MaxRecordSize = PageSize - pageHeaderSize - slotSize
+)
+
+var ErrRecordTooLarge = errors.New("record does not fit into a page")
+
+func OpenPaged(path string, readOnly bool) (*Paged, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size()%PageSize != 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a paged data file: size %d is not a multiple of %d", path, info.Size(), PageSize)
+	}
+
+	p := &Paged{
+		file:     file,
+		pool:     NewBufferPool(file, DefaultPoolPages),
+		pages:    info.Size() / PageSize,
+		readOnly: readOnly,
+	}
+
+	// the free-space map is cheap to rebuild, one pass over the page headers
+	for pageNo := int64(0); pageNo < p.pages; pageNo++ {
+		page, err := p.readPage(pageNo)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		p.freeMap.set(pageNo, pageFree(page))
+	}
+
+	return p, nil
+}
+
+func location(pageNo int64, slot int) int64 {
+	return pageNo<<16 | int64(slot)
+}
+
+func splitLocation(loc int64) (int64, int) {
+	return loc >> 16, int(loc & 0xFFFF)
+}
+
+// readPage returns the page from the buffer pool, changes to it must be
+// followed by writePage.
+func (p *Paged) readPage(pageNo int64) ([]byte, error) {
+	return p.pool.Get(pageNo)
+}
+
+func (p *Paged) writePage(pageNo int64, page []byte) {
+	p.pool.MarkDirty(pageNo)
+	p.freeMap.set(pageNo, pageFree(page))
+}
+
+func slotCount(page []byte) int {
+	return int(binary.LittleEndian.Uint16(page[0:]))
+}
+
+func dataStart(page []byte) int {
+	start := int(binary.LittleEndian.Uint16(page[2:]))
+	if start == 0 {
+		return PageSize
+	}
+	return start
+}
+
+func getSlot(page []byte, slot int) (int, int) {
+	pos := pageHeaderSize + slot*slotSize
+	return int(binary.LittleEndian.Uint16(page[pos:])), int(binary.LittleEndian.Uint16(page[pos+2:]))
+}
+
+func setSlot(page []byte, slot, offset, length int) {
+	pos := pageHeaderSize + slot*slotSize
+	binary.LittleEndian.PutUint16(page[pos:], uint16(offset))
+	binary.LittleEndian.PutUint16(page[pos+2:], uint16(length))
+}
+
+// pageFree is how many bytes a new record may take on the page, counting the
+// space of deleted records that compaction would give back and a new slot.
+func pageFree(page []byte) int {
+	used := pageHeaderSize + slotCount(page)*slotSize
+	hasFreeSlot := false
+	for slot := 0; slot < slotCount(page); slot++ {
+		_, length := getSlot(page, slot)
+		used += length
+		if length == 0 {
+			hasFreeSlot = true
+		}
+	}
+	if !hasFreeSlot {
+		used += slotSize
+	}
+	return max(PageSize-used, 0)
+}
+
+// compactPage moves live records to the end of the page so that all free
+// space is contiguous. Slot numbers do not change, so locations stay valid.
+func compactPage(page []byte) {
+	compacted := make([]byte, PageSize)
+	copy(compacted, page[:pageHeaderSize+slotCount(page)*slotSize])
+
+	end := PageSize
+	for slot := 0; slot < slotCount(page); slot++ {
+		offset, length := getSlot(page, slot)
+		if length == 0 {
+			continue
+		}
+		end -= length
+		copy(compacted[end:], page[offset:offset+length])
+		setSlot(compacted, slot, end, length)
+	}
+	binary.LittleEndian.PutUint16(compacted[2:], uint16(end))
+
+	copy(page, compacted)
+}
+
+// insertIntoPage puts data into a free slot, or a new one, and returns its
+// number. The caller has checked pageFree.
+func insertIntoPage(page []byte, data []byte) int {
+	slot := slotCount(page)
+	for i := 0; i < slotCount(page); i++ {
+		if _, length := getSlot(page, i); length == 0 {
+			slot = i
+			break
+		}
+	}
+
+	if slot == slotCount(page) {
+		// the new slot entry takes the bytes right after the slot directory,
+		// which may still hold record data
+		slotsEnd := pageHeaderSize + slotCount(page)*slotSize
+		if dataStart(page)-len(data)-slotSize < slotsEnd {
+			compactPage(page)
+		}
+		binary.LittleEndian.PutUint16(page[0:], uint16(slot+1))
+		setSlot(page, slot, 0, 0)
+	}
+	placeInSlot(page, slot, data)
+
+	return slot
+}
+
+// placeInSlot writes data for a free slot, compacting the page first when the
+// free space is fragmented.
+func placeInSlot(page []byte, slot int, data []byte) {
+	slotsEnd := pageHeaderSize + slotCount(page)*slotSize
+	if dataStart(page)-len(data) < slotsEnd {
+		compactPage(page)
+	}
+
+	start := dataStart(page) - len(data)
+	copy(page[start:], data)
+	binary.LittleEndian.PutUint16(page[2:], uint16(start))
+	setSlot(page, slot, start, len(data))
+}
+
+func (p *Paged) Append(student models.Student) (int64, error) {
+	data, err := codec.Binary.Encode(student)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > MaxRecordSize {
+		return 0, ErrRecordTooLarge
+	}
+
+	// the page with the most room, a new one if even that one is too full
+	pageNo, free := p.freeMap.roomiest()
+
+	var page []byte
+	if pageNo < 0 || free < len(data) {
+		pageNo = p.pages
+		page, err = p.pool.NewPage(pageNo)
+		if err != nil {
+			return 0, err
+		}
+		p.pages++
+	} else {
+		page, err = p.readPage(pageNo)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	slot := insertIntoPage(page, data)
+	p.writePage(pageNo, page)
+
+	return location(pageNo, slot), nil
+}
+
+// Update rewrites the record in its slot when the page has room for the new
+// version, so the location stays the same. Otherwise the new version is
+// appended elsewhere first and the old slot is freed only after that, so a
+// failed Update leaves the old record in place.
+func (p *Paged) Update(loc int64, student models.Student) (int64, error) {
+	data, err := codec.Binary.Encode(student)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > MaxRecordSize {
+		return 0, ErrRecordTooLarge
+	}
+
+	pageNo, slot := splitLocation(loc)
+	page, err := p.livePage(pageNo, slot)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, length := getSlot(page, slot)
+	if len(data) <= length {
+		copy(page[offset:], data)
+		setSlot(page, slot, offset, len(data))
+		p.writePage(pageNo, page)
+		return loc, nil
+	}
+
+	// the room the page would have without the old version
+	setSlot(page, slot, 0, 0)
+	if pageFree(page) >= len(data) {
+		placeInSlot(page, slot, data)
+		p.writePage(pageNo, page)
+		return loc, nil
+	}
+	setSlot(page, slot, offset, length)
+
+	newLoc, err := p.Append(student)
+	if err != nil {
+		return 0, err
+	}
+	// Append may have evicted the page from the pool
+	if err := p.Delete(loc, student); err != nil {
+		p.Delete(newLoc, student)
+		return 0, err
+	}
+	return newLoc, nil
+}
+
+// livePage returns the page holding a record in slot, or ErrNotFound.
+func (p *Paged) livePage(pageNo int64, slot int) ([]byte, error) {
+	if pageNo >= p.pages {
+		return nil, ErrNotFound
+	}
+
+	page, err := p.readPage(pageNo)
+	if err != nil {
+		return nil, err
+	}
+	if slot >= slotCount(page) {
+		return nil, ErrNotFound
+	}
+	if _, length := getSlot(page, slot); length == 0 {
+		return nil, ErrNotFound
+	}
+
+	return page, nil
+}
+
+func (p *Paged) Delete(loc int64, student models.Student) error {
+	pageNo, slot := splitLocation(loc)
+	page, err := p.livePage(pageNo, slot)
+	if err != nil {
+		return err
+	}
+
+	setSlot(page, slot, 0, 0)
+	p.writePage(pageNo, page)
+
+	return nil
+}
+
+func (p *Paged) ReadAt(loc int64) (models.Student, error) {
+	pageNo, slot := splitLocation(loc)
+	if pageNo >= p.pages {
+		return models.Student{}, ErrNotFound
+	}
+
+	page, err := p.readPage(pageNo)
+	if err != nil {
+		return models.Student{}, err
+	}
+
+	return decodeSlot(page, slot, loc)
+}
+
+func decodeSlot(page []byte, slot int, loc int64) (models.Student, error) {
+	if slot >= slotCount(page) {
+		return models.Student{}, ErrNotFound
+	}
+	offset, length := getSlot(page, slot)
+	if length == 0 {
+		return models.Student{}, ErrNotFound
+	}
+
+	return codec.ReadAt(codec.Binary, bytes.NewReader(page[offset:offset+length]), 0)
+}
+
+// Iterate returns only live records, the paged file keeps no history.
+func (p *Paged) Iterate(fn func(loc int64, student models.Student) error) error {
+	for pageNo := int64(0); pageNo < p.pages; pageNo++ {
+		page, err := p.readPage(pageNo)
+		if err != nil {
+			return err
+		}
+
+		for slot := 0; slot < slotCount(page); slot++ {
+			if _, length := getSlot(page, slot); length == 0 {
+				continue
+			}
+			loc := location(pageNo, slot)
+			student, err := decodeSlot(page, slot, loc)
+			if err != nil {
+				return fmt.Errorf("error reading record at page %d slot %d: %w", pageNo, slot, err)
+			}
+			if err := fn(loc, student); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Paged) Reset() error {
+	p.pool.Drop()
+	if err := p.file.Truncate(0); err != nil {
+		return err
+	}
+	p.pages = 0
+	p.freeMap.reset()
+	return nil
+}
+
+// Sync writes the dirty pages back and flushes the file to disk.
+func (p *Paged) Sync() error {
+	if p.readOnly {
+		return nil
+	}
+	if err := p.pool.Flush(); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *Paged) Close() error {
+	err := p.Sync()
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Batch goes through the buffer pool like Append, the pages are written
+// back when they leave the pool or on Sync.
+func (p *Paged) Batch() (Batch, error) {
+	return &appendBatch{st: p}, nil
+}
+
+// PoolStats reports buffer pool hits and misses since the file was opened.
+func (p *Paged) PoolStats() (hits, misses int) {
+	return p.pool.Hits, p.pool.Misses
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kgugunava/database/models"
+)
+
+func openPagedTest(t *testing.T, path string) *Paged {
+	t.Helper()
+	p, err := OpenPaged(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func pagedStudent(id int, name string) models.Student {
+	return models.Student{Id: id, Name: name, Gpa: 3.5, Active: id%2 == 0, Version: 1}
+}
+
+// checkPaged reads every record in want by its location and checks that
+// Iterate returns exactly them.
+func checkPaged(t *testing.T, p *Paged, want map[int64]models.Student) {
+	t.Helper()
+	for loc, student := range want {
+		got, err := p.ReadAt(loc)
+		if err != nil || got != student {
+			t.Fatalf("ReadAt(%x) = %+v, %v, want %+v", loc, got, err, student)
+		}
+	}
+	got := make(map[int64]models.Student)
+	err := p.Iterate(func(loc int64, student models.Student) error {
+		got[loc] = student
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("Iterate returned %d records, want %d", len(got), len(want))
+	}
+}
+
+func TestPagedSlots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input"+PagedExtension)
+	p := openPagedTest(t, path)
+
+	// enough records for several pages
+	want := make(map[int64]models.Student)
+	var locs []int64
+	for id := range 300 {
+		student := pagedStudent(id, fmt.Sprintf("student %d", id))
+		loc, err := p.Append(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, taken := want[loc]; taken {
+			t.Fatalf("location %x given out twice", loc)
+		}
+		want[loc] = student
+		locs = append(locs, loc)
+	}
+	if p.pages < 3 {
+		t.Fatalf("300 records fit into %d pages", p.pages)
+	}
+	checkPaged(t, p, want)
+
+	// the last page has the most room, so a slot freed there is the next one
+	// used and no page is added for it
+	pages := p.pages
+	freed := locs[len(locs)-2]
+	if err := p.Delete(freed, want[freed]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ReadAt(freed); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ReadAt of a deleted record: %v", err)
+	}
+	if err := p.Delete(freed, want[freed]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting twice: %v", err)
+	}
+	delete(want, freed)
+	student := pagedStudent(1000, "reused")
+	loc, err := p.Append(student)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc != freed || p.pages != pages {
+		t.Fatalf("the new record went to %x with %d pages, want %x with %d", loc, p.pages, freed, pages)
+	}
+	want[loc] = student
+	checkPaged(t, p, want)
+}
+
+func TestPagedFreeSpaceReuse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input"+PagedExtension)
+	p := openPagedTest(t, path)
+
+	want := make(map[int64]models.Student)
+	var firstPage []int64
+	for id := 0; p.pages < 4; id++ {
+		student := pagedStudent(id, fmt.Sprintf("student %d", id))
+		loc, err := p.Append(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[loc] = student
+		if pageNo, _ := splitLocation(loc); pageNo == 0 {
+			firstPage = append(firstPage, loc)
+		}
+	}
+
+	// half of the first page is freed, new records fill it before a fifth
+	// page is added
+	for _, loc := range firstPage[:len(firstPage)/2] {
+		if err := p.Delete(loc, want[loc]); err != nil {
+			t.Fatal(err)
+		}
+		delete(want, loc)
+	}
+	for id := 5000; id < 5000+len(firstPage)/2; id++ {
+		student := pagedStudent(id, fmt.Sprintf("student %d", id%1000))
+		loc, err := p.Append(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[loc] = student
+	}
+	if p.pages != 4 {
+		t.Fatalf("%d pages after reusing freed space, want 4", p.pages)
+	}
+	checkPaged(t, p, want)
+}
+
+func TestPagedUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input"+PagedExtension)
+	p := openPagedTest(t, path)
+
+	want := make(map[int64]models.Student)
+	var locs []int64
+	for id := 0; p.pages < 2; id++ {
+		student := pagedStudent(id, fmt.Sprintf("student %d", id))
+		loc, err := p.Append(student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[loc] = student
+		locs = append(locs, loc)
+	}
+	update := func(loc int64, name string) int64 {
+		t.Helper()
+		student := want[loc]
+		student.Name = name
+		student.Version++
+		newLoc, err := p.Update(loc, student)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(want, loc)
+		want[newLoc] = student
+		return newLoc
+	}
+
+	// shorter and as long stay in the slot
+	if loc := update(locs[0], "s"); loc != locs[0] {
+		t.Fatalf("a shorter record moved from %x to %x", locs[0], loc)
+	}
+	if loc := update(locs[1], "student X"); loc != locs[1] {
+		t.Fatalf("a record of the same size moved from %x to %x", locs[1], loc)
+	}
+	// a grown record stays on its page while it has room, the last page does
+	last := locs[len(locs)-1]
+	if loc := update(last, "student with a longer name"); loc != last {
+		t.Fatalf("a grown record moved from %x to %x although its page has room", last, loc)
+	}
+	// the first page is full, the record moves
+	grown := update(locs[2], strings.Repeat("long name ", 100))
+	if grown == locs[2] {
+		t.Fatal("a record that no longer fits its page kept its location")
+	}
+	if _, err := p.ReadAt(locs[2]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("the old version of a moved record is still there: %v", err)
+	}
+	if _, err := p.Update(locs[2], want[grown]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Update of a freed slot: %v", err)
+	}
+	if _, err := p.Update(0, pagedStudent(1, strings.Repeat("x", PageSize))); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("Update with a record larger than a page: %v", err)
+	}
+	checkPaged(t, p, want)
+
+	// everything above reaches the file
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p = openPagedTest(t, path)
+	checkPaged(t, p, want)
+	if err := p.Delete(grown, want[grown]); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, grown)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p = openPagedTest(t, path)
+	checkPaged(t, p, want)
+}
+
+// TestPagedUpdateKeepsRecordOnFailure makes the Append of a moved record fail
+// and checks that the old version is still readable.
+func TestPagedUpdateKeepsRecordOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input"+PagedExtension)
+	p := openPagedTest(t, path)
+	var locs []int64
+	for id := 0; p.pages < 3; id++ {
+		loc, err := p.Append(pagedStudent(id, fmt.Sprintf("student %d", id)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs = append(locs, loc)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first two pages are full; page 1 is dirty in a pool of two pages
+	// over a file that cannot be written, so adding a page fails
+	p = openPagedTest(t, path)
+	readOnly, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	p.pool = NewBufferPool(readOnly, 2)
+	p.pages = 2
+	p.freeMap.reset()
+	for pageNo := int64(0); pageNo < 2; pageNo++ {
+		page, err := p.readPage(pageNo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.freeMap.set(pageNo, pageFree(page))
+	}
+	onPage1 := locs[len(locs)-1]
+	for _, loc := range locs {
+		if pageNo, _ := splitLocation(loc); pageNo == 1 {
+			onPage1 = loc
+			break
+		}
+	}
+	student, _ := p.ReadAt(onPage1)
+	if _, err := p.Update(onPage1, student); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := p.ReadAt(locs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	grown := old
+	grown.Name = strings.Repeat("long name ", 100)
+	if _, err := p.Update(locs[0], grown); err == nil {
+		t.Fatal("Update succeeded without a place for the record")
+	}
+	if got, err := p.ReadAt(locs[0]); err != nil || got != old {
+		t.Fatalf("after a failed Update the record is %+v, %v", got, err)
+	}
+}
+
+func BenchmarkPagedAppend(b *testing.B) {
+	p, err := OpenPaged(filepath.Join(b.TempDir(), "input"+PagedExtension), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Append(pagedStudent(i, fmt.Sprintf("student %d", i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/kgugunava/database/codec"
+	"github.com/kgugunava/database/models"
+)
+
+// Storage keeps student records and addresses them by a location, which is
+// what IdIndex maps ids to. What a location means is up to the backend: a byte
+// offset for the append-only files, a page and slot for the paged file.
+type Storage interface {
+	// Append stores a new record and returns its location.
+	Append(student models.Student) (int64, error)
+	// Update replaces the record at loc and returns where the new one lives,
+	// which may differ from loc.
+	Update(loc int64, student models.Student) (int64, error)
+	// Delete removes the record at loc. student identifies it for backends
+	// that have to write a tombstone instead.
+	Delete(loc int64, student models.Student) error
+	ReadAt(loc int64) (models.Student, error)
+	// Iterate calls fn for every stored record in storage order. Append-only
+	// backends also return old versions and tombstones (Deleted is set), the
+	// last record for an id is the current one.
+	Iterate(fn func(loc int64, student models.Student) error) error
+	// Reset removes every record.
+	Reset() error
+	Sync() error
+	Close() error
+	// Batch starts appending many records at once, for bulk loads.
+	Batch() (Batch, error)
+}
+
+// Batch appends records faster than Append by keeping the file open between
+// them. Append fails only for a record that cannot be stored, e.g. cannot be
+// encoded; write errors are reported by Commit. Records are not readable
+// before Commit, and a failed Commit keeps none of them where the backend can
+// undo the writes.
+type Batch interface {
+	Append(student models.Student) (int64, error)
+	Commit() error
+}
+
+// appendBatch is the Batch of backends whose Append is already cheap.
+type appendBatch struct {
+	st Storage
+}
+
+func (b *appendBatch) Append(student models.Student) (int64, error) {
+	return b.st.Append(student)
+}
+
+func (b *appendBatch) Commit() error {
+	return nil
+}
+
+var ErrNotFound = errors.New("no record at this location")
+
+// PagedExtension selects the page-based backend in Open.
+const PagedExtension = ".pages"
+
+// Open picks the backend by the file extension: PagedExtension for the paged
+// file, anything else is an append-only file in the format given by
+// codec.ForPath.
+func Open(path string, readOnly bool) (Storage, error) {
+	if filepath.Ext(path) == PagedExtension {
+		return OpenPaged(path, readOnly)
+	}
+	return NewFile(path, codec.ForPath(path)), nil
+}
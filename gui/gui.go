@@ -1,8 +1,10 @@
 package gui
 
 import (
+    "errors"
     "fmt"
     "strconv"
+    "strings"
     "time"
     "os"
 
@@ -12,8 +14,11 @@ import (
     "fyne.io/fyne/v2/widget"
     "fyne.io/fyne/v2/dialog"
 
+    "github.com/kgugunava/database/bitmap"
     "github.com/kgugunava/database/db"
+    "github.com/kgugunava/database/index"
     "github.com/kgugunava/database/models"
+    "github.com/kgugunava/database/recorder"
 )
 
 type GUI struct {
@@ -26,11 +31,26 @@ type GUI struct {
     nameEntry  *widget.Entry
     gpaEntry   *widget.Entry
     activeEntry *widget.Entry
+
+    editIdEntry      *widget.Entry
+    editNameEntry    *widget.Entry
+    editGpaEntry     *widget.Entry
+    editActiveEntry  *widget.Entry
+    editVersionLabel *widget.Label
+    editVersion      int
+
+    indexesLabel *widget.Label
+
+    searchResults *bitmap.Bitmap // ids found by the last search, for export
 }
 
 func NewGUI(database *db.Db) *GUI {
     a := app.New()
-    w := a.NewWindow("Student Database")
+    title := "Student Database"
+    if database.ReadOnly {
+        title += " (read-only)"
+    }
+    w := a.NewWindow(title)
     w.Resize(fyne.NewSize(1200, 800))
 
     gui := &GUI{
@@ -59,7 +79,7 @@ func (g *GUI) setupUI() {
 
     // ТАБЛИЦА 
     g.list = widget.NewList(
-        func() int { return len(g.DB.IdIndex) },
+        func() int { return g.DB.IdIndex.Len() },
         func() fyne.CanvasObject {
             return container.NewHBox(
                 widget.NewLabel("ID: "),
@@ -82,6 +102,28 @@ func (g *GUI) setupUI() {
 
     addBtn := widget.NewButton("Add Student", g.addStudent)
 
+    // РЕДАКТИРОВАНИЕ
+    g.editIdEntry = widget.NewEntry()
+    g.editIdEntry.SetPlaceHolder("ID to edit")
+    g.editNameEntry = widget.NewEntry()
+    g.editNameEntry.SetPlaceHolder("Name")
+    g.editGpaEntry = widget.NewEntry()
+    g.editGpaEntry.SetPlaceHolder("GPA")
+    g.editActiveEntry = widget.NewEntry()
+    g.editActiveEntry.SetPlaceHolder("Active (true/false)")
+    g.editVersionLabel = widget.NewLabel("-")
+
+    editForm := widget.NewForm(
+        &widget.FormItem{Text: "ID", Widget: g.editIdEntry},
+        &widget.FormItem{Text: "Name", Widget: g.editNameEntry},
+        &widget.FormItem{Text: "GPA", Widget: g.editGpaEntry},
+        &widget.FormItem{Text: "Active", Widget: g.editActiveEntry},
+        &widget.FormItem{Text: "Version", Widget: g.editVersionLabel},
+    )
+
+    loadForEditBtn := widget.NewButton("Load", g.loadStudentForEdit)
+    saveEditBtn := widget.NewButton("Save Changes", g.editStudent)
+
     // УДАЛЕНИЕ 
     deleteIdEntry := widget.NewEntry()
     deleteIdEntry.SetPlaceHolder("ID to delete")
@@ -133,6 +175,8 @@ func (g *GUI) setupUI() {
     // ПОИСК 
     searchIdEntry := widget.NewEntry()
     searchIdEntry.SetPlaceHolder("ID to search")
+    searchIdToEntry := widget.NewEntry()
+    searchIdToEntry.SetPlaceHolder("Last ID of the range (for Search ID range)")
     searchNameEntry := widget.NewEntry()
     searchNameEntry.SetPlaceHolder("Name to search")
     searchGpaEntry := widget.NewEntry()
@@ -149,6 +193,20 @@ func (g *GUI) setupUI() {
         g.searchStudentByIdWithId(id)
     })
 
+    searchByIdRangeBtn := widget.NewButton("Search ID range", func() {
+        from, err := strconv.Atoi(searchIdEntry.Text)
+        if err != nil {
+            g.showNotification("Invalid ID")
+            return
+        }
+        to, err := strconv.Atoi(searchIdToEntry.Text)
+        if err != nil {
+            g.showNotification("Invalid last ID")
+            return
+        }
+        g.searchStudentsByIdRange(from, to)
+    })
+
     searchByNameBtn := widget.NewButton("Search by Name", func() {
         g.searchStudentByNameWithName(searchNameEntry.Text)
     })
@@ -173,24 +231,82 @@ func (g *GUI) setupUI() {
 
     searchForm := widget.NewForm(
         &widget.FormItem{Text: "ID", Widget: searchIdEntry},
+        &widget.FormItem{Text: "ID to", Widget: searchIdToEntry},
         &widget.FormItem{Text: "Name", Widget: searchNameEntry},
         &widget.FormItem{Text: "GPA", Widget: searchGpaEntry},
         &widget.FormItem{Text: "Active", Widget: searchActiveEntry},
     )
 
+    // ИНДЕКСЫ
+    indexFieldSelect := widget.NewSelect(index.FieldNames(), nil)
+    indexFieldSelect.PlaceHolder = "Field"
+    var kinds []string
+    for _, kind := range index.Kinds {
+        kinds = append(kinds, string(kind))
+    }
+    indexKindSelect := widget.NewSelect(kinds, nil)
+    indexKindSelect.SetSelected(string(index.Hash))
+    indexValueEntry := widget.NewEntry()
+    indexValueEntry.SetPlaceHolder("Value to search (first value of a range)")
+    indexToEntry := widget.NewEntry()
+    indexToEntry.SetPlaceHolder("Last value of the range (ordered indexes only)")
+    indexNotCheck := widget.NewCheck("NOT: students without the value", nil)
+    indexActiveSelect := widget.NewSelect([]string{"any", "true", "false"}, nil)
+    indexActiveSelect.SetSelected("any")
+    g.indexesLabel = widget.NewLabel("")
+    g.refreshIndexList()
+
+    createIndexBtn := widget.NewButton("Create Index", func() {
+        g.createIndex(indexFieldSelect.Selected, index.Kind(indexKindSelect.Selected))
+    })
+    dropIndexBtn := widget.NewButton("Drop Index", func() {
+        g.dropIndex(indexFieldSelect.Selected)
+    })
+    searchByIndexBtn := widget.NewButton("Search by Index", func() {
+        g.searchByIndex(indexFieldSelect.Selected, indexValueEntry.Text, indexNotCheck.Checked, indexActiveSelect.Selected)
+    })
+    searchByIndexRangeBtn := widget.NewButton("Search Index range", func() {
+        g.searchByIndexRange(indexFieldSelect.Selected, indexValueEntry.Text, indexToEntry.Text)
+    })
+
+    indexForm := widget.NewForm(
+        &widget.FormItem{Text: "Field", Widget: indexFieldSelect},
+        &widget.FormItem{Text: "Kind", Widget: indexKindSelect},
+        &widget.FormItem{Text: "Value", Widget: indexValueEntry},
+        &widget.FormItem{Text: "Value to", Widget: indexToEntry},
+        &widget.FormItem{Text: "", Widget: indexNotCheck},
+        &widget.FormItem{Text: "AND Active", Widget: indexActiveSelect},
+    )
+
     // ОСНОВНОЕ МЕНЮ
     backupBtn := widget.NewButton("Create Backup", g.createBackup)
+    incrementalBackupBtn := widget.NewButton("Incremental Backup", g.createIncrementalBackup)
     restoreBtn := widget.NewButton("Restore from Backup", g.restoreFromBackup)
     importBtn := widget.NewButton("Import from XLSX", g.importFromXLSX)
+    exportBtn := widget.NewButton("Export to Excel", g.exportToXLSX)
+    importCSVBtn := widget.NewButton("Import from CSV", g.importFromCSV)
+    exportCSVBtn := widget.NewButton("Export to CSV", g.exportToCSV)
+    importJSONBtn := widget.NewButton("Import from JSON", g.importFromJSON)
+    exportJSONBtn := widget.NewButton("Export to JSON", g.exportToJSON)
+    importSQLBtn := widget.NewButton("Import from SQL", g.importFromSQL)
+    exportSQLBtn := widget.NewButton("Export to SQL", g.exportToSQL)
+    exportParquetBtn := widget.NewButton("Export to Parquet", g.exportToParquet)
+    cacheStatsBtn := widget.NewButton("Cache Stats", g.showCacheStats)
 
     // КОНТЕНТ 
     content := container.NewVBox(
         widget.NewCard("Add Student", "", container.NewVBox(addForm, addBtn)),
+        widget.NewCard("Edit Student", "", container.NewVBox(editForm,
+            container.NewHBox(loadForEditBtn, saveEditBtn))),
         widget.NewCard("Delete Student", "", container.NewVBox(deleteForm, 
             container.NewHBox(deleteByIdBtn, deleteByNameBtn, deleteByGpaBtn, deleteByActiveBtn))),
         widget.NewCard("Search Student", "", container.NewVBox(searchForm,
-            container.NewHBox(searchByIdBtn, searchByNameBtn, searchByGpaBtn, searchByActiveBtn))),
-        container.NewHBox(backupBtn, restoreBtn, importBtn),
+            container.NewHBox(searchByIdBtn, searchByIdRangeBtn, searchByNameBtn, searchByGpaBtn, searchByActiveBtn))),
+        widget.NewCard("Indexes", "", container.NewVBox(g.indexesLabel, indexForm,
+            container.NewHBox(createIndexBtn, dropIndexBtn, searchByIndexBtn, searchByIndexRangeBtn))),
+        container.NewHBox(backupBtn, incrementalBackupBtn, restoreBtn, cacheStatsBtn),
+        container.NewHBox(importBtn, importCSVBtn, importJSONBtn, importSQLBtn),
+        container.NewHBox(exportBtn, exportCSVBtn, exportJSONBtn, exportSQLBtn, exportParquetBtn),
         widget.NewLabel("Records:"),
         g.list,
     )
@@ -231,11 +347,7 @@ func (g *GUI) addStudent() {
 
     err = g.DB.Recorder.AddNewRecord(
         record,
-        g.DB.FilePath,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -248,6 +360,118 @@ func (g *GUI) addStudent() {
     g.clearInputs()
 }
 
+// РЕДАКТИРОВАНИЕ
+
+func (g *GUI) loadStudentForEdit() {
+    id, err := strconv.Atoi(g.editIdEntry.Text)
+    if err != nil {
+        g.showNotification("Invalid ID")
+        return
+    }
+
+    student, err := g.DB.Recorder.FindById(id, g.DB.IdIndex)
+    if err != nil {
+        g.showNotification("Error searching: " + err.Error())
+        return
+    }
+
+    g.fillEditForm(*student)
+}
+
+func (g *GUI) fillEditForm(student models.Student) {
+    g.editIdEntry.SetText(strconv.Itoa(student.Id))
+    g.editNameEntry.SetText(student.Name)
+    g.editGpaEntry.SetText(strconv.FormatFloat(student.Gpa, 'f', -1, 64))
+    g.editActiveEntry.SetText(strconv.FormatBool(student.Active))
+    g.editVersion = student.Version
+    g.editVersionLabel.SetText(strconv.Itoa(student.Version))
+}
+
+func (g *GUI) editStudent() {
+    id, err := strconv.Atoi(g.editIdEntry.Text)
+    if err != nil {
+        g.showNotification("Invalid ID")
+        return
+    }
+
+    if g.editVersion == 0 {
+        g.showNotification("Load the student before editing")
+        return
+    }
+
+    gpa, err := strconv.ParseFloat(g.editGpaEntry.Text, 64)
+    if err != nil {
+        g.showNotification("Invalid GPA")
+        return
+    }
+
+    active, err := strconv.ParseBool(g.editActiveEntry.Text)
+    if err != nil {
+        g.showNotification("Invalid Active value")
+        return
+    }
+
+    student := models.Student{
+        Id:     id,
+        Name:   g.editNameEntry.Text,
+        Gpa:    gpa,
+        Active: active,
+    }
+
+    g.saveEdit(student, g.editVersion)
+}
+
+func (g *GUI) saveEdit(student models.Student, expectedVersion int) {
+    record := models.Record{
+        Id:      student.Id,
+        Student: student,
+    }
+
+    err := g.DB.Recorder.EditRecord(
+        record,
+        expectedVersion,
+        g.DB.IdIndex,
+        g.DB.RecordInfo,
+    )
+    var conflict *recorder.ConflictError
+    if errors.As(err, &conflict) {
+        g.showConflict(student, expectedVersion, conflict.Current)
+        return
+    }
+    if err != nil {
+        g.showNotification("Error editing record: " + err.Error())
+        return
+    }
+
+    info, _ := g.DB.RecordInfo.Get(student.Id)
+    g.editVersion = info.Version
+    g.editVersionLabel.SetText(strconv.Itoa(g.editVersion))
+    g.showNotification("Student updated successfully")
+    g.list.Refresh()
+}
+
+// showConflict shows the user's changes next to the version that is stored now
+// and lets them either overwrite it or reload the stored one.
+func (g *GUI) showConflict(mine models.Student, baseVersion int, current models.Student) {
+    describe := func(title string, student models.Student) fyne.CanvasObject {
+        return widget.NewCard(title, "", widget.NewLabel(fmt.Sprintf("Name: %s\nGPA: %.2f\nActive: %t",
+            student.Name, student.Gpa, student.Active)))
+    }
+
+    content := container.NewGridWithColumns(2,
+        describe(fmt.Sprintf("Your changes (based on version %d)", baseVersion), mine),
+        describe(fmt.Sprintf("Stored now (version %d)", current.Version), current),
+    )
+
+    dialog.ShowCustomConfirm("Edit conflict", "Overwrite", "Reload", content, func(overwrite bool) {
+        if overwrite {
+            g.saveEdit(mine, current.Version)
+            return
+        }
+        g.fillEditForm(current)
+    }, g.Window)
+}
+
 // УДАЛЕНИЕ 
 
 func (g *GUI) deleteStudentById() {
@@ -260,9 +484,6 @@ func (g *GUI) deleteStudentById() {
     err = g.DB.Recorder.DeleteRecordById(
         id,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -278,9 +499,6 @@ func (g *GUI) deleteStudentByName() {
     err := g.DB.Recorder.DeleteRecordByName(
         g.nameEntry.Text,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -302,9 +520,6 @@ func (g *GUI) deleteStudentByGpa() {
     err = g.DB.Recorder.DeleteRecordByGpa(
         gpa,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -326,9 +541,6 @@ func (g *GUI) deleteStudentByActive() {
     err = g.DB.Recorder.DeleteRecordByActive(
         active,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -349,7 +561,7 @@ func (g *GUI) searchStudentById() {
         return
     }
 
-    student, err := g.DB.Recorder.FindById(id, g.DB.IdIndex, g.DB.FilePath)
+    student, err := g.DB.Recorder.FindById(id, g.DB.IdIndex)
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
         return
@@ -361,9 +573,7 @@ func (g *GUI) searchStudentById() {
 func (g *GUI) searchStudentByName() {
     results, err := g.DB.Recorder.FindByName(
         g.nameEntry.Text,
-        g.DB.NameIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
@@ -382,9 +592,7 @@ func (g *GUI) searchStudentByGpa() {
 
     results, err := g.DB.Recorder.FindByGpa(
         gpa,
-        g.DB.GpaIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
@@ -403,9 +611,7 @@ func (g *GUI) searchStudentByActive() {
 
     results, err := g.DB.Recorder.FindByActive(
         active,
-        g.DB.ActiveIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
@@ -415,7 +621,130 @@ func (g *GUI) searchStudentByActive() {
     g.showNotification(fmt.Sprintf("Found %d records by Active", len(results)))
 }
 
-// BACKUP и XLSX 
+// ИНДЕКСЫ
+
+func (g *GUI) refreshIndexList() {
+    text := "Indexes:"
+    for _, def := range g.DB.Indexes() {
+        if def.Builtin {
+            text += fmt.Sprintf(" %s (%s, built-in)", def.Field, def.Kind)
+            continue
+        }
+        text += fmt.Sprintf(" %s (%s)", def.Field, def.Kind)
+    }
+    g.indexesLabel.SetText(text)
+}
+
+func (g *GUI) createIndex(field string, kind index.Kind) {
+    if field == "" {
+        g.showNotification("Select a field")
+        return
+    }
+
+    err := g.DB.CreateIndex(field, kind)
+    if err != nil {
+        g.showNotification("Error creating index: " + err.Error())
+        return
+    }
+
+    g.refreshIndexList()
+    g.showNotification(fmt.Sprintf("Index on %s created", field))
+}
+
+func (g *GUI) dropIndex(field string) {
+    if field == "" {
+        g.showNotification("Select a field")
+        return
+    }
+
+    err := g.DB.DropIndex(field)
+    if err != nil {
+        g.showNotification("Error dropping index: " + err.Error())
+        return
+    }
+
+    g.refreshIndexList()
+    g.showNotification(fmt.Sprintf("Index on %s dropped", field))
+}
+
+// searchByIndex combines the index with NOT and the Active bitmaps.
+func (g *GUI) searchByIndex(field, text string, not bool, active string) {
+    value, err := index.ParseValue(field, text)
+    if err != nil {
+        g.showNotification(err.Error())
+        return
+    }
+
+    ids, err := g.DB.Recorder.Indexes.Bitmap(field, value)
+    if err != nil {
+        g.showNotification("Error searching: " + err.Error())
+        return
+    }
+    condition := fmt.Sprintf("%s %v", field, value)
+    if not {
+        ids = bitmap.AndNot(g.DB.AllIds(), ids)
+        condition = "NOT " + condition
+    }
+    if active != "any" {
+        activeIds, err := g.DB.Recorder.Indexes.Bitmap("active", active == "true")
+        if err != nil {
+            g.showNotification("Error searching: " + err.Error())
+            return
+        }
+        ids = bitmap.And(ids, activeIds)
+        condition += " AND active " + active
+    }
+
+    results, err := g.DB.Recorder.FindByBitmap(ids, g.DB.IdIndex)
+    if err != nil {
+        g.showNotification("Error searching: " + err.Error())
+        return
+    }
+    g.rememberResults(results)
+
+    g.showStudents(fmt.Sprintf("Found %d students with %s:\n", len(results), condition), results)
+}
+
+func (g *GUI) searchByIndexRange(field, fromText, toText string) {
+    from, err := index.ParseValue(field, fromText)
+    if err != nil {
+        g.showNotification(err.Error())
+        return
+    }
+    to, err := index.ParseValue(field, toText)
+    if err != nil {
+        g.showNotification(err.Error())
+        return
+    }
+
+    results, err := g.DB.Recorder.FindByIndexRange(field, from, to, g.DB.IdIndex)
+    if err != nil {
+        g.showNotification("Error searching: " + err.Error())
+        return
+    }
+    g.rememberResults(results)
+
+    g.showStudents(fmt.Sprintf("Found %d students with %s from %v to %v:\n", len(results), field, from, to), results)
+}
+
+// rememberResults keeps the ids of a search so they can be exported.
+func (g *GUI) rememberResults(results []models.Student) {
+    g.searchResults = bitmap.New()
+    for _, student := range results {
+        g.searchResults.Add(student.Id)
+    }
+}
+
+func (g *GUI) showStudents(message string, results []models.Student) {
+    for _, student := range results {
+        message += fmt.Sprintf("\nID: %d, Name: %s, GPA: %.2f, Active: %t", 
+            student.Id, student.Name, student.Gpa, student.Active)
+    }
+
+    g.showNotification(message)
+}
+
+// BACKUP, ИМПОРТ И ЭКСПОРТ
 
 func (g *GUI) createBackup() {
     err := os.MkdirAll("./backups", 0755)
@@ -433,6 +762,24 @@ func (g *GUI) createBackup() {
     g.showNotification("Backup created successfully")
 }
 
+// createIncrementalBackup adds the changes since the last backup to the
+// newest chain in ./backups.
+func (g *GUI) createIncrementalBackup() {
+    err := g.DB.CreateIncrementalBackup("./backups")
+    if err != nil {
+        g.showNotification("Error creating incremental backup: " + err.Error())
+        return
+    }
+
+    g.showNotification("Incremental backup created successfully")
+}
+
+func (g *GUI) showCacheStats() {
+    cache := g.DB.Recorder.Cache
+    g.showNotification(fmt.Sprintf("Cache: %d records, %d hits, %d misses, hit rate %.1f%%",
+        cache.Len(), cache.Hits, cache.Misses, cache.HitRate()*100))
+}
+
 func (g *GUI) restoreFromBackup() {
     dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
         if err != nil || reader == nil {
@@ -462,13 +809,317 @@ func (g *GUI) importFromXLSX() {
 
         xlsxPath := reader.URI().Path()
 
-        err = g.DB.Recorder.ImportFromXLSX(
+        sheets, err := recorder.XLSXSheets(xlsxPath)
+        if err != nil {
+            g.showNotification("Error reading XLSX file: " + err.Error())
+            return
+        }
+        if len(sheets) == 0 {
+            g.showNotification("XLSX file has no sheets")
+            return
+        }
+        g.showXLSXImportDialog(xlsxPath, sheets)
+    }, g.Window)
+}
+
+func (g *GUI) importFromCSV() {
+    dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+        if err != nil || reader == nil {
+            return
+        }
+        defer reader.Close()
+
+        g.showCSVImportDialog(reader.URI().Path())
+    }, g.Window)
+}
+
+// importFromJSON reads a JSON array or NDJSON file. Its fields are named, so
+// only the conflict policy is asked for.
+func (g *GUI) importFromJSON() {
+    dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+        if err != nil || reader == nil {
+            return
+        }
+
+        conflictSelect := newConflictSelect()
+        items := []*widget.FormItem{widget.NewFormItem("Existing IDs", conflictSelect)}
+        dialog.ShowForm("Import from JSON", "Import", "Cancel", items, func(confirmed bool) {
+            defer reader.Close()
+            if !confirmed {
+                return
+            }
+
+            report, err := g.DB.Recorder.ImportFromJSON(
+                reader,
+                recorder.ImportOptions{DryRun: true, OnConflict: recorder.ConflictPolicy(conflictSelect.Selected)},
+                g.DB.IdIndex,
+                g.DB.RecordInfo,
+            )
+            if err != nil {
+                g.showNotification("Error importing from JSON: " + err.Error())
+                return
+            }
+
+            g.showImportPreview("Import from JSON", report)
+        }, g.Window)
+    }, g.Window)
+}
+
+// importFromSQL reads the INSERT statements of an SQL dump. The table is
+// asked for, as a dump may fill several.
+func (g *GUI) importFromSQL() {
+    dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+        if err != nil || reader == nil {
+            return
+        }
+
+        tableEntry := widget.NewEntry()
+        tableEntry.SetPlaceHolder("first table with rows")
+        conflictSelect := newConflictSelect()
+        items := []*widget.FormItem{
+            widget.NewFormItem("Table", tableEntry),
+            widget.NewFormItem("Existing IDs", conflictSelect),
+        }
+        dialog.ShowForm("Import from SQL", "Import", "Cancel", items, func(confirmed bool) {
+            defer reader.Close()
+            if !confirmed {
+                return
+            }
+
+            report, err := g.DB.Recorder.ImportFromSQL(
+                reader,
+                recorder.ImportOptions{
+                    Sheet:      strings.TrimSpace(tableEntry.Text),
+                    DryRun:     true,
+                    OnConflict: recorder.ConflictPolicy(conflictSelect.Selected),
+                },
+                g.DB.IdIndex,
+                g.DB.RecordInfo,
+            )
+            if err != nil {
+                g.showNotification("Error importing from SQL: " + err.Error())
+                return
+            }
+
+            g.showImportPreview("Import from SQL", report)
+        }, g.Window)
+    }, g.Window)
+}
+
+const (
+    exportAll     = "All students"
+    exportResults = "Current search results"
+)
+
+func (g *GUI) exportToXLSX() {
+    g.showExportDialog("Export to Excel", "students.xlsx", nil, g.DB.ExportXLSX)
+}
+
+func (g *GUI) exportToCSV() {
+    dialect := newDialectForm(false)
+    g.showExportDialog("Export to CSV", "students.csv", dialect.items(), func(path string, query db.Query) (int, error) {
+        return g.DB.ExportCSV(path, query, dialect.dialect())
+    })
+}
+
+func (g *GUI) exportToJSON() {
+    formats := make([]string, len(db.JSONFormats))
+    for i, format := range db.JSONFormats {
+        formats[i] = string(format)
+    }
+    formatSelect := widget.NewSelect(formats, nil)
+    formatSelect.SetSelected(formats[0])
+
+    items := []*widget.FormItem{widget.NewFormItem("Format", formatSelect)}
+    g.showExportDialog("Export to JSON", "students.json", items, func(path string, query db.Query) (int, error) {
+        file, err := os.Create(path)
+        if err != nil {
+            return 0, err
+        }
+        defer file.Close()
+        return g.DB.ExportJSON(file, query, db.JSONFormat(formatSelect.Selected))
+    })
+}
+
+func (g *GUI) exportToSQL() {
+    g.showExportDialog("Export to SQL", "students.sql", nil, func(path string, query db.Query) (int, error) {
+        file, err := os.Create(path)
+        if err != nil {
+            return 0, err
+        }
+        defer file.Close()
+        return g.DB.ExportSQL(file, query)
+    })
+}
+
+func (g *GUI) exportToParquet() {
+    g.showExportDialog("Export to Parquet", "students.parquet", nil, g.DB.ExportParquet)
+}
+
+// showExportDialog asks what to export and in which order, then where to save
+// it. extra are the settings of the format.
+func (g *GUI) showExportDialog(title, fileName string, extra []*widget.FormItem, export func(path string, query db.Query) (int, error)) {
+    scope := widget.NewRadioGroup([]string{exportAll, exportResults}, nil)
+    scope.SetSelected(exportAll)
+    if g.searchResults == nil {
+        scope.Disable()
+    } else {
+        scope.SetSelected(exportResults)
+    }
+
+    orderSelect := widget.NewSelect(db.OrderFields, nil)
+    orderSelect.SetSelected(db.OrderFields[0])
+    descCheck := widget.NewCheck("Descending", nil)
+
+    items := []*widget.FormItem{
+        widget.NewFormItem("Export", scope),
+        widget.NewFormItem("Order by", orderSelect),
+        widget.NewFormItem("", descCheck),
+    }
+    items = append(items, extra...)
+    dialog.ShowForm(title, "Export", "Cancel", items, func(confirmed bool) {
+        if !confirmed {
+            return
+        }
+
+        query := db.Query{OrderBy: orderSelect.Selected, Desc: descCheck.Checked}
+        if scope.Selected == exportResults {
+            query.Ids = g.searchResults
+        }
+
+        save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+            if err != nil || writer == nil {
+                return
+            }
+            // the export writes the file itself
+            writer.Close()
+
+            count, err := export(writer.URI().Path(), query)
+            if err != nil {
+                g.showNotification("Error exporting: " + err.Error())
+                return
+            }
+            g.showNotification(fmt.Sprintf("Exported %d students", count))
+        }, g.Window)
+        save.SetFileName(fileName)
+        save.Show()
+    }, g.Window)
+}
+
+// autoColumn lets the import find a column by its usual header names, and
+// the CSV import find the delimiter.
+const autoColumn = "(auto)"
+
+// importForm holds the settings every table import has: the header, the
+// column of each field and what to do with existing ids.
+type importForm struct {
+    columns  map[string]*widget.Select
+    header   *widget.Check
+    conflict *widget.Select
+}
+
+func newImportForm() *importForm {
+    form := &importForm{columns: make(map[string]*widget.Select)}
+    for _, field := range recorder.ImportFields {
+        form.columns[field] = widget.NewSelect([]string{autoColumn}, nil)
+        form.columns[field].SetSelected(autoColumn)
+    }
+
+    form.header = widget.NewCheck("First row is a header", func(checked bool) {
+        for _, sel := range form.columns {
+            if checked {
+                sel.Enable()
+            } else {
+                sel.Disable()
+            }
+        }
+    })
+    form.header.SetChecked(true)
+
+    form.conflict = newConflictSelect()
+    return form
+}
+
+// newConflictSelect offers the conflict policies of an import, skip first.
+func newConflictSelect() *widget.Select {
+    policies := make([]string, len(recorder.ConflictPolicies))
+    for i, policy := range recorder.ConflictPolicies {
+        policies[i] = string(policy)
+    }
+    sel := widget.NewSelect(policies, nil)
+    sel.SetSelected(policies[0])
+    return sel
+}
+
+// setHeader offers the texts of the first row for the columns.
+func (form *importForm) setHeader(header []string) {
+    options := []string{autoColumn}
+    for _, text := range header {
+        if text != "" {
+            options = append(options, text)
+        }
+    }
+    for _, sel := range form.columns {
+        sel.Options = options
+        sel.SetSelected(autoColumn)
+    }
+}
+
+func (form *importForm) items() []*widget.FormItem {
+    items := []*widget.FormItem{widget.NewFormItem("", form.header)}
+    for _, field := range recorder.ImportFields {
+        items = append(items, widget.NewFormItem("Column "+field, form.columns[field]))
+    }
+    return append(items, widget.NewFormItem("Existing IDs", form.conflict))
+}
+
+// options are for a dry run, the preview applies the report.
+func (form *importForm) options() recorder.ImportOptions {
+    options := recorder.ImportOptions{
+        NoHeader:   !form.header.Checked,
+        Columns:    make(map[string]string),
+        DryRun:     true,
+        OnConflict: recorder.ConflictPolicy(form.conflict.Selected),
+    }
+    for field, sel := range form.columns {
+        if sel.Selected != autoColumn {
+            options.Columns[field] = sel.Selected
+        }
+    }
+    return options
+}
+
+// showXLSXImportDialog asks for the sheet and which header holds each field.
+func (g *GUI) showXLSXImportDialog(xlsxPath string, sheets []recorder.XLSXSheet) {
+    form := newImportForm()
+
+    sheetNames := make([]string, len(sheets))
+    for i, sheet := range sheets {
+        sheetNames[i] = sheet.Name
+    }
+    sheetSelect := widget.NewSelect(sheetNames, func(name string) {
+        for _, sheet := range sheets {
+            if sheet.Name == name {
+                form.setHeader(sheet.Header)
+            }
+        }
+    })
+    sheetSelect.SetSelected(sheetNames[0])
+
+    items := append([]*widget.FormItem{widget.NewFormItem("Sheet", sheetSelect)}, form.items()...)
+
+    dialog.ShowForm("Import from XLSX", "Import", "Cancel", items, func(confirmed bool) {
+        if !confirmed {
+            return
+        }
+
+        options := form.options()
+        options.Sheet = sheetSelect.Selected
+
+        report, err := g.DB.Recorder.ImportFromXLSX(
             xlsxPath,
-            g.DB.FilePath,
+            options,
             g.DB.IdIndex,
-            g.DB.NameIndex,
-            g.DB.GpaIndex,
-            g.DB.ActiveIndex,
             g.DB.RecordInfo,
         )
         if err != nil {
@@ -476,9 +1127,220 @@ func (g *GUI) importFromXLSX() {
             return
         }
 
-        g.showNotification("Import completed successfully")
+        g.showImportPreview("Import from XLSX", report)
+    }, g.Window)
+}
+
+// showCSVImportDialog asks for the dialect of the file and which header holds
+// each field. The header is read again whenever the dialect changes.
+func (g *GUI) showCSVImportDialog(csvPath string) {
+    form := newImportForm()
+    dialect := newDialectForm(true)
+
+    readHeader := func() {
+        header, err := recorder.CSVHeader(csvPath, dialect.dialect())
+        if err != nil {
+            header = nil
+        }
+        form.setHeader(header)
+    }
+    readHeader()
+    dialect.onChanged(readHeader)
+
+    items := append(dialect.items(), form.items()...)
+
+    dialog.ShowForm("Import from CSV", "Import", "Cancel", items, func(confirmed bool) {
+        if !confirmed {
+            return
+        }
+
+        report, err := g.DB.Recorder.ImportFromCSV(
+            csvPath,
+            dialect.dialect(),
+            form.options(),
+            g.DB.IdIndex,
+            g.DB.RecordInfo,
+        )
+        if err != nil {
+            g.showNotification("Error importing from CSV: " + err.Error())
+            return
+        }
+
+        g.showImportPreview("Import from CSV", report)
+    }, g.Window)
+}
+
+// csvDelimiters are the delimiters offered for CSV files by name.
+var csvDelimiters = []struct {
+    name      string
+    delimiter rune
+}{{",", ','}, {";", ';'}, {"Tab", '\t'}, {"|", '|'}}
+
+// dialectForm holds the settings of a CSV file. Reading also offers to find
+// the delimiter and the encoding, writing offers the decimal comma.
+type dialectForm struct {
+    delimiter    *widget.Select
+    quoting      *widget.Select
+    encoding     *widget.Select
+    decimalComma *widget.Check
+}
+
+func newDialectForm(reading bool) *dialectForm {
+    var delimiters []string
+    if reading {
+        delimiters = append(delimiters, autoColumn)
+    }
+    for _, d := range csvDelimiters {
+        delimiters = append(delimiters, d.name)
+    }
+
+    var quotings, encodings []string
+    for _, quoting := range recorder.CSVQuotings {
+        quotings = append(quotings, string(quoting))
+    }
+    for _, encoding := range recorder.CSVEncodings {
+        if encoding == recorder.EncodingAuto && !reading {
+            continue
+        }
+        encodings = append(encodings, string(encoding))
+    }
+
+    form := &dialectForm{
+        delimiter: widget.NewSelect(delimiters, nil),
+        quoting:   widget.NewSelect(quotings, nil),
+        encoding:  widget.NewSelect(encodings, nil),
+    }
+    form.delimiter.SetSelected(delimiters[0])
+    form.quoting.SetSelected(quotings[0])
+    form.encoding.SetSelected(encodings[0])
+    if !reading {
+        form.decimalComma = widget.NewCheck("Decimal comma", nil)
+    }
+    return form
+}
+
+func (form *dialectForm) onChanged(fn func()) {
+    form.delimiter.OnChanged = func(string) { fn() }
+    form.quoting.OnChanged = func(string) { fn() }
+    form.encoding.OnChanged = func(string) { fn() }
+}
+
+func (form *dialectForm) items() []*widget.FormItem {
+    items := []*widget.FormItem{
+        widget.NewFormItem("Delimiter", form.delimiter),
+        widget.NewFormItem("Quoting", form.quoting),
+        widget.NewFormItem("Encoding", form.encoding),
+    }
+    if form.decimalComma != nil {
+        items = append(items, widget.NewFormItem("", form.decimalComma))
+    }
+    return items
+}
+
+func (form *dialectForm) dialect() recorder.CSVDialect {
+    dialect := recorder.CSVDialect{
+        Quoting:  recorder.CSVQuoting(form.quoting.Selected),
+        Encoding: recorder.CSVEncoding(form.encoding.Selected),
+    }
+    for _, d := range csvDelimiters {
+        if d.name == form.delimiter.Selected {
+            dialect.Delimiter = d.delimiter
+        }
+    }
+    if form.decimalComma != nil {
+        dialect.DecimalComma = form.decimalComma.Checked
+    }
+    return dialect
+}
+
+// importPreviewColumns are the columns of the import preview table.
+var importPreviewColumns = []string{"Row", "Action", "ID", "Name", "GPA", "Active", "Reason"}
+
+// showImportPreview lists what a dry run found and applies the report only
+// when the user confirms it.
+func (g *GUI) showImportPreview(title string, report *recorder.ImportReport) {
+    updateAction := "overwrite"
+    if report.Policy == recorder.ConflictUpsert {
+        updateAction = "merge"
+    }
+
+    var lines [][]string
+    for _, row := range report.Insert {
+        lines = append(lines, importPreviewLine(row, "insert"))
+    }
+    for _, row := range report.Update {
+        lines = append(lines, importPreviewLine(row, updateAction))
+    }
+    for _, rowErr := range report.Skipped {
+        lines = append(lines, []string{strconv.Itoa(rowErr.Row), "skip", "", "", "", "", rowErr.Err.Error()})
+    }
+    for _, rowErr := range report.Conflicts {
+        lines = append(lines, []string{strconv.Itoa(rowErr.Row), "conflict", strconv.Itoa(rowErr.Id), "", "", "", rowErr.Err.Error()})
+    }
+
+    table := widget.NewTable(
+        func() (int, int) { return len(lines) + 1, len(importPreviewColumns) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(cell widget.TableCellID, obj fyne.CanvasObject) {
+            label := obj.(*widget.Label)
+            if cell.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(importPreviewColumns[cell.Col])
+                return
+            }
+            label.TextStyle = fyne.TextStyle{}
+            label.SetText(lines[cell.Row-1][cell.Col])
+        },
+    )
+    for col, width := range []float32{60, 80, 80, 200, 60, 60, 320} {
+        table.SetColumnWidth(col, width)
+    }
+
+    content := container.NewBorder(widget.NewLabel(report.Summary()), nil, nil, nil, table)
+    preview := dialog.NewCustomConfirm(title, "Import", "Cancel", content, func(confirmed bool) {
+        if !confirmed {
+            return
+        }
+
+        err := g.DB.Recorder.ApplyImport(
+            report,
+            g.DB.IdIndex,
+            g.DB.RecordInfo,
+        )
+        if errors.Is(err, recorder.ErrIndexesBehind) {
+            // the rows are stored, bring the indexes up to date with them
+            if loadErr := g.DB.LoadIndex(); loadErr != nil {
+                err = loadErr
+            }
+            g.list.Refresh()
+        }
+        if err != nil {
+            g.showNotification("Error importing: " + err.Error())
+            return
+        }
+
+        g.showNotification("Import completed: " + report.Summary())
         g.list.Refresh()
     }, g.Window)
+    preview.Resize(fyne.NewSize(900, 600))
+    preview.Show()
+}
+
+// importPreviewLine shows a row to import, fields it has no value for are
+// left blank.
+func importPreviewLine(row recorder.ImportRow, action string) []string {
+    student := row.Student
+    line := []string{strconv.Itoa(row.Row), action, strconv.Itoa(student.Id),
+        student.Name, strconv.FormatFloat(student.Gpa, 'f', -1, 64), strconv.FormatBool(student.Active), ""}
+    for _, field := range row.Missing {
+        switch field {
+        case "gpa":
+            line[4] = ""
+        case "active":
+            line[5] = ""
+        }
+    }
+    return line
 }
 
 func (g *GUI) showNotification(message string) {
@@ -507,9 +1369,6 @@ func (g *GUI) deleteStudentByIdWithId(id int) {
     err := g.DB.Recorder.DeleteRecordById(
         id,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -525,9 +1384,6 @@ func (g *GUI) deleteStudentByNameWithName(name string) {
     err := g.DB.Recorder.DeleteRecordByName(
         name,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -543,9 +1399,6 @@ func (g *GUI) deleteStudentByGpaWithGpa(gpa float64) {
     err := g.DB.Recorder.DeleteRecordByGpa(
         gpa,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -561,9 +1414,6 @@ func (g *GUI) deleteStudentByActiveWithActive(active bool) {
     err := g.DB.Recorder.DeleteRecordByActive(
         active,
         g.DB.IdIndex,
-        g.DB.NameIndex,
-        g.DB.GpaIndex,
-        g.DB.ActiveIndex,
         g.DB.RecordInfo,
     )
     if err != nil {
@@ -577,28 +1427,45 @@ func (g *GUI) deleteStudentByActiveWithActive(active bool) {
 
 //  ПОИСК С ОТДЕЛЬНЫМИ ПАРАМЕТРАМИ 
 func (g *GUI) searchStudentByIdWithId(id int) {
-    student, err := g.DB.Recorder.FindById(id, g.DB.IdIndex, g.DB.FilePath)
+    student, err := g.DB.Recorder.FindById(id, g.DB.IdIndex)
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
         return
     }
+    g.rememberResults([]models.Student{*student})
 
     message := fmt.Sprintf("Found student:\nID: %d\nName: %s\nGPA: %.2f\nActive: %t", 
         student.Id, student.Name, student.Gpa, student.Active)
     g.showNotification(message)
 }
 
+func (g *GUI) searchStudentsByIdRange(from, to int) {
+    results, err := g.DB.Recorder.FindByIdRange(from, to, g.DB.IdIndex)
+    if err != nil {
+        g.showNotification("Error searching: " + err.Error())
+        return
+    }
+    g.rememberResults(results)
+
+    message := fmt.Sprintf("Found %d students with ID from %d to %d:\n", len(results), from, to)
+    for _, student := range results {
+        message += fmt.Sprintf("\nID: %d, Name: %s, GPA: %.2f, Active: %t", 
+            student.Id, student.Name, student.Gpa, student.Active)
+    }
+
+    g.showNotification(message)
+}
+
 func (g *GUI) searchStudentByNameWithName(name string) {
     results, err := g.DB.Recorder.FindByName(
         name,
-        g.DB.NameIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
         return
     }
+    g.rememberResults(results)
 
     if len(results) == 0 {
         g.showNotification("No students found with name: " + name)
@@ -617,14 +1484,13 @@ func (g *GUI) searchStudentByNameWithName(name string) {
 func (g *GUI) searchStudentByGpaWithGpa(gpa float64) {
     results, err := g.DB.Recorder.FindByGpa(
         gpa,
-        g.DB.GpaIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
         return
     }
+    g.rememberResults(results)
 
     if len(results) == 0 {
         g.showNotification("No students found with GPA: " + fmt.Sprintf("%.2f", gpa))
@@ -643,14 +1509,13 @@ func (g *GUI) searchStudentByGpaWithGpa(gpa float64) {
 func (g *GUI) searchStudentByActiveWithActive(active bool) {
     results, err := g.DB.Recorder.FindByActive(
         active,
-        g.DB.ActiveIndex,
         g.DB.IdIndex,
-        g.DB.FilePath,
     )
     if err != nil {
         g.showNotification("Error searching: " + err.Error())
         return
     }
+    g.rememberResults(results)
 
     if len(results) == 0 {
         g.showNotification("No students found with Active: " + fmt.Sprintf("%t", active))
@@ -1,31 +1,129 @@
 package main
 
 import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "slices"
+    "strings"
+
     "github.com/kgugunava/database/db"
     "github.com/kgugunava/gui"
     "github.com/kgugunava/database/recorder"
     "github.com/kgugunava/database/scanner"
-    "github.com/kgugunava/database/models"
 )
 
 func main() {
+    dataPath := flag.String("db", "input.jsonl", "data file, its extension selects the format: .jsonl, .sdb (binary) or .pages (paged)")
+    readOnly := flag.Bool("readonly", false, "open the database without taking the write lock")
+    mmap := flag.Bool("mmap", false, "read records through a memory mapping of the data file")
+    importJSON := flag.String("import-json", "", "import students from a JSON array or NDJSON file, - for stdin, and exit")
+    exportJSON := flag.String("export-json", "", "export every student as JSON to a file, - for stdout, and exit")
+    ndjson := flag.Bool("ndjson", false, "export one student per line instead of an indented array")
+    onConflict := recorder.ConflictSkip
+    flag.Func("on-conflict", "what an import does with existing ids: "+conflictPolicyNames()+` (default "skip")`, func(value string) error {
+        policy := recorder.ConflictPolicy(value)
+        if !slices.Contains(recorder.ConflictPolicies, policy) {
+            return fmt.Errorf("must be one of %s", conflictPolicyNames())
+        }
+        onConflict = policy
+        return nil
+    })
+    flag.Parse()
+
     scannerInstance := scanner.NewScanner()
 
     recorderInstance := recorder.NewRecorder(scannerInstance)
 	
-    database := &db.Db{
-        Scanner:     scannerInstance,
-        Recorder:    recorderInstance,
-		FilePath:    "input.jsonl",
-        IdIndex:     make(map[int]int64),
-        NameIndex:   make(map[string]map[int]bool),
-        GpaIndex:    make(map[float64]map[int]bool),
-        ActiveIndex: make(map[bool]map[int]bool),
-        RecordInfo:  make(map[int]models.RecordInfo),
+    database := db.NewDb(*dataPath, scannerInstance, recorderInstance)
+    database.Mmap = *mmap
+
+    err := database.Open(*readOnly)
+    var locked *db.LockedError
+    if errors.As(err, &locked) {
+        log.Fatalf("%v, start with -readonly to browse it", err)
+    }
+    if err != nil {
+        log.Fatal(err)
     }
+    defer database.Close()
 
-    database.LoadIndex("input.jsonl")
+    // without the GUI, for scripts and pipes
+    if *importJSON != "" || *exportJSON != "" {
+        err = runJSON(database, *importJSON, *exportJSON, *ndjson, onConflict)
+        if err != nil {
+            database.Close()
+            log.Fatal(err)
+        }
+        return
+    }
 
     guiInstance := gui.NewGUI(database)
     guiInstance.Run()
-}
\ No newline at end of file
+}
+
+func conflictPolicyNames() string {
+    names := make([]string, len(recorder.ConflictPolicies))
+    for i, policy := range recorder.ConflictPolicies {
+        names[i] = string(policy)
+    }
+    return strings.Join(names, ", ")
+}
+
+// runJSON imports and then exports JSON, "-" meaning stdin and stdout. The
+// import summary goes to stderr so that it does not mix with an export.
+func runJSON(database *db.Db, importPath, exportPath string, ndjson bool, policy recorder.ConflictPolicy) error {
+    if importPath != "" {
+        var in io.Reader = os.Stdin
+        if importPath != "-" {
+            file, err := os.Open(importPath)
+            if err != nil {
+                return err
+            }
+            defer file.Close()
+            in = file
+        }
+
+        report, err := database.Recorder.ImportFromJSON(
+            in,
+            recorder.ImportOptions{OnConflict: policy},
+            database.IdIndex,
+            database.RecordInfo,
+        )
+        if report != nil {
+            fmt.Fprintln(os.Stderr, report.Summary())
+            for _, rowErr := range append(report.Skipped, report.Conflicts...) {
+                fmt.Fprintln(os.Stderr, "  ", rowErr.Error())
+            }
+        }
+        if err != nil {
+            return err
+        }
+    }
+
+    if exportPath != "" {
+        format := db.JSONPretty
+        if ndjson {
+            format = db.NDJSON
+        }
+
+        out := os.Stdout
+        if exportPath != "-" {
+            file, err := os.Create(exportPath)
+            if err != nil {
+                return err
+            }
+            defer file.Close()
+            out = file
+        }
+
+        _, err := database.ExportJSON(out, db.Query{}, format)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}